@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// adminTokenField is the form field carrying ADMIN_TOKEN for clients that can't set headers.
+const adminTokenField = "admin_token"
+
+// adminToken returns the ADMIN_TOKEN presented by r: a bearer token, the password of
+// basic auth, which makes browsers prompt for it, or the admin_token form field.
+func adminToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	if auth == "" && r.Method == http.MethodPost {
+		return r.FormValue(adminTokenField)
+	}
+	return ""
+}
+
+// requireAdmin answers 401 unless the request presents ADMIN_TOKEN, every request
+// passes when it is empty.
+func (w *WebServer) requireAdmin(next http.Handler) http.Handler {
+	want := w.provider.cfg.AdminToken
+	if want == "" {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(adminToken(r)), []byte(want)) != 1 {
+			w.logger.Warn("Admin request rejected, missing or wrong token", "method", r.Method, "path", r.URL.Path, "remote", r.RemoteAddr)
+			rw.Header().Set("WWW-Authenticate", `Basic realm="csi-debugger"`)
+			http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
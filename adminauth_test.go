@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminToken(t *testing.T) {
+	cfg := Config{AdminToken: "s3cret"}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.Set("a.txt", "1", "v1", 420)
+	web := newTestWeb(t, cfg, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, path := range []string{"/", "/api/secrets", "/metrics", "/readyz", "/api/events"} {
+		if code := serve(httptest.NewRequest(http.MethodGet, path, nil)); code != http.StatusUnauthorized {
+			t.Errorf("GET %s without a token: expected 401, got %d", path, code)
+		}
+	}
+	if code := serve(httptest.NewRequest(http.MethodGet, "/healthz", nil)); code != http.StatusOK {
+		t.Errorf("expected /healthz open, got %d", code)
+	}
+
+	wrong := httptest.NewRequest(http.MethodGet, "/api/secrets", nil)
+	wrong.Header.Set("Authorization", "Bearer nope")
+	if code := serve(wrong); code != http.StatusUnauthorized {
+		t.Errorf("wrong bearer token: expected 401, got %d", code)
+	}
+	bearer := httptest.NewRequest(http.MethodGet, "/api/secrets", nil)
+	bearer.Header.Set("Authorization", "Bearer s3cret")
+	if code := serve(bearer); code != http.StatusOK {
+		t.Errorf("bearer token: expected 200, got %d", code)
+	}
+	basic := httptest.NewRequest(http.MethodGet, "/", nil)
+	basic.SetBasicAuth("admin", "s3cret")
+	if code := serve(basic); code != http.StatusOK {
+		t.Errorf("basic auth: expected 200, got %d", code)
+	}
+
+	form := url.Values{"name": {"b.txt"}, "value": {"2"}, "version": {"v1"}, adminTokenField: {"s3cret"}}
+	req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if code := serve(req); code != http.StatusSeeOther {
+		t.Errorf("form token: expected 303, got %d", code)
+	}
+	if _, ok := store.Get("b.txt"); !ok {
+		t.Error("expected the form update with the token to be applied")
+	}
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"list", "-addr", srv.URL}, &stdout, &stderr); code != 1 {
+		t.Errorf("CLI without a token exited with %d, want 1", code)
+	}
+	if code := runCLI([]string{"list", "-addr", srv.URL, "-token", "s3cret"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "a.txt") {
+		t.Errorf("CLI with the token exited with %d: %s%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestAdminTokenDisabled(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the admin API open without ADMIN_TOKEN, got %d", rec.Code)
+	}
+}
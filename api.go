@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func (w *WebServer) writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		w.logger.Error("failed to encode JSON response", "error", err)
+	}
+}
+
+// writeJSONError writes a {"error": msg} JSON body.
+func (w *WebServer) writeJSONError(rw http.ResponseWriter, status int, msg string) {
+	w.writeJSON(rw, status, map[string]string{"error": msg})
+}
+
+// notFoundHTML is served for unknown non API paths.
+const notFoundHTML = `<!DOCTYPE html>
+<html>
+<head><title>404 Not Found</title></head>
+<body>
+    <h1>404 Not Found</h1>
+    <p>No such page, go back to the <a href="/">admin UI</a>.</p>
+</body>
+</html>
+`
+
+// handleNotFound answers unregistered paths, with a JSON error under /api/ and an
+// HTML page elsewhere, instead of rendering the index.
+func (w *WebServer) handleNotFound(rw http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.writeJSONError(rw, http.StatusNotFound, "no such endpoint "+r.Method+" "+r.URL.Path)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusNotFound)
+	io.WriteString(rw, notFoundHTML)
+}
+
+// SecretView is a secret as presented by the admin API and UI.
+type SecretView struct {
+	Secret
+	// Value shadows Secret.Value in JSON, it is nil and omitted when values are masked
+	Value *string `json:"value,omitempty"`
+	// Stale is set when the secret wasn't updated for longer than STALE_AFTER
+	Stale bool `json:"stale"`
+	// Owned replaces the owner token, which is never shown
+	Owned bool `json:"owned"`
+}
+
+// view presents sec, with its value only when reveal is set.
+func (w *WebServer) view(sec Secret, reveal bool) SecretView {
+	after := w.provider.cfg.StaleAfter
+	v := SecretView{
+		Secret: sec,
+		Stale:  after > 0 && time.Since(sec.UpdatedAt) > after,
+		Owned:  sec.Owner != "",
+	}
+	v.Secret.Owner = ""
+	if reveal {
+		v.Value = &v.Secret.Value
+	}
+	return v
+}
+
+func (w *WebServer) views(secrets []Secret, reveal bool) []SecretView {
+	views := make([]SecretView, 0, len(secrets))
+	for _, sec := range secrets {
+		views = append(views, w.view(sec, reveal))
+	}
+	return views
+}
+
+// handleListSecrets lists the secrets without their values, unless ?reveal=true.
+func (w *WebServer) handleListSecrets(rw http.ResponseWriter, r *http.Request) {
+	reveal, _ := strconv.ParseBool(r.URL.Query().Get("reveal"))
+	w.writeJSON(rw, http.StatusOK, w.views(w.store.List(), reveal))
+}
+
+// handleGetSecret is an explicit fetch and always includes the value.
+func (w *WebServer) handleGetSecret(rw http.ResponseWriter, r *http.Request) {
+	sec, ok := w.store.Get(r.PathValue("name"))
+	if !ok {
+		w.writeJSONError(rw, http.StatusNotFound, "secret not found")
+		return
+	}
+	w.writeJSON(rw, http.StatusOK, w.view(sec, true))
+}
+
+// handleScope assigns the named secret to the service account given by the
+// serviceAccount query parameter, an empty value makes it global again.
+func (w *WebServer) handleScope(rw http.ResponseWriter, r *http.Request) {
+	sa := r.URL.Query().Get("serviceAccount")
+	token := ownerToken(r)
+	sec, ok, err := w.store.UpdateChecked(r.PathValue("name"), func(sec *Secret) error {
+		sec.ServiceAccount = sa
+		return checkOwner(*sec, token)
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if !ok {
+		w.writeJSONError(rw, http.StatusNotFound, "secret not found")
+		return
+	}
+	w.logger.Info("Secret scope changed via API", "name", sec.Name, "service_account", sa)
+	w.writeJSON(rw, http.StatusOK, w.view(sec, true))
+}
+
+// handleChmod changes only the mode of a secret and bumps its version, for rotations that
+// change permissions but not content.
+func (w *WebServer) handleChmod(rw http.ResponseWriter, r *http.Request) {
+	mode, err := parseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		w.rejectJSON(rw, reasonMode, err.Error())
+		return
+	}
+	token := ownerToken(r)
+	sec, ok, err := w.store.UpdateChecked(r.PathValue("name"), func(sec *Secret) error {
+		sec.Mode = mode
+		sec.Version = bumpVersion(sec.Version)
+		if err := checkOwner(*sec, token); err != nil {
+			return err
+		}
+		return w.checkVersion(sec.Version)
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if !ok {
+		w.writeJSONError(rw, http.StatusNotFound, "secret not found")
+		return
+	}
+	if err != nil {
+		w.rejectJSON(rw, rejectionReason(err), err.Error())
+		return
+	}
+	w.logger.Info("Secret mode changed via API", "name", sec.Name, "mode", fmt.Sprintf("%04o", mode), "version", sec.Version)
+	w.writeJSON(rw, http.StatusOK, w.view(sec, true))
+}
+
+// handleStats reports the store generation and the observed Mount intervals per target path.
+func (w *WebServer) handleStats(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, map[string]any{
+		"generation": w.store.Generation(),
+		"mounts":     w.provider.stats.Snapshot(),
+	})
+}
+
+type modePreview struct {
+	Mode     int32  `json:"mode"`
+	Octal    string `json:"octal"`
+	Symbolic string `json:"symbolic"`
+}
+
+// handleModePreview shows how a mode given as octal, decimal or symbolic is interpreted.
+func (w *WebServer) handleModePreview(rw http.ResponseWriter, r *http.Request) {
+	mode, err := parseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.writeJSON(rw, http.StatusOK, modePreview{
+		Mode:     mode,
+		Octal:    fmt.Sprintf("%04o", mode),
+		Symbolic: formatMode(mode),
+	})
+}
+
+// handleWait long-polls until the named secret exists with a version different from
+// the sinceVersion query parameter, or answers 408 once timeout elapses.
+func (w *WebServer) handleWait(rw http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	since := r.URL.Query().Get("sinceVersion")
+
+	timeout := defaultWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			w.writeJSONError(rw, http.StatusBadRequest, "invalid timeout")
+			return
+		}
+		timeout = min(d, maxWaitTimeout)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		// Grab the channel before reading so a change in between isn't missed
+		changed := w.store.Changed()
+		if sec, ok := w.store.Get(name); ok && sec.Version != since {
+			w.writeJSON(rw, http.StatusOK, w.view(sec, true))
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			w.writeJSONError(rw, http.StatusRequestTimeout, "timed out waiting for secret change")
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
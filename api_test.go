@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func newTestWebServer(t *testing.T, store *MemoryStore) *httptest.Server {
+	t.Helper()
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWaitForSecretChange(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "old", "v1", 420)
+	srv := newTestWebServer(t, store)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.Set("other.txt", "noise", "v1", 420)
+		store.Set("app.txt", "new", "v2", 420)
+	}()
+
+	resp, err := http.Get(srv.URL + "/api/secrets/app.txt/wait?sinceVersion=v1&timeout=5s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var sec Secret
+	if err := json.NewDecoder(resp.Body).Decode(&sec); err != nil {
+		t.Fatal(err)
+	}
+	if sec.Version != "v2" || sec.Value != "new" {
+		t.Errorf("unexpected secret returned: %+v", sec)
+	}
+}
+
+func TestWaitForSecretTimeout(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "old", "v1", 420)
+	srv := newTestWebServer(t, store)
+
+	resp, err := http.Get(srv.URL + "/api/secrets/app.txt/wait?sinceVersion=v1&timeout=50ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Fatalf("expected 408, got %d", resp.StatusCode)
+	}
+}
+
+func TestListSecretsStale(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+	store.Set("old.txt", "old", "v1", 420)
+	store.now = time.Now
+	store.Set("fresh.txt", "fresh", "v1", 420)
+
+	web := newTestWeb(t, Config{StaleAfter: time.Hour}, store)
+	rec := httptest.NewRecorder()
+	web.handleListSecrets(rec, httptest.NewRequest(http.MethodGet, "/api/secrets", nil))
+
+	var views []SecretView
+	if err := json.Unmarshal(rec.Body.Bytes(), &views); err != nil {
+		t.Fatal(err)
+	}
+	stale := map[string]bool{}
+	for _, v := range views {
+		stale[v.Name] = v.Stale
+	}
+	if !stale["old.txt"] || stale["fresh.txt"] || len(stale) != 2 {
+		t.Errorf("unexpected stale flags: %v", stale)
+	}
+
+	rec = httptest.NewRecorder()
+	web.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `class="stale"`) {
+		t.Error("expected the stale secret to be highlighted in the UI")
+	}
+}
+
+func TestMaskValues(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "hunter2", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+
+	rec := httptest.NewRecorder()
+	web.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/?mask=true", nil))
+	if body := rec.Body.String(); strings.Contains(body, "hunter2") || !strings.Contains(body, "••••••") {
+		t.Errorf("expected the value to be masked in the UI:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	web.handleListSecrets(rec, httptest.NewRequest(http.MethodGet, "/api/secrets", nil))
+	if body := rec.Body.String(); strings.Contains(body, "hunter2") || strings.Contains(body, `"value"`) {
+		t.Errorf("expected the value to be omitted from the API: %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	web.handleListSecrets(rec, httptest.NewRequest(http.MethodGet, "/api/secrets?reveal=true", nil))
+	var views []Secret
+	if err := json.Unmarshal(rec.Body.Bytes(), &views); err != nil {
+		t.Fatal(err)
+	}
+	if len(views) != 1 || views[0].Value != "hunter2" {
+		t.Errorf("expected the value with reveal=true, got %+v", views)
+	}
+}
+
+func TestUnknownRoutes(t *testing.T) {
+	srv := newTestWebServer(t, NewMemoryStore(testLogger(), Config{}))
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/api/foo", "application/json"},
+		{"/bogus", "text/html"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", tt.path, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("%s: expected %s, got %q", tt.path, tt.contentType, ct)
+		}
+		if strings.Contains(string(body), "CSI Secret Debugger") {
+			t.Errorf("%s: rendered the index page", tt.path)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/?q=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the index on /, got %d", resp.StatusCode)
+	}
+}
+
+func TestChmodSecret(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "hunter2", "v1", 0o644)
+	srv := newTestWebServer(t, store)
+	provider := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	resp, err := http.Post(srv.URL+"/api/secrets/app.txt/chmod?mode=0600", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	mount, err := provider.Mount(t.Context(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, v := mount.GetFiles()[0], mount.GetObjectVersion()[0]
+	if string(f.GetContents()) != "hunter2" {
+		t.Errorf("expected unchanged content, got %q", f.GetContents())
+	}
+	if f.GetMode() != 0o600 || v.GetVersion() != "v2" {
+		t.Errorf("expected mode 0600 at v2, got %o at %s", f.GetMode(), v.GetVersion())
+	}
+
+	for path, want := range map[string]int{
+		"/api/secrets/app.txt/chmod?mode=rwx":    http.StatusBadRequest,
+		"/api/secrets/other.txt/chmod?mode=0600": http.StatusNotFound,
+	} {
+		resp, err := http.Post(srv.URL+path, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", path, want, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Attributes set by the secrets-store-csi-driver on every MountRequest, next to the
+// SecretProviderClass parameters.
+const (
+	serviceAccountAttribute = "csi.storage.k8s.io/serviceAccount.name"
+	podNameAttribute        = "csi.storage.k8s.io/pod.name"
+	podNamespaceAttribute   = "csi.storage.k8s.io/pod.namespace"
+	// serviceAccountTokensAttribute holds the pod service account tokens when the
+	// CSIDriver requests them
+	serviceAccountTokensAttribute = "csi.storage.k8s.io/serviceAccount.tokens"
+)
+
+// SecretProviderClass parameters understood by Mount.
+const (
+	// authTokenAttribute is checked against MOUNT_REQUIRE_TOKEN
+	authTokenAttribute = "auth_token"
+	// bundleAttribute restricts the mount to the secrets of a bundle
+	bundleAttribute = "bundle"
+	// concatFileAttribute overrides CONCAT_FILE, an empty value keeps individual files
+	concatFileAttribute = "concatFile"
+	// providerAttribute echoes the provider name the SPC targets, checked against EXPECTED_PROVIDERS
+	providerAttribute = "provider"
+	// limitAttribute caps the number of mounted secrets, by sorted name
+	limitAttribute = "limit"
+	// objectsAttribute and secretsAttribute restrict the mount to the listed secret names
+	objectsAttribute = "objects"
+	secretsAttribute = "secrets"
+	// labelsAttribute restricts the mount to the secrets carrying every key=value pair listed
+	labelsAttribute = "labels"
+)
+
+// parseAttributes decodes the JSON encoded attributes of a MountRequest.
+func parseAttributes(s string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	if s == "" {
+		return attrs, nil
+	}
+	if err := json.Unmarshal([]byte(s), &attrs); err != nil {
+		return nil, fmt.Errorf("decoding attributes: %w", err)
+	}
+	return attrs, nil
+}
+
+// parseObjectNames parses a list of secret names given as a JSON array of names or of
+// {"objectName": ...} objects, the YAML "- objectName: name" list other providers use,
+// or names separated by commas or newlines.
+func parseObjectNames(v string) ([]string, error) {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "[") {
+		var names []string
+		if err := json.Unmarshal([]byte(v), &names); err == nil {
+			return names, nil
+		}
+		var objects []struct {
+			ObjectName string `json:"objectName"`
+		}
+		if err := json.Unmarshal([]byte(v), &objects); err != nil {
+			return nil, fmt.Errorf("must be a JSON array of names or objects with an objectName: %w", err)
+		}
+		names = make([]string, 0, len(objects))
+		for _, o := range objects {
+			names = append(names, o.ObjectName)
+		}
+		return names, nil
+	}
+
+	var names []string
+	yaml := strings.Contains(v, "objectName:")
+	for _, field := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '\n' }) {
+		field = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(field), "-"))
+		if yaml {
+			name, ok := strings.CutPrefix(field, "objectName:")
+			if !ok {
+				continue
+			}
+			field = strings.Trim(strings.TrimSpace(name), `"'`)
+		}
+		if field != "" {
+			names = append(names, field)
+		}
+	}
+	return names, nil
+}
+
+// selectedObjects returns the secret names listed in the objects and secrets attributes,
+// as store keys, nil when neither is set.
+func (s *ProviderServer) selectedObjects(attrs map[string]string) (map[string]bool, error) {
+	var selected map[string]bool
+	for _, attr := range []string{objectsAttribute, secretsAttribute} {
+		v, ok := attrs[attr]
+		if !ok {
+			continue
+		}
+		names, err := parseObjectNames(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s attribute: %w", attr, err)
+		}
+		if selected == nil {
+			selected = make(map[string]bool, len(names))
+		}
+		for _, name := range names {
+			selected[s.store.key(name)] = true
+		}
+	}
+	return selected, nil
+}
+
+// checkProvider compares the provider attribute with EXPECTED_PROVIDERS, a mismatch is
+// logged, and rejected with FailedPrecondition under PROVIDER_CHECK_STRICT.
+func (s *ProviderServer) checkProvider(targetPath, provider string) error {
+	if len(s.cfg.ExpectedProviders) == 0 || slices.Contains(s.cfg.ExpectedProviders, provider) {
+		return nil
+	}
+	s.logger.Warn("Mount provider attribute mismatch, check the SecretProviderClass",
+		"target_path", targetPath,
+		"provider", provider,
+		"expected", s.cfg.ExpectedProviders,
+		"strict", s.cfg.ProviderCheckStrict,
+	)
+	if !s.cfg.ProviderCheckStrict {
+		return nil
+	}
+	return status.Errorf(codes.FailedPrecondition, "%s attribute %q is not one of %s",
+		providerAttribute, provider, strings.Join(s.cfg.ExpectedProviders, ", "))
+}
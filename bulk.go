@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// bulkItem is an element of the /bulk JSON array.
+type bulkItem struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Version string `json:"version"`
+	// Mode is parsed per item so a bad one is reported along with the others
+	Mode            json.RawMessage   `json:"mode"`
+	Annotations     map[string]string `json:"annotations"`
+	Labels          map[string]string `json:"labels"`
+	Transform       string            `json:"transform"`
+	RotateOnMount   bool              `json:"rotateOnMount"`
+	Failing         bool              `json:"failing"`
+	ServiceAccount  string            `json:"serviceAccount"`
+	Bundle          string            `json:"bundle"`
+	Type            string            `json:"type"`
+	VariantStrategy string            `json:"variantStrategy"`
+	MountAs         string            `json:"mountAs"`
+	Encoding        string            `json:"encoding"`
+	LineEnding      string            `json:"lineEnding"`
+	Owner           string            `json:"owner"`
+	ContentEncoding string            `json:"contentEncoding"`
+}
+
+// bulkItemError reports why a /bulk item was rejected.
+type bulkItemError struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Error  string `json:"error"`
+}
+
+// secret returns the validated secret of the item, mode defaulting to 0644.
+func (i bulkItem) secret() (Secret, error) {
+	value, err := decodeContent(i.Value, i.ContentEncoding)
+	if err != nil {
+		return Secret{}, invalid(reasonEncoding, err)
+	}
+	// Default mode 0644 (decimal 420)
+	mode := patchMode(420)
+	if len(i.Mode) > 0 && string(i.Mode) != "null" {
+		if err := mode.UnmarshalJSON(i.Mode); err != nil {
+			return Secret{}, err
+		}
+	}
+	sec := Secret{
+		Name:            i.Name,
+		Value:           value,
+		Version:         i.Version,
+		Mode:            int32(mode),
+		Annotations:     i.Annotations,
+		Labels:          i.Labels,
+		Transform:       i.Transform,
+		RotateOnMount:   i.RotateOnMount,
+		Failing:         i.Failing,
+		ServiceAccount:  i.ServiceAccount,
+		Bundle:          i.Bundle,
+		Type:            i.Type,
+		VariantStrategy: i.VariantStrategy,
+		MountAs:         i.MountAs,
+		Encoding:        i.Encoding,
+		LineEnding:      i.LineEnding,
+		Owner:           i.Owner,
+	}
+	if err := validateSecret(sec); err != nil {
+		return Secret{}, err
+	}
+	return sec, nil
+}
+
+// SetMany stores every secret, replacing existing ones, under a single write lock with
+// one change notification, so a Mount sees all of them or none. Nothing is stored if
+// any secret is invalid.
+func (s *MemoryStore) SetMany(secrets []Secret) error {
+	for _, sec := range secrets {
+		if err := validateSecret(sec); err != nil {
+			return fmt.Errorf("secret %q: %w", sec.Name, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sec := range secrets {
+		s.putLocked(sec)
+	}
+	s.notifyLocked()
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBulkItemErrors(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("kept.txt", "old", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+
+	data := `[{"name":"kept.txt","value":"new"},
+		{"name":"","value":"x"},
+		{"name":"mode.txt","value":"x","mode":"rwx"},
+		{"name":"enc.txt","value":"x","encoding":"ebcdic"},
+		{"name":"ok.txt","value":"x","mode":"0600"}]`
+	form := url.Values{"json_data": {data}}
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	web.handleBulk(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var body struct {
+		Error string          `json:"error"`
+		Items []bulkItemError `json:"items"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []bulkItemError{
+		{Index: 1, Reason: reasonRequired},
+		{Index: 2, Name: "mode.txt", Reason: reasonMode},
+		{Index: 3, Name: "enc.txt", Reason: reasonEncoding},
+	}
+	if len(body.Items) != len(want) {
+		t.Fatalf("expected %d item errors, got %+v", len(want), body.Items)
+	}
+	for n, w := range want {
+		got := body.Items[n]
+		if got.Index != w.Index || got.Name != w.Name || got.Reason != w.Reason || got.Error == "" {
+			t.Errorf("item error %d: got %+v, want %+v", n, got, w)
+		}
+	}
+
+	if sec, _ := store.Get("kept.txt"); sec.Value != "old" {
+		t.Errorf("a rejected batch updated kept.txt: %+v", sec)
+	}
+	if _, ok := store.Get("ok.txt"); ok {
+		t.Error("a rejected batch created ok.txt")
+	}
+}
+
+func TestSetMany(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "old", "v1", 420)
+	gen := store.Generation()
+
+	if err := store.SetMany([]Secret{{Name: "a.txt", Value: "new"}, {Name: "b.txt", Value: "2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Generation(); got != gen+1 {
+		t.Errorf("expected a single generation bump, went from %d to %d", gen, got)
+	}
+	if sec, _ := store.Get("a.txt"); sec.Value != "new" {
+		t.Errorf("a.txt not replaced: %+v", sec)
+	}
+
+	if err := store.SetMany([]Secret{{Name: "c.txt", Value: "3"}, {Name: "d.txt", Type: "bogus"}}); err == nil {
+		t.Error("expected an error for an invalid secret")
+	}
+	if _, ok := store.Get("c.txt"); ok {
+		t.Error("SetMany partially applied an invalid batch")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// secretGroup is the secrets of a bundle as shown in the UI.
+type secretGroup struct {
+	Bundle  string
+	Secrets []SecretView
+}
+
+// groupByBundle groups views by bundle, secrets without a bundle first, keeping the
+// order of views within each group.
+func groupByBundle(views []SecretView) []secretGroup {
+	var groups []secretGroup
+	index := make(map[string]int)
+	for _, v := range views {
+		i, ok := index[v.Bundle]
+		if !ok {
+			i = len(groups)
+			index[v.Bundle] = i
+			groups = append(groups, secretGroup{Bundle: v.Bundle})
+		}
+		groups[i].Secrets = append(groups[i].Secrets, v)
+	}
+	slices.SortStableFunc(groups, func(a, b secretGroup) int {
+		return strings.Compare(a.Bundle, b.Bundle)
+	})
+	return groups
+}
+
+// SetBundleDisabled disables or enables every secret of bundle at once, it returns the
+// number of secrets in the bundle.
+func (s *MemoryStore) SetBundleDisabled(bundle string, disabled bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.setBundleDisabledLocked(bundle, disabled)
+	if n > 0 {
+		s.notifyLocked()
+	}
+	return n
+}
+
+func (s *MemoryStore) setBundleDisabledLocked(bundle string, disabled bool) int {
+	n := 0
+	for name, sec := range s.secrets {
+		if sec.Bundle != bundle {
+			continue
+		}
+		n++
+		sec.Disabled = disabled
+		s.secrets[name] = sec
+	}
+	return n
+}
+
+// DeleteBundle deletes every secret of bundle at once, it returns the number of deleted secrets.
+func (s *MemoryStore) DeleteBundle(bundle string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.deleteBundleLocked(bundle)
+	if n > 0 {
+		s.notifyLocked()
+	}
+	return n
+}
+
+func (s *MemoryStore) deleteBundleLocked(bundle string) int {
+	n := 0
+	for name, sec := range s.secrets {
+		if sec.Bundle == bundle {
+			s.deleteLocked(name)
+			n++
+		}
+	}
+	return n
+}
+
+type bundleResult struct {
+	Bundle  string `json:"bundle"`
+	Secrets int    `json:"secrets"`
+}
+
+func (w *WebServer) handleBundleEnable(rw http.ResponseWriter, r *http.Request) {
+	w.setBundleDisabled(rw, r, false)
+}
+
+func (w *WebServer) handleBundleDisable(rw http.ResponseWriter, r *http.Request) {
+	w.setBundleDisabled(rw, r, true)
+}
+
+func (w *WebServer) setBundleDisabled(rw http.ResponseWriter, r *http.Request, disabled bool) {
+	bundle := r.PathValue("name")
+	var n int
+	err := w.store.Owned(ownerToken(r), func() []string { return w.store.bundleMembersLocked(bundle) }, func() bool {
+		n = w.store.setBundleDisabledLocked(bundle, disabled)
+		return n > 0
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if n == 0 {
+		w.writeJSONError(rw, http.StatusNotFound, "bundle not found")
+		return
+	}
+	w.logger.Info("Bundle toggled via API", "bundle", bundle, "disabled", disabled, "secrets", n)
+	w.writeJSON(rw, http.StatusOK, bundleResult{Bundle: bundle, Secrets: n})
+}
+
+func (w *WebServer) handleBundleDelete(rw http.ResponseWriter, r *http.Request) {
+	bundle := r.PathValue("name")
+	var n int
+	err := w.store.Owned(ownerToken(r), func() []string { return w.store.bundleMembersLocked(bundle) }, func() bool {
+		n = w.store.deleteBundleLocked(bundle)
+		return n > 0
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if n == 0 {
+		w.writeJSONError(rw, http.StatusNotFound, "bundle not found")
+		return
+	}
+	w.logger.Info("Bundle deleted via API", "bundle", bundle, "secrets", n)
+	w.writeJSON(rw, http.StatusOK, bundleResult{Bundle: bundle, Secrets: n})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func filePaths(store *MemoryStore) []string {
+	files, _, _ := store.GetFiles(nil)
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func TestBundleEnableDisable(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "a1.txt", Value: "1", Bundle: "app-a"})
+	store.Put(Secret{Name: "a2.txt", Value: "2", Bundle: "app-a"})
+	store.Put(Secret{Name: "b.txt", Value: "3", Bundle: "app-b"})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	post := func(path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code
+	}
+
+	if code := post("/api/bundles/app-a/disable"); code != http.StatusOK {
+		t.Fatalf("disable: expected 200, got %d", code)
+	}
+	if got := filePaths(store); !reflect.DeepEqual(got, []string{"b.txt"}) {
+		t.Errorf("expected disabled bundle to be skipped, got %v", got)
+	}
+
+	if code := post("/api/bundles/app-a/enable"); code != http.StatusOK {
+		t.Fatalf("enable: expected 200, got %d", code)
+	}
+	if got := filePaths(store); !reflect.DeepEqual(got, []string{"a1.txt", "a2.txt", "b.txt"}) {
+		t.Errorf("expected enabled bundle to be served, got %v", got)
+	}
+
+	if code := post("/api/bundles/app-b/delete"); code != http.StatusOK {
+		t.Fatalf("delete: expected 200, got %d", code)
+	}
+	if got := filePaths(store); !reflect.DeepEqual(got, []string{"a1.txt", "a2.txt"}) {
+		t.Errorf("expected deleted bundle to be gone, got %v", got)
+	}
+	if code := post("/api/bundles/missing/disable"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown bundle, got %d", code)
+	}
+}
+
+func TestMountBundleAttribute(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "a.txt", Value: "1", Bundle: "app-a"})
+	store.Put(Secret{Name: "b.txt", Value: "2", Bundle: "app-b"})
+	store.Put(Secret{Name: "none.txt", Value: "3"})
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	if got := mountedPaths(t, srv, map[string]string{bundleAttribute: "app-a"}); !reflect.DeepEqual(got, []string{"a.txt"}) {
+		t.Errorf("bundle app-a mounted %v", got)
+	}
+	if got := mountedPaths(t, srv, nil); len(got) != 3 {
+		t.Errorf("expected every secret without a bundle attribute, got %v", got)
+	}
+}
+
+func TestGroupByBundle(t *testing.T) {
+	views := []SecretView{
+		{Secret: Secret{Name: "a", Bundle: "z"}},
+		{Secret: Secret{Name: "b"}},
+		{Secret: Secret{Name: "c", Bundle: "m"}},
+		{Secret: Secret{Name: "d", Bundle: "z"}},
+	}
+	groups := groupByBundle(views)
+	var got []string
+	for _, g := range groups {
+		for _, s := range g.Secrets {
+			got = append(got, g.Bundle+":"+s.Name)
+		}
+	}
+	if want := []string{":b", "m:c", "z:a", "z:d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByBundle = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "net/http"
+
+// clearConfirmField must be "yes" on /clear unless ADMIN_TOKEN guards the admin server.
+const clearConfirmField = "confirm"
+
+// Clear deletes every secret under a single write lock, it returns the number of deleted secrets.
+func (s *MemoryStore) Clear() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.clearLocked()
+	if n > 0 {
+		s.notifyLocked()
+	}
+	return n
+}
+
+func (s *MemoryStore) clearLocked() int {
+	n := len(s.secrets)
+	for name := range s.secrets {
+		s.deleteLocked(name)
+	}
+	return n
+}
+
+func (w *WebServer) handleClear(rw http.ResponseWriter, r *http.Request) {
+	// requireAdmin already checked the token when one is configured
+	if r.FormValue(clearConfirmField) != "yes" && w.provider.cfg.AdminToken == "" {
+		w.reject(rw, reasonRequired, "Clearing the store requires confirm=yes")
+		return
+	}
+	var n int
+	err := w.store.Owned(ownerToken(r), w.store.namesLocked, func() bool {
+		n = w.store.clearLocked()
+		return n > 0
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	w.logger.Info("Store cleared via UI", "secrets", n)
+	http.Redirect(rw, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestClear(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "a.txt", Value: "1"})
+	store.Put(Secret{Name: "b.txt", Value: "2"})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	post := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/clear", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	gen := store.Generation()
+	if code := post(url.Values{}); code != http.StatusBadRequest {
+		t.Fatalf("without confirmation: expected 400, got %d", code)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("expected the store to be untouched, got %d secrets", store.Len())
+	}
+	if code := post(url.Values{"confirm": {"yes"}}); code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", code)
+	}
+	if store.Len() != 0 {
+		t.Errorf("expected an empty store, got %d secrets", store.Len())
+	}
+	if store.Generation() != gen+1 {
+		t.Errorf("expected a single generation bump, got %d -> %d", gen, store.Generation())
+	}
+	if n := store.Clear(); n != 0 {
+		t.Errorf("clearing an empty store deleted %d secrets", n)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+const cliUsage = `Usage:
+  csi-debugger                              start the provider and admin servers
+  csi-debugger list   [flags]               list secrets
+  csi-debugger get    [flags] NAME          print the content of a secret
+  csi-debugger set    [flags] NAME VALUE    create or update a secret
+  csi-debugger delete [flags] NAME          delete a secret
+  csi-debugger ping   [flags]               call Version on the provider socket
+
+Flags must precede positional arguments:
+  -addr     admin server URL (default $CSI_DEBUGGER_ADDR or http://localhost:8090)
+  -token    ADMIN_TOKEN of the admin server (default $CSI_DEBUGGER_TOKEN)
+  -version  version of the secret for set (default v1)
+  -mode     file mode for set, octal or decimal (default 0644)
+  -socket   provider socket for ping (default $SOCKET_PATH or /tmp/csi-debugger.sock)
+`
+
+// cliCommand is a parsed one-shot subcommand.
+type cliCommand struct {
+	Name    string
+	Addr    string
+	Args    []string
+	Version string
+	Mode    string
+	Socket  string
+	Token   string
+}
+
+// parseCLIArgs parses os.Args[1:] into a subcommand.
+func parseCLIArgs(args []string) (cliCommand, error) {
+	if len(args) == 0 {
+		return cliCommand{}, errors.New("missing subcommand")
+	}
+	cmd := cliCommand{Name: args[0]}
+
+	addr := os.Getenv("CSI_DEBUGGER_ADDR")
+	if addr == "" {
+		addr = "http://localhost:8090"
+	}
+
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&cmd.Addr, "addr", addr, "admin server URL")
+	fs.StringVar(&cmd.Version, "version", "v1", "secret version")
+	fs.StringVar(&cmd.Mode, "mode", "0644", "secret file mode")
+	fs.StringVar(&cmd.Socket, "socket", "", "provider socket path")
+	fs.StringVar(&cmd.Token, "token", os.Getenv("CSI_DEBUGGER_TOKEN"), "admin token")
+	if err := fs.Parse(args[1:]); err != nil {
+		return cliCommand{}, err
+	}
+	cmd.Args = fs.Args()
+
+	want := map[string]int{"list": 0, "get": 1, "delete": 1, "set": 2, "ping": 0}
+	n, ok := want[cmd.Name]
+	if !ok {
+		return cliCommand{}, fmt.Errorf("unknown subcommand %q", cmd.Name)
+	}
+	if len(cmd.Args) != n {
+		return cliCommand{}, fmt.Errorf("%s expects %d argument(s), got %d", cmd.Name, n, len(cmd.Args))
+	}
+	if _, err := parseMode(cmd.Mode); err != nil {
+		return cliCommand{}, err
+	}
+	cmd.Addr = strings.TrimSuffix(cmd.Addr, "/")
+	return cmd, nil
+}
+
+// runCLI executes a subcommand against a running instance and returns the process exit code.
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	cmd, err := parseCLIArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n\n%s", err, cliUsage)
+		return 2
+	}
+	if cmd.Name == "ping" {
+		if err := ping(cmd.Socket, stdout); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	client := &adminClient{base: cmd.Addr, token: cmd.Token, http: &http.Client{Timeout: 10 * time.Second}}
+	if err := client.run(cmd, stdout); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// adminClient talks to the admin HTTP server of a running instance.
+type adminClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func (c *adminClient) run(cmd cliCommand, stdout io.Writer) error {
+	switch cmd.Name {
+	case "list":
+		var secrets []Secret
+		if err := c.getJSON("/api/secrets?reveal=true", &secrets); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tVERSION\tMODE\tSIZE")
+		for _, sec := range secrets {
+			fmt.Fprintf(tw, "%s\t%s\t%#o\t%d\n", sec.Name, sec.Version, sec.Mode, len(sec.Value))
+		}
+		return tw.Flush()
+	case "get":
+		var sec Secret
+		if err := c.getJSON("/api/secrets/"+url.PathEscape(cmd.Args[0]), &sec); err != nil {
+			return err
+		}
+		_, err := io.WriteString(stdout, sec.Value)
+		return err
+	case "set":
+		return c.postForm("/update", url.Values{
+			"name":    {cmd.Args[0]},
+			"value":   {cmd.Args[1]},
+			"version": {cmd.Version},
+			"mode":    {cmd.Mode},
+		})
+	case "delete":
+		return c.postForm("/delete", url.Values{"name": {cmd.Args[0]}})
+	}
+	return fmt.Errorf("unknown subcommand %q", cmd.Name)
+}
+
+// do sends req with the admin token, if any.
+func (c *adminClient) do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return client.Do(req)
+}
+
+func (c *adminClient) getJSON(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(c.http, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *adminClient) postForm(path string, form url.Values) error {
+	// Don't follow the redirect to the HTML index, a 303 is the success answer
+	client := *c.http
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	req, err := http.NewRequest(http.MethodPost, c.base+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.do(&client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusSeeOther {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// ping dials the provider socket and prints its Version answer, it's meant to be used as an
+// exec liveness probe or to check the socket is reachable from inside a pod.
+func ping(socket string, stdout io.Writer) error {
+	if socket == "" {
+		socket = os.Getenv("SOCKET_PATH")
+	}
+	if socket == "" {
+		socket = "/tmp/csi-debugger.sock"
+	}
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", socket, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := v1alpha1.NewCSIDriverProviderClient(conn).Version(ctx, &v1alpha1.VersionRequest{Version: "v1alpha1"})
+	if err != nil {
+		return fmt.Errorf("calling Version on %s: %w", socket, err)
+	}
+	_, err = fmt.Fprintf(stdout, "version=%s runtime=%s runtimeVersion=%s\n", resp.GetVersion(), resp.GetRuntimeName(), resp.GetRuntimeVersion())
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCLIArgs(t *testing.T) {
+	cmd, err := parseCLIArgs([]string{"set", "-addr", "http://debugger:8090/", "-version", "v2", "-mode", "0600", "db.txt", "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cliCommand{Name: "set", Addr: "http://debugger:8090", Args: []string{"db.txt", "pw"}, Version: "v2", Mode: "0600"}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Errorf("parseCLIArgs = %+v, want %+v", cmd, want)
+	}
+
+	for _, args := range [][]string{
+		{"frobnicate"},
+		{"get"},
+		{"set", "only-name"},
+		{"list", "extra"},
+		{"set", "-mode", "rwx", "a", "b"},
+	} {
+		if _, err := parseCLIArgs(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestCLISetListRoundTrip(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	srv := newTestWebServer(t, store)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"set", "-addr", srv.URL, "-version", "v7", "-mode", "0600", "db.txt", "pw"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("set exited with %d: %s", code, stderr.String())
+	}
+	if sec, ok := store.Get("db.txt"); !ok || sec.Value != "pw" || sec.Version != "v7" || sec.Mode != 0o600 {
+		t.Fatalf("set did not store the expected secret: %+v", sec)
+	}
+
+	if code := runCLI([]string{"list", "-addr", srv.URL}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list exited with %d: %s", code, stderr.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "db.txt") || !strings.Contains(out, "v7") || !strings.Contains(out, "0600") {
+		t.Errorf("unexpected list output:\n%s", out)
+	}
+
+	stdout.Reset()
+	if code := runCLI([]string{"get", "-addr", srv.URL, "db.txt"}, &stdout, &stderr); code != 0 || stdout.String() != "pw" {
+		t.Errorf("get exited with %d and printed %q", code, stdout.String())
+	}
+
+	if code := runCLI([]string{"delete", "-addr", srv.URL, "db.txt"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("delete exited with %d: %s", code, stderr.String())
+	}
+	if code := runCLI([]string{"get", "-addr", srv.URL, "db.txt"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected get of a deleted secret to fail, exited with %d", code)
+	}
+}
+
+func TestCLIPing(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "provider.sock")
+	var stdout, stderr bytes.Buffer
+	if code := runCLI([]string{"ping", "-socket", socket}, &stdout, &stderr); code != 1 {
+		t.Errorf("ping without a server exited with %d, want 1", code)
+	}
+
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{}
+	srv := newGRPCServer(testLogger(), cfg, NewProviderServer(testLogger(), cfg, NewMemoryStore(testLogger(), cfg), NewMetrics("")))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	stderr.Reset()
+	if code := runCLI([]string{"ping", "-socket", socket}, &stdout, &stderr); code != 0 {
+		t.Fatalf("ping exited with %d: %s", code, stderr.String())
+	}
+	if out := stdout.String(); !strings.Contains(out, "runtime=csi-debugger") {
+		t.Errorf("unexpected ping output: %q", out)
+	}
+}
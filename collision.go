@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Collision policies, what an import does with a name that already exists.
+const (
+	CollisionOverwrite   = "overwrite"
+	CollisionSkip        = "skip"
+	CollisionError       = "error"
+	CollisionVersionBump = "version-bump"
+)
+
+func validCollisionPolicy(p string) bool {
+	switch p {
+	case CollisionOverwrite, CollisionSkip, CollisionError, CollisionVersionBump:
+		return true
+	}
+	return false
+}
+
+// ImportSummary reports what an import did with each name.
+type ImportSummary struct {
+	Created     []string `json:"created"`
+	Overwritten []string `json:"overwritten"`
+	Skipped     []string `json:"skipped"`
+	Bumped      []string `json:"bumped"`
+}
+
+// Written returns the names the import stored or bumped.
+func (s ImportSummary) Written() []string {
+	names := make([]string, 0, len(s.Created)+len(s.Overwritten)+len(s.Bumped))
+	names = append(names, s.Created...)
+	names = append(names, s.Overwritten...)
+	return append(names, s.Bumped...)
+}
+
+// bumpVersion increments the trailing number of version, v1 becomes v2, a version
+// without one gets a "-1" suffix.
+func bumpVersion(version string) string {
+	i := len(version)
+	for i > 0 && version[i-1] >= '0' && version[i-1] <= '9' {
+		i--
+	}
+	n, err := strconv.ParseUint(version[i:], 10, 64)
+	if err != nil {
+		return version + "-1"
+	}
+	return version[:i] + strconv.FormatUint(n+1, 10)
+}
+
+// Import stores secrets in a single change like Transaction, a name already in the store,
+// once normalized, or earlier in secrets is handled according to policy:
+// overwritten, skipped, kept with its version bumped, or failing the whole import.
+func (s *MemoryStore) Import(secrets []Secret, policy string) (ImportSummary, error) {
+	if err := checkImport(secrets, policy); err != nil {
+		return ImportSummary{Created: []string{}, Overwritten: []string{}, Skipped: []string{}, Bumped: []string{}}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summary, err := s.importLocked(secrets, policy, nil)
+	if len(summary.Written()) > 0 {
+		s.notifyLocked()
+	}
+	return summary, err
+}
+
+// checkImport validates the arguments of Import.
+func checkImport(secrets []Secret, policy string) error {
+	if !validCollisionPolicy(policy) {
+		return invalid(reasonInvalid, fmt.Errorf("unknown collision policy %q", policy))
+	}
+	for _, sec := range secrets {
+		if err := validateSecret(sec); err != nil {
+			return fmt.Errorf("secret %q: %w", sec.Name, err)
+		}
+	}
+	return nil
+}
+
+// importLocked applies Import once checkImport passed, it doesn't notify. checkVersion,
+// when not nil, vets the versions version-bump generates.
+func (s *MemoryStore) importLocked(secrets []Secret, policy string, checkVersion func(string) error) (ImportSummary, error) {
+	summary, staged, err := s.planImportLocked(secrets, policy, checkVersion)
+	if err != nil {
+		return summary, err
+	}
+	for _, sec := range staged {
+		s.putLocked(sec)
+	}
+	s.logger.Info("import committed", "policy", policy, "created", len(summary.Created),
+		"overwritten", len(summary.Overwritten), "skipped", len(summary.Skipped), "bumped", len(summary.Bumped))
+	return summary, nil
+}
+
+// planImportLocked works out what importLocked does with secrets under policy without
+// touching the store, staged holds the secrets to write in order. Dry runs report the
+// summary alone.
+func (s *MemoryStore) planImportLocked(secrets []Secret, policy string, checkVersion func(string) error) (summary ImportSummary, staged []Secret, err error) {
+	summary = ImportSummary{Created: []string{}, Overwritten: []string{}, Skipped: []string{}, Bumped: []string{}}
+	byName := make(map[string]Secret, len(secrets))
+	order := make([]string, 0, len(secrets))
+	for _, sec := range secrets {
+		name := s.key(sec.Name)
+		cur, collision := byName[name]
+		if !collision {
+			cur, collision = s.secrets[name]
+		}
+		if !collision {
+			summary.Created = append(summary.Created, name)
+		} else {
+			switch policy {
+			case CollisionError:
+				return ImportSummary{}, nil, invalid(reasonCollision, fmt.Errorf("secret %q already exists", name))
+			case CollisionSkip:
+				summary.Skipped = append(summary.Skipped, name)
+				continue
+			case CollisionVersionBump:
+				loaded, err := s.loadLocked(cur)
+				if err != nil {
+					return ImportSummary{}, nil, err
+				}
+				// Bumped past the imported version too, so the version never orders backwards
+				latest := cur.Version
+				if compareVersions(sec.Version, latest) > 0 {
+					latest = sec.Version
+				}
+				sec = loaded
+				sec.Version = bumpVersion(latest)
+				if checkVersion != nil {
+					if err := checkVersion(sec.Version); err != nil {
+						return ImportSummary{}, nil, fmt.Errorf("secret %q: bumped %w", name, err)
+					}
+				}
+				summary.Bumped = append(summary.Bumped, name)
+			default:
+				summary.Overwritten = append(summary.Overwritten, name)
+			}
+		}
+		if _, ok := byName[name]; !ok {
+			order = append(order, name)
+		}
+		byName[name] = sec
+	}
+	for _, name := range order {
+		staged = append(staged, byName[name])
+	}
+	return summary, staged, nil
+}
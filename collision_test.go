@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBumpVersion(t *testing.T) {
+	tests := map[string]string{
+		"v1":     "v2",
+		"v9":     "v10",
+		"2024.7": "2024.8",
+		"latest": "latest-1",
+		"":       "-1",
+	}
+	for in, want := range tests {
+		if got := bumpVersion(in); got != want {
+			t.Errorf("bumpVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestImportCollisionPolicy(t *testing.T) {
+	newStore := func() *MemoryStore {
+		store := NewMemoryStore(testLogger(), Config{})
+		store.Set("a.txt", "old", "v1", 420)
+		return store
+	}
+	batch := []Secret{
+		{Name: "a.txt", Value: "new", Version: "v5", Mode: 420},
+		{Name: "b.txt", Value: "b", Version: "v5", Mode: 420},
+	}
+
+	tests := map[string]struct {
+		summary      ImportSummary
+		value, vers  string
+		wantB, fails bool
+	}{
+		CollisionOverwrite:   {ImportSummary{Created: []string{"b.txt"}, Overwritten: []string{"a.txt"}, Skipped: []string{}, Bumped: []string{}}, "new", "v5", true, false},
+		CollisionSkip:        {ImportSummary{Created: []string{"b.txt"}, Overwritten: []string{}, Skipped: []string{"a.txt"}, Bumped: []string{}}, "old", "v1", true, false},
+		CollisionVersionBump: {ImportSummary{Created: []string{"b.txt"}, Overwritten: []string{}, Skipped: []string{}, Bumped: []string{"a.txt"}}, "old", "v6", true, false},
+		CollisionError:       {ImportSummary{}, "old", "v1", false, true},
+	}
+	for policy, tt := range tests {
+		store := newStore()
+		gen := store.Generation()
+		summary, err := store.Import(batch, policy)
+		if tt.fails {
+			if rejectionReason(err) != reasonCollision {
+				t.Errorf("%s: expected a collision error, got %v", policy, err)
+			}
+			if store.Generation() != gen {
+				t.Errorf("%s: a failed import must not change the store", policy)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		if !reflect.DeepEqual(summary, tt.summary) {
+			t.Errorf("%s: unexpected summary %+v", policy, summary)
+		}
+		if sec, _ := store.Get("a.txt"); sec.Value != tt.value || sec.Version != tt.vers {
+			t.Errorf("%s: expected a.txt %s at %s, got %+v", policy, tt.value, tt.vers, sec)
+		}
+		if _, ok := store.Get("b.txt"); ok != tt.wantB {
+			t.Errorf("%s: b.txt present %v, want %v", policy, ok, tt.wantB)
+		}
+	}
+
+	if _, err := newStore().Import(batch, "merge"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestImportCollisionPolicyEndpoints(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("TOKEN", "old", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+
+	rec := httptest.NewRecorder()
+	web.handleImportDotenv(rec, httptest.NewRequest(http.MethodPost, "/api/import/dotenv?collisionPolicy=skip", strings.NewReader("TOKEN=new\nOTHER=x\n")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Imported   []string      `json:"imported"`
+		Collisions ImportSummary `json:"collisions"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resp.Imported, []string{"OTHER"}) || !reflect.DeepEqual(resp.Collisions.Skipped, []string{"TOKEN"}) {
+		t.Errorf("unexpected dotenv import response %+v", resp)
+	}
+
+	form := url.Values{"json_data": {`[{"name":"TOKEN","value":"bulk"}]`}, "collisionPolicy": {"error"}}
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	web.handleBulk(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a collision with the error policy, got %d", rec.Code)
+	}
+
+	form.Set("collisionPolicy", "version-bump")
+	req = httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	web.handleBulk(rec, req)
+	var summary ImportSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("expected a JSON summary, got %d %s", rec.Code, rec.Body)
+	}
+	if sec, _ := store.Get("TOKEN"); !reflect.DeepEqual(summary.Bumped, []string{"TOKEN"}) || sec.Value != "old" || sec.Version != "v2" {
+		t.Errorf("unexpected bump %+v, secret %+v", summary, sec)
+	}
+}
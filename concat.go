@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// buildConcatFile merges files into a single file named name holding one name=value line
+// per file, in the order of files. Values spanning lines or with quotes are double quoted
+// with escapes, the format the dotenv import reads back.
+func buildConcatFile(name string, files []*v1alpha1.File) (*v1alpha1.File, *v1alpha1.ObjectVersion) {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f.GetPath())
+		b.WriteByte('=')
+		b.WriteString(quoteConcatValue(string(f.GetContents())))
+		b.WriteByte('\n')
+	}
+	contents := []byte(b.String())
+	sum := sha256.Sum256(contents)
+	return &v1alpha1.File{Path: name, Mode: 0o444, Contents: contents},
+		&v1alpha1.ObjectVersion{Id: name, Version: hex.EncodeToString(sum[:8])}
+}
+
+func quoteConcatValue(v string) string {
+	if !strings.ContainsAny(v, "\n\r\"'#\\") && strings.TrimSpace(v) == v {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(v) + `"`
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encodings of submitted values, base64 carries binary content such as keys through the
+// text form and JSON, it is decoded before storing.
+const (
+	ContentEncodingPlain  = "plain"
+	ContentEncodingBase64 = "base64"
+)
+
+// decodeContent returns the raw value of v submitted with encoding, "" meaning plain.
+// Whitespace is ignored in base64, so wrapped output can be pasted as is.
+func decodeContent(v, encoding string) (string, error) {
+	switch encoding {
+	case "", ContentEncodingPlain:
+		return v, nil
+	case ContentEncodingBase64:
+		b, err := base64.StdEncoding.DecodeString(strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, v))
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 content: %w", err)
+		}
+		return string(b), nil
+	}
+	return "", fmt.Errorf("unknown content encoding %q", encoding)
+}
+
+// Binary reports whether the stored value isn't valid UTF-8 text.
+func (sec Secret) Binary() bool {
+	return !utf8.ValidString(sec.Value)
+}
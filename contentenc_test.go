@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDecodeContent(t *testing.T) {
+	tests := []struct {
+		value, encoding, want string
+		ok                    bool
+	}{
+		{"hello", "", "hello", true},
+		{"hello", ContentEncodingPlain, "hello", true},
+		{"AAH/\n/g==", ContentEncodingBase64, "\x00\x01\xff\xfe", true},
+		{"not base64!", ContentEncodingBase64, "", false},
+		{"hello", "hex", "", false},
+	}
+	for _, tt := range tests {
+		got, err := decodeContent(tt.value, tt.encoding)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("decodeContent(%q, %q) = %q, %v", tt.value, tt.encoding, got, err)
+		}
+	}
+}
+
+func TestBinaryContent(t *testing.T) {
+	raw := []byte{0x30, 0x82, 0x01, 0xff, 0x00, 0xc3}
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	post := func(path string, form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("/update", url.Values{"name": {"form.der"}, "value": {"MIIB/wDD"}, "version": {"v1"}, "content_encoding": {"base64"}}); code != http.StatusSeeOther {
+		t.Fatalf("form: expected 303, got %d", code)
+	}
+	if code := post("/bulk", url.Values{"json_data": {`[{"name":"bulk.der","value":"MIIB/wDD","contentEncoding":"base64"}]`}}); code != http.StatusSeeOther {
+		t.Fatalf("bulk: expected 303, got %d", code)
+	}
+	files, _, _ := store.GetFiles(nil)
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+	for _, f := range files {
+		if !bytes.Equal(f.Contents, raw) {
+			t.Errorf("%s: mounted %x, want %x", f.Path, f.Contents, raw)
+		}
+	}
+
+	if code := post("/update", url.Values{"name": {"bad"}, "value": {"%%%"}, "content_encoding": {"base64"}}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid base64, got %d", code)
+	}
+	if code := post("/bulk", url.Values{"json_data": {`[{"name":"bad","value":"x","contentEncoding":"rot13"}]`}}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown content encoding, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	web.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "(binary, 6 bytes)") {
+		t.Errorf("expected the binary placeholder in the UI:\n%s", body)
+	}
+}
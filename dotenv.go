@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// dotenvEntry is a KEY=VALUE line of a dotenv file.
+type dotenvEntry struct {
+	Key, Value string
+}
+
+// parseDotenv parses dotenv content: blank lines and # comments are skipped, an optional
+// "export " prefix is dropped, single quoted values are literal, double quoted values
+// support \n, \r, \t, \" and \\ escapes, and unquoted values end at an inline " #" comment.
+func parseDotenv(content string) ([]dotenvEntry, error) {
+	var entries []dotenvEntry
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value, err := parseDotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, dotenvEntry{Key: key, Value: value})
+	}
+	return entries, sc.Err()
+}
+
+func parseDotenvValue(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	switch quote := v[0]; quote {
+	case '\'':
+		end := strings.IndexByte(v[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quoted value")
+		}
+		return v[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(v); i++ {
+			switch c := v[i]; {
+			case c == '"':
+				return b.String(), nil
+			case c == '\\' && i+1 < len(v):
+				i++
+				switch v[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(v[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quoted value")
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, nil
+}
+
+// dotenvPath maps a dotenv key to a secret name, replacing DOTENV_PATH_SEPARATOR with "/",
+// e.g. DB__PASSWORD becomes DB/PASSWORD with "__".
+func dotenvPath(key, sep string) string {
+	if sep == "" {
+		return key
+	}
+	return strings.ReplaceAll(key, sep, "/")
+}
+
+// ImportDotenv stores every entry of the dotenv content as a secret in a single change,
+// existing names are handled according to the collision policy.
+func (s *MemoryStore) ImportDotenv(content, version, policy string) (ImportSummary, error) {
+	secrets, err := s.dotenvSecrets(content, version)
+	if err != nil {
+		return ImportSummary{}, err
+	}
+	return s.Import(secrets, policy)
+}
+
+// dotenvSecrets returns the secrets ImportDotenv stores for content.
+func (s *MemoryStore) dotenvSecrets(content, version string) ([]Secret, error) {
+	entries, err := parseDotenv(content)
+	if err != nil {
+		return nil, invalid(reasonDotenv, err)
+	}
+	secrets := make([]Secret, 0, len(entries))
+	for _, e := range entries {
+		secrets = append(secrets, Secret{Name: dotenvPath(e.Key, s.cfg.DotenvPathSeparator), Value: e.Value, Version: version, Mode: 420})
+	}
+	return secrets, nil
+}
+
+// handleImportDotenv imports the dotenv request body, with the version query parameter
+// (default v1) as the version of every secret and collisionPolicy (default overwrite)
+// deciding what happens to existing names.
+func (w *WebServer) handleImportDotenv(rw http.ResponseWriter, r *http.Request) {
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = "v1"
+	}
+	if err := w.checkVersion(version); err != nil {
+		w.rejectJSON(rw, reasonVersion, err.Error())
+		return
+	}
+	policy := r.URL.Query().Get("collisionPolicy")
+	if policy == "" {
+		policy = CollisionOverwrite
+	}
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, "failed to read body")
+		return
+	}
+	secrets, err := w.store.dotenvSecrets(string(content), version)
+	if err == nil {
+		err = checkImport(secrets, policy)
+	}
+	if err != nil {
+		w.rejectJSON(rw, rejectionReason(err), err.Error())
+		return
+	}
+	var summary ImportSummary
+	var importErr error
+	err = w.store.Owned(ownerToken(r), fixedNames(secretNames(secrets)...), func() bool {
+		summary, importErr = w.store.importLocked(secrets, policy, w.checkVersion)
+		return len(summary.Written()) > 0
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if importErr != nil {
+		w.rejectJSON(rw, rejectionReason(importErr), importErr.Error())
+		return
+	}
+	names := summary.Written()
+	w.logger.Info("Dotenv imported via API", "count", len(names), "version", version, "policy", policy)
+	w.writeJSON(rw, http.StatusOK, struct {
+		Imported   []string      `json:"imported"`
+		Collisions ImportSummary `json:"collisions"`
+	}{names, summary})
+}
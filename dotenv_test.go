@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	content := `
+# database settings
+DB_USER=admin
+export DB_PASSWORD="p@ss \"quoted\"\nline2"
+
+DB_HOST = db.local # inline comment
+RAW='single #not a comment \n'
+EMPTY=
+URL=http://x/#frag
+`
+	got, err := parseDotenv(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []dotenvEntry{
+		{"DB_USER", "admin"},
+		{"DB_PASSWORD", "p@ss \"quoted\"\nline2"},
+		{"DB_HOST", "db.local"},
+		{"RAW", `single #not a comment \n`},
+		{"EMPTY", ""},
+		{"URL", "http://x/#frag"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDotenv =\n%q\nwant\n%q", got, want)
+	}
+
+	for _, bad := range []string{"NOVALUE", `A="unterminated`, "A='unterminated", "=value"} {
+		if _, err := parseDotenv(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestImportDotenv(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{DotenvPathSeparator: "__"})
+	web := newTestWeb(t, Config{}, store)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/import/dotenv?version=v3", strings.NewReader("DB__PASSWORD=secret\nTOKEN='abc'\n"))
+	web.handleImportDotenv(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if sec, ok := store.Get("DB/PASSWORD"); !ok || sec.Value != "secret" || sec.Version != "v3" {
+		t.Errorf("unexpected DB/PASSWORD secret: %+v", sec)
+	}
+	if sec, ok := store.Get("TOKEN"); !ok || sec.Value != "abc" {
+		t.Errorf("unexpected TOKEN secret: %+v", sec)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"unicode/utf16"
+)
+
+// Encodings of the served file content, the default "" serves the raw bytes.
+const (
+	EncodingUTF8    = "utf8"
+	EncodingUTF8BOM = "utf8-bom"
+	// EncodingUTF16LE is little endian UTF-16 with its BOM, as written by Windows tools
+	EncodingUTF16LE = "utf16le"
+)
+
+// Line endings the served content can be normalized to, the default "" leaves them alone.
+const (
+	LineEndingLF   = "lf"
+	LineEndingCRLF = "crlf"
+)
+
+var (
+	encodings   = []string{EncodingUTF8, EncodingUTF8BOM, EncodingUTF16LE}
+	lineEndings = []string{LineEndingLF, LineEndingCRLF}
+)
+
+func validEncoding(e string) bool {
+	return e == "" || slices.Contains(encodings, e)
+}
+
+func validLineEnding(l string) bool {
+	return l == "" || slices.Contains(lineEndings, l)
+}
+
+// encodeContents normalizes the line endings of b then encodes it.
+func encodeContents(b []byte, encoding, lineEnding string) []byte {
+	switch lineEnding {
+	case LineEndingLF:
+		b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	case LineEndingCRLF:
+		b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+		b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+	}
+
+	switch encoding {
+	case EncodingUTF8BOM:
+		if !bytes.HasPrefix(b, []byte("\xef\xbb\xbf")) {
+			b = append([]byte("\xef\xbb\xbf"), b...)
+		}
+	case EncodingUTF16LE:
+		units := utf16.Encode(bytes.Runes(b))
+		out := make([]byte, 2, 2+2*len(units))
+		binary.LittleEndian.PutUint16(out, 0xfeff)
+		for _, u := range units {
+			out = binary.LittleEndian.AppendUint16(out, u)
+		}
+		b = out
+	}
+	return b
+}
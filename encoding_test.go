@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeContents(t *testing.T) {
+	tests := []struct {
+		in, encoding, lineEnding string
+		want                     []byte
+	}{
+		{"a\nb", "", "", []byte("a\nb")},
+		{"a\r\nb\n", "", LineEndingLF, []byte("a\nb\n")},
+		{"a\r\nb\n", "", LineEndingCRLF, []byte("a\r\nb\r\n")},
+		{"a\n", EncodingUTF8, "", []byte("a\n")},
+		{"a\n", EncodingUTF8BOM, LineEndingCRLF, []byte("\xef\xbb\xbfa\r\n")},
+		{"\xef\xbb\xbfa", EncodingUTF8BOM, "", []byte("\xef\xbb\xbfa")},
+		{"hé\n", EncodingUTF16LE, LineEndingCRLF, []byte{0xff, 0xfe, 'h', 0, 0xe9, 0, '\r', 0, '\n', 0}},
+	}
+	for _, tt := range tests {
+		if got := encodeContents([]byte(tt.in), tt.encoding, tt.lineEnding); !bytes.Equal(got, tt.want) {
+			t.Errorf("encodeContents(%q, %q, %q) = %q, want %q", tt.in, tt.encoding, tt.lineEnding, got, tt.want)
+		}
+	}
+}
+
+func TestGetFilesEncoding(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "win.txt", Value: "line1\nline2\n", Version: "v1", Mode: 420, Encoding: EncodingUTF8BOM, LineEnding: LineEndingCRLF})
+	store.Put(Secret{Name: "raw.txt", Value: "line1\nline2\n", Version: "v1", Mode: 420})
+
+	files, _, _ := store.GetFiles(nil)
+	got := map[string][]byte{}
+	for _, f := range files {
+		got[f.Path] = f.Contents
+	}
+	if want := []byte("\xef\xbb\xbfline1\r\nline2\r\n"); !bytes.Equal(got["win.txt"], want) {
+		t.Errorf("win.txt = %q, want %q", got["win.txt"], want)
+	}
+	if want := []byte("line1\nline2\n"); !bytes.Equal(got["raw.txt"], want) {
+		t.Errorf("raw.txt = %q, want raw %q", got["raw.txt"], want)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Secret types, selecting where the mounted content comes from.
+const (
+	SecretTypeStatic   = "static"
+	SecretTypeFileRef  = "fileref"
+	SecretTypeHTTPRef  = "httpref"
+	SecretTypeVariants = "variants"
+)
+
+func validSecretType(t string) bool {
+	return t == "" || t == SecretTypeStatic || t == SecretTypeFileRef || t == SecretTypeHTTPRef || t == SecretTypeVariants
+}
+
+// readFileRef reads the node file at path, which must resolve inside root even through
+// symlinks, refusing files larger than maxBytes.
+func readFileRef(root, path string, maxBytes int64) ([]byte, error) {
+	if root == "" {
+		return nil, errors.New("fileref secrets are disabled, FILEREF_ROOT is not set")
+	}
+	rel := path
+	if filepath.IsAbs(path) {
+		var err error
+		if rel, err = filepath.Rel(root, path); err != nil {
+			return nil, err
+		}
+	}
+
+	// os.Root refuses any path, including through symlinks, escaping root
+	f, err := os.OpenInRoot(root, rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxBytes {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxBytes)
+	}
+	return b, nil
+}
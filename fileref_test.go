@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileRefLiveContent(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "live.txt")
+	if err := os.WriteFile(target, []byte("first"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemoryStore(testLogger(), Config{FileRefRoot: root, FileRefMaxBytes: 16})
+	store.Put(Secret{Name: "live.txt", Value: target, Version: "v1", Type: SecretTypeFileRef})
+
+	mount := func() string {
+		files, _, _ := store.GetFiles(nil)
+		if len(files) != 1 {
+			t.Fatalf("expected 1 file, got %d", len(files))
+		}
+		return string(files[0].Contents)
+	}
+	if got := mount(); got != "first" {
+		t.Errorf("first mount = %q", got)
+	}
+	if err := os.WriteFile(target, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := mount(); got != "second" {
+		t.Errorf("second mount = %q, expected fresh file content", got)
+	}
+}
+
+func TestReadFileRefGuards(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("nope"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "big"), make([]byte, 32), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(root, "escape"),
+		filepath.Join(root, "..", filepath.Base(outside), "secret"),
+		filepath.Join(root, "missing"),
+		filepath.Join(root, "big"),
+	} {
+		if _, err := readFileRef(root, path, 16); err == nil {
+			t.Errorf("expected reading %s to fail", path)
+		}
+	}
+	if _, err := readFileRef("", filepath.Join(root, "big"), 64); err == nil {
+		t.Error("expected fileref to be disabled without a root")
+	}
+
+	store := NewMemoryStore(testLogger(), Config{FileRefRoot: root, FileRefMaxBytes: 16})
+	store.Put(Secret{Name: "escape", Value: filepath.Join(root, "escape"), Type: SecretTypeFileRef})
+	store.Put(Secret{Name: "ok.txt", Value: "static"})
+	if files, _, _ := store.GetFiles(nil); len(files) != 1 || files[0].Path != "ok.txt" {
+		t.Errorf("expected the unreadable fileref to be skipped, got %d files", len(files))
+	}
+}
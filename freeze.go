@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Frozen reports whether the store rejects mutations made through the admin server.
+func (s *MemoryStore) Frozen() bool {
+	return s.frozen.Load()
+}
+
+// SetFrozen freezes or unfreezes the store.
+func (s *MemoryStore) SetFrozen(frozen bool) {
+	s.frozen.Store(frozen)
+}
+
+// mutating wraps a handler changing the store so it answers 423 Locked while frozen.
+func (w *WebServer) mutating(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if w.store.Frozen() {
+			w.rejectFrozen(rw, r)
+			return
+		}
+		next(rw, r)
+	}
+}
+
+// mutatingDryRun is mutating for handlers implementing dryRun=true, which doesn't
+// change anything and is let through while frozen.
+func (w *WebServer) mutatingDryRun(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun")); w.store.Frozen() && !dryRun {
+			w.rejectFrozen(rw, r)
+			return
+		}
+		next(rw, r)
+	}
+}
+
+func (w *WebServer) rejectFrozen(rw http.ResponseWriter, r *http.Request) {
+	w.logger.Warn("Mutation rejected, store is frozen", "method", r.Method, "path", r.URL.Path)
+	w.writeJSONError(rw, http.StatusLocked, "store is frozen")
+}
+
+type freezeState struct {
+	Frozen bool `json:"frozen"`
+}
+
+func (w *WebServer) handleFreezeState(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, freezeState{Frozen: w.store.Frozen()})
+}
+
+func (w *WebServer) handleFreeze(rw http.ResponseWriter, r *http.Request) {
+	w.store.SetFrozen(true)
+	w.logger.Info("Store frozen via API")
+	w.writeJSON(rw, http.StatusOK, freezeState{Frozen: true})
+}
+
+func (w *WebServer) handleUnfreeze(rw http.ResponseWriter, r *http.Request) {
+	w.store.SetFrozen(false)
+	w.logger.Info("Store unfrozen via API")
+	w.writeJSON(rw, http.StatusOK, freezeState{Frozen: false})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestFreezeRejectsMutations(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "v", "v1", 420)
+	srv := newTestWebServer(t, store)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	post := func(path string, form url.Values) int {
+		t.Helper()
+		resp, err := client.PostForm(srv.URL+path, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("/api/freeze", nil); code != http.StatusOK {
+		t.Fatalf("freeze: expected 200, got %d", code)
+	}
+	if !store.Frozen() {
+		t.Fatal("expected the store to be frozen")
+	}
+
+	mutations := map[string]url.Values{
+		"/update":                    {"name": {"new.txt"}, "value": {"x"}},
+		"/delete":                    {"name": {"app.txt"}},
+		"/bulk":                      {"json_data": {`[{"name":"b.txt","value":"x"}]`}},
+		"/api/secrets/app.txt/scope": {},
+		"/clear?dryRun=true":         {"confirm": {"yes"}},
+		"/api/secrets?dryRun=true":   {},
+	}
+	for path, form := range mutations {
+		if code := post(path, form); code != http.StatusLocked {
+			t.Errorf("%s: expected 423 while frozen, got %d", path, code)
+		}
+	}
+	if list := store.List(); len(list) != 1 || list[0].Name != "app.txt" || list[0].ServiceAccount != "" {
+		t.Errorf("store changed while frozen: %+v", list)
+	}
+
+	// Only /bulk implements dryRun, which then leaves the store untouched
+	if code := post("/bulk?dryRun=true", mutations["/bulk"]); code != http.StatusOK {
+		t.Errorf("bulk dry run: expected 200 while frozen, got %d", code)
+	}
+	if store.Len() != 1 {
+		t.Errorf("bulk dry run changed the store: %d secrets", store.Len())
+	}
+
+	resp, err := http.Get(srv.URL + "/api/secrets/app.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("reads should keep working while frozen, got %d", resp.StatusCode)
+	}
+
+	post("/api/unfreeze", nil)
+	if code := post("/delete", url.Values{"name": {"app.txt"}}); code != http.StatusSeeOther {
+		t.Errorf("expected delete to succeed after unfreeze, got %d", code)
+	}
+	if n := len(store.List()); n != 0 {
+		t.Errorf("expected the secret to be deleted, %d left", n)
+	}
+}
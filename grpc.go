@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// CompressionNone disables server side compression of responses.
+const CompressionNone = "none"
+
+// compressions lists the valid GRPC_COMPRESSION values, importing the gzip package
+// registers its compressor.
+var compressions = []string{CompressionNone, gzip.Name}
+
+func validCompression(c string) bool {
+	return slices.Contains(compressions, c)
+}
+
+// deadlineInterceptor applies a default deadline to incoming RPCs whose context has none,
+// bounding how long a call like Mount can run when the driver does not set a timeout.
+// A zero timeout disables the interceptor.
+func deadlineInterceptor(logger *slog.Logger, timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if timeout <= 0 {
+			return handler(ctx, req)
+		}
+		if _, ok := ctx.Deadline(); ok {
+			return handler(ctx, req)
+		}
+		logger.Debug("applying default deadline", "method", info.FullMethod, "timeout", timeout)
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// requestIDMetadata is the gRPC metadata key of the correlation ID of a call, taken
+// from the caller when set and echoed in the response header.
+const requestIDMetadata = "x-request-id"
+
+// accessLogInterceptor logs every call once answered, with its status code, duration and
+// a correlation ID.
+func accessLogInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		id := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get(requestIDMetadata); len(v) > 0 {
+				id = v[0]
+			}
+		}
+		if id == "" {
+			id = newUUID()
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id)); err != nil {
+			logger.Debug("failed to set the request ID header", "method", info.FullMethod, "error", err)
+		}
+		resp, err := handler(ctx, req)
+		logger.Info("gRPC call",
+			"request_id", id,
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"duration", time.Since(start),
+		)
+		return resp, err
+	}
+}
+
+// recoveryInterceptor turns a panicking handler into a codes.Internal error instead of
+// crashing the provider, logging the stack trace.
+func recoveryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.Error("recovered from panic in gRPC handler",
+					"method", info.FullMethod,
+					"panic", p,
+					"stack", string(debug.Stack()),
+				)
+				err = status.Errorf(codes.Internal, "panic in %s: %v", info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// compressionInterceptor compresses responses with the named compressor when the client
+// advertises support for it in grpc-accept-encoding. CompressionNone disables the interceptor.
+func compressionInterceptor(logger *slog.Logger, name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if name == "" || name == CompressionNone {
+			return handler(ctx, req)
+		}
+		supported, err := grpc.ClientSupportedCompressors(ctx)
+		if err != nil || !slices.Contains(supported, name) {
+			return handler(ctx, req)
+		}
+		if err := grpc.SetSendCompressor(ctx, name); err != nil {
+			logger.Warn("failed to set response compressor", "method", info.FullMethod, "compressor", name, "error", err)
+		}
+		return handler(ctx, req)
+	}
+}
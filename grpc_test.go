@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestDeadlineInterceptor(t *testing.T) {
+	interceptor := deadlineInterceptor(testLogger(), time.Minute)
+	info := &grpc.UnaryServerInfo{FullMethod: "/v1alpha1.CSIDriverProvider/Mount"}
+
+	var got time.Time
+	var hasDeadline bool
+	handler := func(ctx context.Context, req any) (any, error) {
+		got, hasDeadline = ctx.Deadline()
+		return nil, nil
+	}
+
+	t.Run("without inbound deadline", func(t *testing.T) {
+		start := time.Now()
+		if _, err := interceptor(context.Background(), nil, info, handler); err != nil {
+			t.Fatal(err)
+		}
+		if !hasDeadline {
+			t.Fatal("expected default deadline to be applied")
+		}
+		if d := got.Sub(start); d < 59*time.Second || d > 61*time.Second {
+			t.Errorf("unexpected default deadline distance %s", d)
+		}
+	})
+
+	t.Run("with inbound deadline", func(t *testing.T) {
+		want := time.Now().Add(5 * time.Second)
+		ctx, cancel := context.WithDeadline(context.Background(), want)
+		defer cancel()
+		if _, err := interceptor(ctx, nil, info, handler); err != nil {
+			t.Fatal(err)
+		}
+		if !hasDeadline || !got.Equal(want) {
+			t.Errorf("expected inbound deadline %s to be kept, got %s", want, got)
+		}
+	})
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	interceptor := recoveryInterceptor(testLogger())
+	info := &grpc.UnaryServerInfo{FullMethod: "/v1alpha1.CSIDriverProvider/Mount"}
+
+	_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected codes.Internal after panic, got %v", err)
+	}
+}
+
+func TestAccessLogInterceptor(t *testing.T) {
+	var logs strings.Builder
+	interceptor := accessLogInterceptor(slog.New(slog.NewTextHandler(&logs, nil)))
+	info := &grpc.UnaryServerInfo{FullMethod: "/v1alpha1.CSIDriverProvider/Mount"}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDMetadata, "corr-1"))
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected the handler error to be returned, got %v", err)
+	}
+	for _, want := range []string{"request_id=corr-1", "method=/v1alpha1.CSIDriverProvider/Mount", "code=NotFound", "duration="} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("access log %q lacks %q", logs.String(), want)
+		}
+	}
+}
+
+// payloadSizes records the uncompressed and wire size of received messages.
+type payloadSizes struct {
+	length, wireLength int
+}
+
+func (p *payloadSizes) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context { return ctx }
+func (p *payloadSizes) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+func (p *payloadSizes) HandleConn(context.Context, stats.ConnStats) {}
+func (p *payloadSizes) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if in, ok := s.(*stats.InPayload); ok {
+		p.length, p.wireLength = in.Length, in.WireLength
+	}
+}
+
+func TestGRPCCompression(t *testing.T) {
+	value := strings.Repeat("compressible ", 4096)
+	for _, compression := range []string{gzip.Name, CompressionNone} {
+		t.Run(compression, func(t *testing.T) {
+			store := NewMemoryStore(testLogger(), Config{})
+			store.Set("big.txt", value, "v1", 420)
+			cfg := Config{GRPCCompression: compression}
+			srv := newGRPCServer(testLogger(), cfg, NewProviderServer(testLogger(), cfg, store, NewMetrics("")))
+
+			lis := bufconn.Listen(1 << 20)
+			go srv.Serve(lis)
+			t.Cleanup(srv.Stop)
+
+			// The client advertises gzip in grpc-accept-encoding since the package is imported,
+			// its own request stays uncompressed.
+			sizes := &payloadSizes{}
+			conn, err := grpc.NewClient("passthrough:///bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithStatsHandler(sizes),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			resp, err := v1alpha1.NewCSIDriverProviderClient(conn).Mount(context.Background(), &v1alpha1.MountRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Files) != 1 || string(resp.Files[0].Contents) != value {
+				t.Fatal("unexpected decompressed Mount response")
+			}
+			compressed := sizes.wireLength < sizes.length/10
+			if compressed != (compression == gzip.Name) {
+				t.Errorf("got %d bytes on the wire for %d with compression %s", sizes.wireLength, sizes.length, compression)
+			}
+		})
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		cfg := Config{GRPCReflection: enabled}
+		srv := newGRPCServer(testLogger(), cfg, NewProviderServer(testLogger(), cfg, NewMemoryStore(testLogger(), cfg), NewMetrics("")))
+		lis := bufconn.Listen(1 << 20)
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := stream.Recv()
+		if !enabled {
+			if status.Code(err) != codes.Unimplemented {
+				t.Errorf("expected Unimplemented without GRPC_REFLECTION, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var services []string
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			services = append(services, s.GetName())
+		}
+		if !slices.Contains(services, "v1alpha1.CSIDriverProvider") {
+			t.Errorf("expected the provider service to be listed, got %v", services)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// connTracker is a gRPC stats.Handler keeping track of the open connections, to spot a
+// driver churning connections.
+type connTracker struct {
+	mu     sync.Mutex
+	nextID uint64
+	conns  map[uint64]*trackedConn
+	now    func() time.Time
+}
+
+type trackedConn struct {
+	id           uint64
+	remoteAddr   string
+	opened       time.Time
+	lastActivity time.Time
+	rpcs         uint64
+}
+
+// ConnInfo describes an open gRPC connection.
+type ConnInfo struct {
+	ID           uint64    `json:"id"`
+	RemoteAddr   string    `json:"remoteAddr"`
+	OpenedAt     time.Time `json:"openedAt"`
+	Age          string    `json:"age"`
+	LastActivity time.Time `json:"lastActivity"`
+	RPCs         uint64    `json:"rpcs"`
+}
+
+// ConnsSummary is the answer of GET /api/grpc/conns.
+type ConnsSummary struct {
+	Count       int        `json:"count"`
+	Connections []ConnInfo `json:"connections"`
+}
+
+type connIDKey struct{}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[uint64]*trackedConn), now: time.Now}
+}
+
+func (c *connTracker) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	now := c.now()
+	remote := ""
+	if info.RemoteAddr != nil {
+		remote = info.RemoteAddr.String()
+	}
+	c.conns[c.nextID] = &trackedConn{id: c.nextID, remoteAddr: remote, opened: now, lastActivity: now}
+	return context.WithValue(ctx, connIDKey{}, c.nextID)
+}
+
+func (c *connTracker) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnEnd); !ok {
+		return
+	}
+	id, _ := ctx.Value(connIDKey{}).(uint64)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.conns, id)
+}
+
+func (c *connTracker) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC records the RPCs on their connection, the RPC context derives from the connection one.
+func (c *connTracker) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.Begin); !ok {
+		return
+	}
+	id, _ := ctx.Value(connIDKey{}).(uint64)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if conn, ok := c.conns[id]; ok {
+		conn.lastActivity = c.now()
+		conn.rpcs++
+	}
+}
+
+// Summary returns the open connections, oldest first.
+func (c *connTracker) Summary() ConnsSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	conns := make([]ConnInfo, 0, len(c.conns))
+	for _, conn := range c.conns {
+		conns = append(conns, ConnInfo{
+			ID:           conn.id,
+			RemoteAddr:   conn.remoteAddr,
+			OpenedAt:     conn.opened,
+			Age:          now.Sub(conn.opened).Round(time.Millisecond).String(),
+			LastActivity: conn.lastActivity,
+			RPCs:         conn.rpcs,
+		})
+	}
+	slices.SortFunc(conns, func(a, b ConnInfo) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return ConnsSummary{Count: len(conns), Connections: conns}
+}
+
+func (w *WebServer) handleGRPCConns(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, w.provider.conns.Summary())
+}
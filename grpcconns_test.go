@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestGRPCConns(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	srv := newGRPCServer(testLogger(), Config{}, web.provider)
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := v1alpha1.NewCSIDriverProviderClient(conn)
+	for range 2 {
+		if _, err := client.Version(context.Background(), &v1alpha1.VersionRequest{Version: "v1alpha1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	web.handleGRPCConns(rec, httptest.NewRequest(http.MethodGet, "/api/grpc/conns", nil))
+	var summary ConnsSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Count != 1 || len(summary.Connections) != 1 {
+		t.Fatalf("expected 1 open connection, got %+v", summary)
+	}
+	if c := summary.Connections[0]; c.RPCs != 2 || c.LastActivity.Before(c.OpenedAt) || c.Age == "" {
+		t.Errorf("unexpected connection info: %+v", c)
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for web.provider.conns.Summary().Count != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("closed connection still tracked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// seedPollInterval is how often the gRPC health status checks whether seeding is done.
+const seedPollInterval = 100 * time.Millisecond
+
+// registerHealth registers the standard gRPC health service on srv, for grpc_health_probe.
+// Both the server ("") and the provider service report NOT_SERVING until the store is
+// seeded, then SERVING until ctx is done.
+func registerHealth(ctx context.Context, logger *slog.Logger, srv *grpc.Server, store *MemoryStore) *health.Server {
+	hs := health.NewServer()
+	services := []string{"", v1alpha1.CSIDriverProvider_ServiceDesc.ServiceName}
+	for _, svc := range services {
+		hs.SetServingStatus(svc, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	healthpb.RegisterHealthServer(srv, hs)
+
+	go func() {
+		t := time.NewTicker(seedPollInterval)
+		defer t.Stop()
+		for store.Seeding() {
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		for _, svc := range services {
+			hs.SetServingStatus(svc, healthpb.HealthCheckResponse_SERVING)
+		}
+		logger.Info("gRPC health serving")
+	}()
+	return hs
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := Config{SocketPath: filepath.Join(t.TempDir(), "provider.sock")}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.seeding.Store(true)
+	provider := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+	done := make(chan error, 1)
+	go func() { done <- startGRPCServer(ctx, testLogger(), cfg, provider) }()
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(cfg.SocketPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("socket not created")
+		}
+	}
+	conn, err := grpc.NewClient("unix://"+cfg.SocketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func() healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetStatus()
+	}
+	if s := check(); s != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING while seeding, got %s", s)
+	}
+
+	store.seeding.Store(false)
+	for deadline := time.Now().Add(5 * time.Second); check() != healthpb.HealthCheckResponse_SERVING; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("health never turned SERVING after seeding")
+		}
+	}
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "v1alpha1.CSIDriverProvider"})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected the provider service SERVING, got %v %v", resp, err)
+	}
+
+	// A probe watching through the shutdown sees the drain
+	watch, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first, err := watch.Recv(); err != nil || first.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected SERVING first, got %v %v", first, err)
+	}
+	cancel()
+	if last, err := watch.Recv(); err != nil || last.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING on shutdown, got %v %v", last, err)
+	}
+	conn.Close()
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// flapper makes readiness cycle on a schedule: ready for the first duty fraction of
+// every period, not ready for the rest.
+type flapper struct {
+	period time.Duration
+	duty   float64
+	start  time.Time
+	logger *slog.Logger
+
+	// notReady is the last reported state, to log transitions
+	notReady atomic.Bool
+}
+
+func newFlapper(logger *slog.Logger, period time.Duration, duty float64) *flapper {
+	return &flapper{period: period, duty: duty, start: time.Now(), logger: logger}
+}
+
+// ready reports the scheduled readiness at now, always true when flapping is disabled.
+func (f *flapper) ready(now time.Time) bool {
+	if f.period <= 0 {
+		return true
+	}
+	phase := now.Sub(f.start) % f.period
+	ready := phase < time.Duration(f.duty*float64(f.period))
+	if f.notReady.Swap(!ready) != !ready {
+		f.logger.Info("readiness flapped", "ready", ready, "period", f.period, "duty", f.duty)
+	}
+	return ready
+}
+
+func (w *WebServer) handleHealthz(rw http.ResponseWriter, r *http.Request) {
+	io.WriteString(rw, "ok\n")
+}
+
+// sampleIndexData exercises every part of the admin template.
+var sampleIndexData = indexData{
+	Groups: []secretGroup{{
+		Bundle: "sample",
+		Secrets: []SecretView{{
+			Secret: Secret{
+				Name:        "sample.txt",
+				Value:       "sample value",
+				Version:     "v1",
+				Mode:        420,
+				Annotations: map[string]string{"ticket": "ABC-123"},
+				UpdatedAt:   time.Unix(0, 0),
+			},
+			Stale: true,
+		}},
+	}},
+	Query: "sample",
+}
+
+// checkTemplate renders the admin template against a sample secret, so a broken
+// TEMPLATE_PATH fails readiness instead of the first page load.
+func (w *WebServer) checkTemplate() error {
+	return w.tmpl.Execute(io.Discard, sampleIndexData)
+}
+
+// handleReadyz answers 503 while not ready.
+func (w *WebServer) handleReadyz(rw http.ResponseWriter, r *http.Request) {
+	if w.store.Seeding() {
+		http.Error(rw, "not ready: seeding (REQUIRE_SEED)", http.StatusServiceUnavailable)
+		return
+	}
+	if !w.flapper.ready(time.Now()) {
+		http.Error(rw, "not ready: flapping (READYZ_FLAP_PERIOD)", http.StatusServiceUnavailable)
+		return
+	}
+	if err := w.checkTemplate(); err != nil {
+		w.logger.Error("readiness check failed, admin template does not render", "error", err)
+		http.Error(rw, "not ready: template: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	io.WriteString(rw, "ok\n")
+}
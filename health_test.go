@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlapperSchedule(t *testing.T) {
+	f := newFlapper(testLogger(), 10*time.Second, 0.7)
+	tests := map[time.Duration]bool{
+		0:                true,
+		6 * time.Second:  true,
+		7 * time.Second:  false,
+		9 * time.Second:  false,
+		10 * time.Second: true,
+		17 * time.Second: false,
+	}
+	for offset, want := range tests {
+		if got := f.ready(f.start.Add(offset)); got != want {
+			t.Errorf("ready at +%v = %v, want %v", offset, got, want)
+		}
+	}
+
+	if !newFlapper(testLogger(), 0, 0).ready(time.Now()) {
+		t.Error("expected always ready with flapping disabled")
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	web := newTestWeb(t, Config{}, NewMemoryStore(testLogger(), Config{}))
+	rec := httptest.NewRecorder()
+	web.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+
+	web = newTestWeb(t, Config{ReadyzFlapPeriod: time.Hour, ReadyzFlapDuty: 0}, NewMemoryStore(testLogger(), Config{}))
+	rec = httptest.NewRecorder()
+	web.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 with a zero duty cycle, got %d", rec.Code)
+	}
+}
+
+func TestReadyzTemplate(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.html")
+	// Parses fine, fails at execution since indexData has no Missing field
+	if err := os.WriteFile(broken, []byte(`<p>{{.Missing}}</p>`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	web := newTestWeb(t, Config{TemplatePath: broken}, NewMemoryStore(testLogger(), Config{}))
+	rec := httptest.NewRecorder()
+	web.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "template") {
+		t.Errorf("expected 503 for a broken template, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	valid := filepath.Join(dir, "valid.html")
+	if err := os.WriteFile(valid, []byte(`{{range .Groups}}{{range .Secrets}}{{.Name}}{{end}}{{end}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	web = newTestWeb(t, Config{TemplatePath: valid}, NewMemoryStore(testLogger(), Config{}))
+	rec = httptest.NewRecorder()
+	web.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for a valid template, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hexBytesPerLine is the width of the hex dump shown in the editor.
+const hexBytesPerLine = 16
+
+// hexDump formats b as space separated hex bytes, hexBytesPerLine per line.
+func hexDump(b []byte) string {
+	var sb strings.Builder
+	for i, c := range b {
+		switch {
+		case i == 0:
+		case i%hexBytesPerLine == 0:
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%02x", c)
+	}
+	return sb.String()
+}
+
+// parseHexDump decodes a hex dump, whitespace between or within bytes is ignored.
+func parseHexDump(s string) ([]byte, error) {
+	digits := strings.Join(strings.Fields(s), "")
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		var invalid hex.InvalidByteError
+		if errors.As(err, &invalid) {
+			return nil, fmt.Errorf("invalid hex digit %q", rune(invalid))
+		}
+		return nil, fmt.Errorf("odd number of hex digits (%d), every byte needs two", len(digits))
+	}
+	return b, nil
+}
+
+var hexTmpl = template.Must(template.New("hex").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.Name}} - CSI Debugger hex editor</title>
+    <link rel="icon" href="/favicon.ico">
+    <link rel="stylesheet" href="/static/admin.css">
+</head>
+<body>
+    <h3>{{.Name}} ({{.Size}} bytes, version {{.Version}})</h3>
+    <p><a href="/">Back to the admin UI</a></p>
+    <form action="/hex?name={{.Name}}" method="POST">
+        <textarea name="hex" rows="20" cols="{{.Cols}}" spellcheck="false" style="font-family:monospace">{{.Dump}}</textarea>
+        <div class="form-group">
+            <label>Version (keeps the current one if empty)</label>
+            <input type="text" name="version" placeholder="{{.Version}}">
+        </div>
+        <div class="form-group">
+            <label>Owner token (required if the secret has an owner)</label>
+            <input type="password" name="owner_token">
+        </div>
+        <button type="submit">Save Bytes</button>
+    </form>
+</body>
+</html>
+`))
+
+type hexData struct {
+	Name, Version, Dump string
+	Size, Cols          int
+}
+
+// handleHexEditor shows the named secret as an editable hex dump.
+func (w *WebServer) handleHexEditor(rw http.ResponseWriter, r *http.Request) {
+	sec, ok := w.store.Get(r.URL.Query().Get("name"))
+	if !ok {
+		http.Error(rw, "Secret not found", http.StatusNotFound)
+		return
+	}
+	data := hexData{
+		Name:    sec.Name,
+		Version: sec.Version,
+		Dump:    hexDump([]byte(sec.Value)),
+		Size:    len(sec.Value),
+		Cols:    hexBytesPerLine * 3,
+	}
+	if err := hexTmpl.Execute(rw, data); err != nil {
+		w.logger.Error("failed to render hex editor", "error", err)
+	}
+}
+
+// handleHexSave replaces the value of the named secret with the decoded hex form field.
+func (w *WebServer) handleHexSave(rw http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	b, err := parseHexDump(r.FormValue("hex"))
+	if err != nil {
+		w.reject(rw, reasonHex, "Invalid hex: "+err.Error())
+		return
+	}
+	if err := w.checkSize(string(b)); err != nil {
+		w.reject(rw, reasonSize, err.Error())
+		return
+	}
+	version := r.FormValue("version")
+	if version != "" {
+		if err := w.checkVersion(version); err != nil {
+			w.reject(rw, reasonVersion, err.Error())
+			return
+		}
+	}
+	token := ownerToken(r)
+	sec, ok, err := w.store.UpdateChecked(name, func(sec *Secret) error {
+		sec.Value = string(b)
+		if version != "" {
+			sec.Version = version
+		}
+		return checkOwner(*sec, token)
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if !ok {
+		http.Error(rw, "Secret not found", http.StatusNotFound)
+		return
+	}
+	w.logger.Info("Secret bytes edited via hex editor", "name", sec.Name, "size", len(b), "version", sec.Version)
+	http.Redirect(rw, r, "/hex?name="+url.QueryEscape(sec.Name), http.StatusSeeOther)
+}
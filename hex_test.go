@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHexDumpRoundTrip(t *testing.T) {
+	in := make([]byte, 40)
+	for i := range in {
+		in[i] = byte(i * 7)
+	}
+	dump := hexDump(in)
+	if lines := strings.Count(dump, "\n") + 1; lines != 3 {
+		t.Errorf("expected 40 bytes on 3 lines, got %d:\n%s", lines, dump)
+	}
+	out, err := parseHexDump(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, in) {
+		t.Errorf("round trip = %x, want %x", out, in)
+	}
+
+	if b, err := parseHexDump(" DE ad\n\tbe ef "); err != nil || !bytes.Equal(b, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("parseHexDump with extra whitespace = %x, %v", b, err)
+	}
+	for _, bad := range []string{"zz", "abc", "0x12"} {
+		if _, err := parseHexDump(bad); err == nil {
+			t.Errorf("parseHexDump(%q): expected an error", bad)
+		}
+	}
+}
+
+func TestHexSave(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "bin", Value: "ab", Version: "v1", Mode: 0o600})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	post := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/hex?name=bin", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(url.Values{"hex": {"00 ff 1"}}); code != http.StatusBadRequest {
+		t.Errorf("malformed hex: expected 400, got %d", code)
+	}
+	if code := post(url.Values{"hex": {"00 ff 10"}, "version": {"v2"}}); code != http.StatusSeeOther {
+		t.Fatalf("save: expected 303, got %d", code)
+	}
+	sec, _ := store.Get("bin")
+	if sec.Value != "\x00\xff\x10" || sec.Version != "v2" || sec.Mode != 0o600 {
+		t.Errorf("unexpected secret after hex save: %+v", sec)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hex?name=bin", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "00 ff 10") {
+		t.Errorf("unexpected hex editor page (%d):\n%s", rec.Code, rec.Body.String())
+	}
+}
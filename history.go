@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// SecretVersion is a value a secret held before it was replaced.
+type SecretVersion struct {
+	Value     string    `json:"value"`
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// spillPath is the file holding Value when the replaced secret was spilled
+	spillPath string
+}
+
+// recordHistoryLocked appends old, the secret sec replaces, to the history of its name when
+// the value or version changes, keeping the last SECRET_HISTORY_DEPTH entries. A spilled
+// old value stays on disk, the entry takes over its spill file and recordHistoryLocked
+// reports whether it did, the caller must then not remove it.
+func (s *MemoryStore) recordHistoryLocked(sec, old Secret) (keptSpill bool) {
+	depth := s.cfg.SecretHistoryDepth
+	if depth <= 0 {
+		return false
+	}
+	if old.Version == sec.Version {
+		// Only the values can tell them apart
+		prev, err := s.loadLocked(old)
+		if err == nil {
+			sec, err = s.loadLocked(sec)
+		}
+		if err != nil {
+			s.logger.Error("failed to load replaced secret value, it won't be kept in history", "name", old.Name, "error", err)
+			return false
+		}
+		if prev.Value == sec.Value {
+			return false
+		}
+	}
+	entry := SecretVersion{Value: old.Value, Version: old.Version, Timestamp: old.UpdatedAt, spillPath: old.spillPath}
+	history := append(s.history[sec.Name], entry)
+	if len(history) > depth {
+		for _, e := range history[:len(history)-depth] {
+			s.removeHistorySpillLocked(sec.Name, e)
+		}
+		history = history[len(history)-depth:]
+	}
+	s.history[sec.Name] = history
+	return old.Spilled()
+}
+
+// deleteHistoryLocked drops the history of the named secret with its spill files.
+func (s *MemoryStore) deleteHistoryLocked(name string) {
+	for _, e := range s.history[name] {
+		s.removeHistorySpillLocked(name, e)
+	}
+	delete(s.history, name)
+}
+
+func (s *MemoryStore) removeHistorySpillLocked(name string, e SecretVersion) {
+	if e.spillPath == "" {
+		return
+	}
+	if err := os.Remove(e.spillPath); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("failed to remove history spill file", "name", name, "path", e.spillPath, "error", err)
+	}
+}
+
+// History returns the prior values of the named secret, oldest first, and false when the
+// secret doesn't exist.
+func (s *MemoryStore) History(name string) ([]SecretVersion, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	name = s.key(name)
+	sec, ok := s.secrets[name]
+	if !ok || s.expired(sec, s.now()) {
+		return nil, false
+	}
+	history := append([]SecretVersion{}, s.history[name]...)
+	for i, e := range history {
+		if e.spillPath == "" {
+			continue
+		}
+		b, err := os.ReadFile(e.spillPath)
+		if err != nil {
+			s.logger.Error("failed to read spilled history value", "name", name, "version", e.Version, "error", err)
+			continue
+		}
+		history[i].Value = string(b)
+	}
+	return history, true
+}
+
+func (w *WebServer) handleSecretHistory(rw http.ResponseWriter, r *http.Request) {
+	history, ok := w.store.History(r.PathValue("name"))
+	if !ok {
+		w.writeJSONError(rw, http.StatusNotFound, "secret not found")
+		return
+	}
+	switch order := r.URL.Query().Get("order"); order {
+	case "", "time":
+	case "version":
+		slices.SortStableFunc(history, func(a, b SecretVersion) int {
+			return compareVersions(a.Version, b.Version)
+		})
+	default:
+		w.rejectJSON(rw, reasonInvalid, "unknown order "+order+", must be time or version")
+		return
+	}
+	w.writeJSON(rw, http.StatusOK, history)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestSecretHistory(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{SecretHistoryDepth: 2})
+	store.Put(Secret{Name: "db.txt", Value: "a", Version: "v1"})
+	store.Put(Secret{Name: "db.txt", Value: "b", Version: "v2"})
+	store.Update("db.txt", func(sec *Secret) { sec.Mode = 0o600 })
+	store.Put(Secret{Name: "db.txt", Value: "c", Version: "v3"})
+	store.Put(Secret{Name: "db.txt", Value: "d", Version: "v4"})
+
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets/db.txt/history", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var history []SecretVersion
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	// The mode change kept the value and version, only the last 2 entries are kept
+	if len(history) != 2 || history[0].Version != "v2" || history[0].Value != "b" || history[1].Version != "v3" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	if history[0].Timestamp.IsZero() {
+		t.Error("expected entries to be timestamped")
+	}
+	if sec, _ := store.Get("db.txt"); sec.Value != "d" {
+		t.Errorf("expected the current value to be d, got %q", sec.Value)
+	}
+
+	store.Delete("db.txt")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets/db.txt/history", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted secret, got %d", rec.Code)
+	}
+	store.Put(Secret{Name: "db.txt", Value: "e", Version: "v1"})
+	if history, _ := store.History("db.txt"); len(history) != 0 {
+		t.Errorf("expected the history to be dropped with the secret, got %+v", history)
+	}
+}
+
+func TestSecretHistoryVersionOrder(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{SecretHistoryDepth: 10})
+	for _, v := range []string{"v10", "v2", "v1", "v9"} {
+		store.Put(Secret{Name: "db.txt", Value: v, Version: v})
+	}
+
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	get := func(query string) (int, []SecretVersion) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets/db.txt/history"+query, nil))
+		var history []SecretVersion
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, history
+	}
+	versions := func(history []SecretVersion) []string {
+		var out []string
+		for _, h := range history {
+			out = append(out, h.Version)
+		}
+		return out
+	}
+
+	if _, history := get(""); !slices.Equal(versions(history), []string{"v10", "v2", "v1"}) {
+		t.Errorf("expected the write order by default, got %v", versions(history))
+	}
+	if _, history := get("?order=version"); !slices.Equal(versions(history), []string{"v1", "v2", "v10"}) {
+		t.Errorf("expected the version order, got %v", versions(history))
+	}
+	if code, _ := get("?order=size"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown order, got %d", code)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// httpRefEntry is the last successful fetch of an httpref URL.
+type httpRefEntry struct {
+	body    []byte
+	fetched time.Time
+}
+
+// httpRefCache fetches httpref secrets, caching bodies for HTTPREF_CACHE_TTL and keeping
+// the last good body to serve when the upstream fails.
+type httpRefCache struct {
+	cfg    Config
+	client *http.Client
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	entries map[string]httpRefEntry
+}
+
+func newHTTPRefCache(logger *slog.Logger, cfg Config) *httpRefCache {
+	return &httpRefCache{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.HTTPRefTimeout},
+		logger:  logger,
+		entries: make(map[string]httpRefEntry),
+	}
+}
+
+// validHTTPRef checks an httpref value is an absolute http(s) URL.
+func validHTTPRef(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", rawURL)
+	}
+	return nil
+}
+
+// Get returns the body of rawURL, from the cache when fresh enough. On fetch failure
+// it falls back to the last good body, then to HTTPREF_FALLBACK when set.
+func (c *httpRefCache) Get(ctx context.Context, rawURL string) ([]byte, error) {
+	c.mu.Lock()
+	entry, cached := c.entries[rawURL]
+	c.mu.Unlock()
+	if cached && time.Since(entry.fetched) < c.cfg.HTTPRefCacheTTL {
+		return entry.body, nil
+	}
+
+	body, err := c.fetch(ctx, rawURL)
+	if err == nil {
+		c.mu.Lock()
+		c.entries[rawURL] = httpRefEntry{body: body, fetched: time.Now()}
+		c.mu.Unlock()
+		return body, nil
+	}
+
+	switch {
+	case cached:
+		c.logger.Error("failed to fetch httpref, serving last good value", "url", rawURL, "fetched", entry.fetched, "error", err)
+		return entry.body, nil
+	case c.cfg.HTTPRefFallback != "":
+		c.logger.Error("failed to fetch httpref, serving fallback", "url", rawURL, "error", err)
+		return []byte(c.cfg.HTTPRefFallback), nil
+	}
+	return nil, err
+}
+
+func (c *httpRefCache) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	if err := validHTTPRef(rawURL); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.HTTPRefMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > c.cfg.HTTPRefMaxBytes {
+		return nil, fmt.Errorf("body is larger than %d bytes", c.cfg.HTTPRefMaxBytes)
+	}
+	return b, nil
+}
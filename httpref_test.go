@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPRefSecret(t *testing.T) {
+	var body atomic.Value
+	body.Store("upstream-v1")
+	var failing atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(rw, "down", http.StatusBadGateway)
+			return
+		}
+		rw.Write([]byte(body.Load().(string)))
+	}))
+	defer upstream.Close()
+
+	cfg := Config{HTTPRefTimeout: time.Second, HTTPRefMaxBytes: 1024}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.Put(Secret{Name: "dyn.txt", Value: upstream.URL, Version: "v1", Mode: 420, Type: SecretTypeHTTPRef})
+
+	contents := func() string {
+		t.Helper()
+		files, _, _ := store.GetFiles(func(Secret) bool { return true })
+		if len(files) != 1 {
+			t.Fatalf("expected the httpref secret to be served, got %d files", len(files))
+		}
+		return string(files[0].Contents)
+	}
+
+	if got := contents(); got != "upstream-v1" {
+		t.Errorf("got %q, want the upstream body", got)
+	}
+	body.Store("upstream-v2")
+	if got := contents(); got != "upstream-v2" {
+		t.Errorf("got %q, want a fresh fetch without a cache TTL", got)
+	}
+
+	failing.Store(true)
+	if got := contents(); got != "upstream-v2" {
+		t.Errorf("got %q, want the last good value on failure", got)
+	}
+}
+
+func TestHTTPRefCache(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		rw.Write([]byte("0123456789"))
+	}))
+	defer upstream.Close()
+
+	cache := newHTTPRefCache(testLogger(), Config{HTTPRefTimeout: time.Second, HTTPRefMaxBytes: 1024, HTTPRefCacheTTL: time.Minute})
+	for range 3 {
+		if _, err := cache.Get(t.Context(), upstream.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected a single fetch within the TTL, got %d", n)
+	}
+
+	small := newHTTPRefCache(testLogger(), Config{HTTPRefTimeout: time.Second, HTTPRefMaxBytes: 5})
+	if _, err := small.Get(t.Context(), upstream.URL); err == nil {
+		t.Error("expected an error for a body over the size cap")
+	}
+
+	fallback := newHTTPRefCache(testLogger(), Config{HTTPRefTimeout: time.Second, HTTPRefMaxBytes: 1024, HTTPRefFallback: "fallback"})
+	if b, err := fallback.Get(t.Context(), "http://127.0.0.1:1/unreachable"); err != nil || string(b) != "fallback" {
+		t.Errorf("expected the configured fallback, got %q, %v", b, err)
+	}
+}
+
+func TestHTTPRefFetchOutsideLock(t *testing.T) {
+	fetching := make(chan struct{}, 1)
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fetching <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	store := NewMemoryStore(testLogger(), Config{HTTPRefTimeout: time.Minute, HTTPRefMaxBytes: 1024})
+	store.Put(Secret{Name: "slow.txt", Value: upstream.URL, Version: "v1", Mode: 420, Type: SecretTypeHTTPRef})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int, 1)
+	go func() {
+		files, _, _ := store.GetFilesContext(ctx, nil)
+		done <- len(files)
+	}()
+	<-fetching
+
+	// Writers aren't held up by the fetch
+	written := make(chan struct{})
+	go func() {
+		store.Set("other.txt", "x", "v1", 420)
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("write blocked by an httpref fetch")
+	}
+
+	// Cancelling the mount aborts the fetch
+	cancel()
+	select {
+	case n := <-done:
+		if n != 0 {
+			t.Errorf("expected the cancelled httpref to be skipped, got %d files", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch kept running after the mount was cancelled")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+const (
+	defaultLoadTestConcurrency = 10
+	defaultLoadTestDuration    = 10 * time.Second
+	maxLoadTestDuration        = 5 * time.Minute
+)
+
+// loadTestRequest mimics what the driver sends for a pod using the SecretProviderClass.
+var loadTestRequest = &v1alpha1.MountRequest{
+	Attributes: `{"csi.storage.k8s.io/pod.name":"loadtest","csi.storage.k8s.io/pod.namespace":"default",` +
+		`"csi.storage.k8s.io/serviceAccount.name":"default"}`,
+	TargetPath: "/var/lib/kubelet/pods/loadtest/volumes/kubernetes.io~csi/secrets-store-inline/mount",
+	Permission: "420",
+}
+
+// LoadTestResult summarizes a load test run.
+type LoadTestResult struct {
+	Concurrency       int            `json:"concurrency"`
+	Duration          string         `json:"duration"`
+	Requests          int            `json:"requests"`
+	Errors            int            `json:"errors"`
+	RequestsPerSecond float64        `json:"requestsPerSecond"`
+	Latency           LatencySummary `json:"latency"`
+}
+
+// LatencySummary holds latency percentiles, formatted as durations.
+type LatencySummary struct {
+	P50 string `json:"p50"`
+	P90 string `json:"p90"`
+	P99 string `json:"p99"`
+	Max string `json:"max"`
+}
+
+// runLoadTest calls mount from concurrency goroutines until duration elapses or ctx is done.
+func runLoadTest(ctx context.Context, concurrency int, duration time.Duration, mount func(context.Context) error) LoadTestResult {
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errs      int
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	deadline := start.Add(duration)
+	for range concurrency {
+		wg.Go(func() {
+			var local []time.Duration
+			var localErrs int
+			for time.Now().Before(deadline) && ctx.Err() == nil {
+				t := time.Now()
+				if err := mount(ctx); err != nil {
+					localErrs++
+				}
+				local = append(local, time.Since(t))
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			errs += localErrs
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	slices.Sort(latencies)
+	return LoadTestResult{
+		Concurrency:       concurrency,
+		Duration:          elapsed.String(),
+		Requests:          len(latencies),
+		Errors:            errs,
+		RequestsPerSecond: float64(len(latencies)) / elapsed.Seconds(),
+		Latency: LatencySummary{
+			P50: percentile(latencies, 0.50).String(),
+			P90: percentile(latencies, 0.90).String(),
+			P99: percentile(latencies, 0.99).String(),
+			Max: percentile(latencies, 1).String(),
+		},
+	}
+}
+
+// percentile returns the p quantile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(p*float64(len(sorted)-1))]
+}
+
+// handleLoadTest runs concurrent in-process Mount calls, the response is sent once
+// the run is over.
+func (w *WebServer) handleLoadTest(rw http.ResponseWriter, r *http.Request) {
+	concurrency := defaultLoadTestConcurrency
+	if v := r.URL.Query().Get("concurrency"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			w.writeJSONError(rw, http.StatusBadRequest, "invalid concurrency")
+			return
+		}
+		concurrency = n
+	}
+	if limit := w.provider.cfg.LoadTestMaxConcurrency; concurrency > limit {
+		w.writeJSONError(rw, http.StatusBadRequest, "concurrency exceeds LOADTEST_MAX_CONCURRENCY ("+strconv.Itoa(limit)+")")
+		return
+	}
+
+	duration := defaultLoadTestDuration
+	if v := r.URL.Query().Get("duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			w.writeJSONError(rw, http.StatusBadRequest, "invalid duration")
+			return
+		}
+		duration = min(d, maxLoadTestDuration)
+	}
+
+	w.logger.Info("Load test started via API", "concurrency", concurrency, "duration", duration)
+	result := runLoadTest(r.Context(), concurrency, duration, func(ctx context.Context) error {
+		_, err := w.provider.Mount(ctx, loadTestRequest)
+		return err
+	})
+	w.logger.Info("Load test finished", "requests", result.Requests, "errors", result.Errors, "rps", result.RequestsPerSecond)
+	w.writeJSON(rw, http.StatusOK, result)
+}
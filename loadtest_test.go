@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	if got := percentile(sorted, 0.5); got != 50*time.Millisecond {
+		t.Errorf("p50 = %v", got)
+	}
+	if got := percentile(sorted, 1); got != 100*time.Millisecond {
+		t.Errorf("max = %v", got)
+	}
+	if got := percentile(nil, 0.99); got != 0 {
+		t.Errorf("empty p99 = %v", got)
+	}
+}
+
+func TestLoadTest(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "v", "v1", 420)
+	store.Put(Secret{Name: "rot.txt", Value: "r", Version: "v1", RotateOnMount: true})
+	web := newTestWeb(t, Config{LoadTestMaxConcurrency: 8}, store)
+
+	// Writers run next to the load test so -race sees store contention
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			store.Set("app.txt", "v", "v2", 420)
+		}
+	}()
+
+	rec := httptest.NewRecorder()
+	web.handleLoadTest(rec, httptest.NewRequest(http.MethodPost, "/api/loadtest?concurrency=8&duration=100ms", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result LoadTestResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Concurrency != 8 || result.Requests == 0 || result.Errors != 0 || result.Latency.P99 == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	rec = httptest.NewRecorder()
+	web.handleLoadTest(rec, httptest.NewRequest(http.MethodPost, "/api/loadtest?concurrency=9", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 above the concurrency cap, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogEntry is a log record retained by a logRing.
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+
+	level slog.Level
+}
+
+// logRing keeps the last records logged, for GET /api/logs.
+type logRing struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{entries: make([]LogEntry, size)}
+}
+
+func (r *logRing) add(e LogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns the retained entries at level or above logged after since, oldest first.
+func (r *logRing) Entries(level slog.Level, since time.Time) []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ordered := r.entries[:r.next]
+	if r.full {
+		ordered = append(append([]LogEntry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+	}
+	out := make([]LogEntry, 0, len(ordered))
+	for _, e := range ordered {
+		if e.level >= level && e.Time.After(since) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ringHandler retains the records it handles in a logRing before passing them to next,
+// whose level decides what is retained.
+type ringHandler struct {
+	ring   *logRing
+	next   slog.Handler
+	attrs  []slog.Attr
+	prefix string
+}
+
+func (h *ringHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ringHandler) Handle(ctx context.Context, rec slog.Record) error {
+	e := LogEntry{Time: rec.Time, Level: rec.Level.String(), Message: rec.Message, level: rec.Level}
+	if len(h.attrs) > 0 || rec.NumAttrs() > 0 {
+		e.Attrs = make(map[string]string, len(h.attrs)+rec.NumAttrs())
+		for _, a := range h.attrs {
+			flattenAttr(e.Attrs, "", a)
+		}
+		rec.Attrs(func(a slog.Attr) bool {
+			flattenAttr(e.Attrs, h.prefix, a)
+			return true
+		})
+	}
+	h.ring.add(e)
+	return h.next.Handle(ctx, rec)
+}
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.next = h.next.WithAttrs(attrs)
+	c.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		c.attrs = append(c.attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &c
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.next = h.next.WithGroup(name)
+	c.prefix = h.prefix + name + "."
+	return &c
+}
+
+// flattenAttr stores a in m as strings, group members under dotted keys.
+func flattenAttr(m map[string]string, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, g := range v.Group() {
+			flattenAttr(m, p, g)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	m[prefix+a.Key] = v.String()
+}
+
+// logRingOf returns the ring retaining the logs of logger, nil when there is none.
+func logRingOf(logger *slog.Logger) *logRing {
+	if h, ok := logger.Handler().(*ringHandler); ok {
+		return h.ring
+	}
+	return nil
+}
+
+// handleLogs returns the retained logs, at the level query parameter or above, logged
+// after since, either a RFC 3339 time or a duration back from now.
+func (w *WebServer) handleLogs(rw http.ResponseWriter, r *http.Request) {
+	ring := logRingOf(w.logger)
+	if ring == nil {
+		w.writeJSONError(rw, http.StatusNotFound, "log retention is disabled (LOG_BUFFER_SIZE=0)")
+		return
+	}
+
+	level := slog.LevelDebug
+	if v := r.URL.Query().Get("level"); v != "" {
+		if err := level.UnmarshalText([]byte(strings.ToUpper(v))); err != nil {
+			w.writeJSONError(rw, http.StatusBadRequest, "invalid level")
+			return
+		}
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		} else {
+			w.writeJSONError(rw, http.StatusBadRequest, "since must be a RFC 3339 time or a duration")
+			return
+		}
+	}
+
+	w.writeJSON(rw, http.StatusOK, ring.Entries(level, since))
+}
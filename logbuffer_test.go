@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLogsEndpoint(t *testing.T) {
+	ring := newLogRing(3)
+	logger := slog.New(&ringHandler{
+		ring: ring,
+		next: slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo}),
+	}).With("app", "test")
+
+	web := newTestWeb(t, Config{}, NewMemoryStore(testLogger(), Config{}))
+	web.logger = logger
+
+	logger.Debug("below the level")
+	logger.Info("dropped by the ring")
+	logger.Info("first", "n", 1)
+	logger.WithGroup("req").Warn("second", "path", "/x")
+	logger.Error("third", "err", "boom")
+
+	get := func(query string) (int, []LogEntry) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		web.handleLogs(rec, httptest.NewRequest(http.MethodGet, "/api/logs"+query, nil))
+		var entries []LogEntry
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, entries
+	}
+
+	code, entries := get("")
+	if code != http.StatusOK || len(entries) != 3 {
+		t.Fatalf("expected the 3 last records, got %d %+v", code, entries)
+	}
+	if entries[0].Message != "first" || entries[0].Attrs["n"] != "1" || entries[0].Attrs["app"] != "test" {
+		t.Fatalf("unexpected first entry %+v", entries[0])
+	}
+	if entries[1].Attrs["req.path"] != "/x" {
+		t.Fatalf("expected grouped attribute, got %+v", entries[1].Attrs)
+	}
+
+	if _, entries = get("?level=warn"); len(entries) != 2 || entries[0].Message != "second" {
+		t.Fatalf("expected WARN and above, got %+v", entries)
+	}
+	if _, entries = get("?since=1h"); len(entries) != 3 {
+		t.Fatalf("expected all entries of the last hour, got %+v", entries)
+	}
+	if _, entries = get("?since=" + time.Now().Add(time.Minute).Format(time.RFC3339)); len(entries) != 0 {
+		t.Fatalf("expected no entries in the future, got %+v", entries)
+	}
+	if code, _ = get("?level=loud"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad level, got %d", code)
+	}
+	if code, _ = get("?since=yesterday"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad since, got %d", code)
+	}
+
+	web.logger = testLogger()
+	if code, _ = get(""); code != http.StatusNotFound {
+		t.Fatalf("expected 404 without retention, got %d", code)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// logLevel is the level of the logger built by createLogger, from LOG_LEVEL then
+// changed at runtime through POST /loglevel.
+var logLevel slog.LevelVar
+
+// parseLogLevel maps INFO and DEBUG, case insensitive, to their level.
+func parseLogLevel(s string) (slog.Level, bool) {
+	switch strings.ToUpper(s) {
+	case "INFO":
+		return slog.LevelInfo, true
+	case "DEBUG":
+		return slog.LevelDebug, true
+	}
+	return 0, false
+}
+
+// logLevelBody is the JSON of GET and POST /loglevel.
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+func (w *WebServer) handleGetLogLevel(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, logLevelBody{Level: logLevel.Level().String()})
+}
+
+// handleSetLogLevel switches the log level between INFO and DEBUG without a restart.
+func (w *WebServer) handleSetLogLevel(rw http.ResponseWriter, r *http.Request) {
+	var body logLevelBody
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	level, ok := parseLogLevel(body.Level)
+	if !ok {
+		w.writeJSONError(rw, http.StatusBadRequest, "level must be INFO or DEBUG")
+		return
+	}
+	previous := logLevel.Level()
+	logLevel.Set(level)
+	// Logged at WARN so the change shows whatever the new level
+	w.logger.Warn("Log level set via API", "level", level, "previous", previous)
+	w.writeJSON(rw, http.StatusOK, logLevelBody{Level: level.String()})
+}
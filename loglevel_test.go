@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogLevelEndpoint(t *testing.T) {
+	previous := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(previous) })
+	logLevel.Set(slog.LevelInfo)
+	logger := slog.New(newLogHandler(&strings.Builder{}, Config{}, &logLevel))
+
+	web := newTestWeb(t, Config{}, NewMemoryStore(testLogger(), Config{}))
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	send := func(method, body string) (int, logLevelBody) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, "/loglevel", strings.NewReader(body)))
+		var got logLevelBody
+		json.Unmarshal(rec.Body.Bytes(), &got)
+		return rec.Code, got
+	}
+
+	if code, got := send(http.MethodGet, ""); code != http.StatusOK || got.Level != "INFO" {
+		t.Fatalf("GET: got %d %+v, want INFO", code, got)
+	}
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug enabled at INFO")
+	}
+	if code, got := send(http.MethodPost, `{"level":"debug"}`); code != http.StatusOK || got.Level != "DEBUG" {
+		t.Fatalf("POST: got %d %+v, want DEBUG", code, got)
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug to be enabled live")
+	}
+	if code, _ := send(http.MethodPost, `{"level":"TRACE"}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown level, got %d", code)
+	}
+	if code, got := send(http.MethodGet, ""); code != http.StatusOK || got.Level != "DEBUG" {
+		t.Errorf("GET after a rejected change: got %d %+v, want DEBUG", code, got)
+	}
+}
@@ -1,24 +1,41 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
+	"maps"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/caarlos0/env/v11"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 
 	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
 )
@@ -29,52 +46,607 @@ const (
 
 // Config holds configuration similar to the reference main.go
 type Config struct {
-	LogLevel   string `env:"LOG_LEVEL" envDefault:"INFO"`
-	HTTPPort   int    `env:"HTTP_PORT" envDefault:"8090"`
-	SocketPath string `env:"SOCKET_PATH" envDefault:"/tmp/csi-debugger.sock"`
+	LogLevel string `env:"LOG_LEVEL" envDefault:"INFO"`
+	// LogFormat is LogFormatText or LogFormatJSON.
+	LogFormat string `env:"LOG_FORMAT" envDefault:"text"`
+	HTTPPort  int    `env:"HTTP_PORT" envDefault:"8090"`
+
+	// TLSCertFile and TLSKeyFile, when both set, serve the admin server over HTTPS.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+	SocketPath  string `env:"SOCKET_PATH" envDefault:"/tmp/csi-debugger.sock"`
+
+	// GRPCTCPAddr, when set, also serves the provider on that TCP address, e.g. for grpcurl.
+	// The driver keeps using SOCKET_PATH.
+	GRPCTCPAddr string `env:"GRPC_TCP_ADDR"`
+
+	// ExpectedProvidersDir is where the driver looks for provider sockets, a SOCKET_PATH
+	// elsewhere is warned about at startup. Empty disables the check.
+	ExpectedProvidersDir string `env:"EXPECTED_PROVIDERS_DIR" envDefault:"/var/lib/kubelet/plugins/secrets-store.csi.k8s.io/providers"`
+
+	// LogSource adds the file:line of the logging call to every log line.
+	LogSource bool `env:"LOG_SOURCE" envDefault:"false"`
+
+	// LogBufferSize is how many log records are retained for GET /api/logs, 0 disables it.
+	LogBufferSize int `env:"LOG_BUFFER_SIZE" envDefault:"1000"`
+
+	// MountHistorySize is how many Mount requests are retained for /requests, 0 disables it.
+	MountHistorySize int `env:"MOUNT_HISTORY_SIZE" envDefault:"100"`
+
+	// SecretHistoryDepth is how many prior values are kept per secret, 0 disables it.
+	SecretHistoryDepth int `env:"SECRET_HISTORY_DEPTH" envDefault:"10"`
+
+	// InstanceID tells instances apart in logs and metrics, defaults to KUBE_NODE_NAME or the hostname.
+	InstanceID string `env:"INSTANCE_ID"`
+
+	// NameNormalize lowercases and cleans secret names on Set so that
+	// "Config.json" and "config.json" end up as the same mounted file.
+	NameNormalize bool `env:"NAME_NORMALIZE" envDefault:"false"`
+
+	// GRPCDefaultDeadline is applied to RPCs arriving without a deadline, 0 disables it.
+	GRPCDefaultDeadline time.Duration `env:"GRPC_DEFAULT_DEADLINE" envDefault:"30s"`
+
+	// GRPCCompression compresses responses with the named compressor when the client supports it.
+	GRPCCompression string `env:"GRPC_COMPRESSION" envDefault:"none"`
+
+	// GRPCReflection registers the server reflection service, so grpcurl works without proto files.
+	GRPCReflection bool `env:"GRPC_REFLECTION" envDefault:"false"`
+
+	// ContentTransform is applied to secrets that don't set their own transform.
+	ContentTransform string `env:"CONTENT_TRANSFORM" envDefault:"none"`
+	NodeName         string `env:"KUBE_NODE_NAME"`
+
+	// RecoverPanics keeps the servers alive when a handler panics, disable it to get a crash instead.
+	RecoverPanics bool `env:"RECOVER_PANICS" envDefault:"true"`
+
+	// AccessLog logs every admin request and gRPC call with a request ID, status and duration.
+	AccessLog bool `env:"ACCESS_LOG" envDefault:"true"`
+
+	// MountManifest adds a file listing every served path, version and size to each mount.
+	MountManifest bool   `env:"MOUNT_MANIFEST" envDefault:"false"`
+	ManifestName  string `env:"MANIFEST_NAME" envDefault:"__manifest__.json"`
+
+	// AdminToken, when set, is required by every admin HTTP handler but /healthz, as a
+	// bearer token, the basic auth password or the admin_token form field.
+	AdminToken string `env:"ADMIN_TOKEN"`
+
+	// AdminHeaders are set on every admin HTTP response, e.g. "Cache-Control:no-store,X-Frame-Options:DENY".
+	AdminHeaders map[string]string `env:"ADMIN_HEADERS" envKeyValSeparator:":" envDefault:"X-Content-Type-Options:nosniff,Cache-Control:no-store,X-Frame-Options:DENY,Referrer-Policy:no-referrer"`
+
+	// FileRefRoot is the only directory fileref secrets may read from, fileref secrets are
+	// disabled when empty. FileRefMaxBytes caps the size of a file served that way.
+	FileRefRoot     string `env:"FILEREF_ROOT"`
+	FileRefMaxBytes int64  `env:"FILEREF_MAX_BYTES" envDefault:"1048576"`
+
+	// HTTPRef* configure httpref secrets, whose value is a URL fetched at mount time. Bodies
+	// are cached for HTTPRefCacheTTL, on fetch failure the last good body is served, or
+	// HTTPRefFallback when set.
+	HTTPRefTimeout  time.Duration `env:"HTTPREF_TIMEOUT" envDefault:"5s"`
+	HTTPRefMaxBytes int64         `env:"HTTPREF_MAX_BYTES" envDefault:"1048576"`
+	HTTPRefCacheTTL time.Duration `env:"HTTPREF_CACHE_TTL" envDefault:"5s"`
+	HTTPRefFallback string        `env:"HTTPREF_FALLBACK"`
+
+	// StaleAfter flags secrets not updated for that long in the API and UI, 0 disables it.
+	StaleAfter time.Duration `env:"STALE_AFTER" envDefault:"0"`
+
+	// VersionJitter is the probability, between 0 and 1, that a mounted secret reports a
+	// randomly suffixed version, 0 disables it.
+	VersionJitter float64 `env:"VERSION_JITTER" envDefault:"0"`
+
+	// VersionAllowlist makes Version fail with FailedPrecondition for other client versions, empty accepts any.
+	VersionAllowlist []string `env:"VERSION_ALLOWLIST" envSeparator:","`
+
+	// ProviderAPIVersion, RuntimeName and RuntimeVersion override what Version reports, e.g.
+	// to simulate another provider or a version mismatch.
+	ProviderAPIVersion string `env:"PROVIDER_API_VERSION"`
+	RuntimeName        string `env:"RUNTIME_NAME"`
+	RuntimeVersion     string `env:"RUNTIME_VERSION"`
+
+	// MountRequireToken makes Mount fail with PermissionDenied unless the auth_token attribute matches it.
+	MountRequireToken string `env:"MOUNT_REQUIRE_TOKEN"`
+
+	// FaultMode is a gRPC code name, e.g. UNAVAILABLE, every Mount fails with until the
+	// fault is cleared through POST /fault, empty disables it.
+	FaultMode string `env:"FAULT_MODE"`
+
+	// ReadyzFlapPeriod makes /readyz cycle, ready for the ReadyzFlapDuty fraction of
+	// every period then 503 for the rest, 0 disables it.
+	ReadyzFlapPeriod time.Duration `env:"READYZ_FLAP_PERIOD" envDefault:"0"`
+	ReadyzFlapDuty   float64       `env:"READYZ_FLAP_DUTY" envDefault:"0.5"`
+
+	// InitDotenv is a dotenv file imported at startup, DotenvPathSeparator is replaced by
+	// "/" in dotenv keys to build secret paths, e.g. "__" turns DB__PASSWORD into DB/PASSWORD.
+	InitDotenv          string `env:"INIT_DOTENV"`
+	DotenvPathSeparator string `env:"DOTENV_PATH_SEPARATOR"`
+
+	// SeedFile is a JSON or YAML list of secrets loaded at startup instead of the debug secret.
+	SeedFile string `env:"SEED_FILE"`
+
+	// TTLSweepInterval is how often secrets past their TTL are deleted, 0 only deletes
+	// them when listed or mounted.
+	TTLSweepInterval time.Duration `env:"TTL_SWEEP_INTERVAL" envDefault:"1s"`
+
+	// ConcatFile serves every secret as name=value lines of a single file with that name
+	// instead of one file per secret, empty keeps individual files. The concatFile attribute
+	// overrides it per mount.
+	ConcatFile string `env:"CONCAT_FILE"`
+
+	// PropagationDelay simulates an eventually consistent backend, writes are only mounted
+	// once it elapsed, the previous version is mounted until then. 0 disables it.
+	PropagationDelay time.Duration `env:"PROPAGATION_DELAY" envDefault:"0"`
+
+	// Tracing reads the W3C trace context callers send in gRPC metadata, attaching the
+	// trace and span IDs as exemplars to the Mount duration histogram.
+	Tracing bool `env:"TRACING" envDefault:"false"`
+
+	// MountThrottleBytesPerSec delays Mount by the time its file contents take to send at
+	// that rate, to model a bandwidth limited backend, 0 disables it.
+	MountThrottleBytesPerSec int64 `env:"MOUNT_THROTTLE_BYTES_PER_SEC" envDefault:"0"`
+
+	// MountDelay is added to every Mount before it answers, to reproduce a slow provider
+	// hitting the driver RPC timeout. POST /delay changes it at runtime.
+	MountDelay time.Duration `env:"MOUNT_DELAY" envDefault:"0"`
+
+	// MountOnlyChanged only sends the files whose version differs from the currentObjectVersion
+	// reported by the driver, instead of everything.
+	MountOnlyChanged bool `env:"MOUNT_ONLY_CHANGED" envDefault:"false"`
+
+	// RequireSeed seeds the store once the servers are up, /readyz failing until it is done
+	// and SeedReadyFile is written.
+	RequireSeed   bool   `env:"REQUIRE_SEED" envDefault:"false"`
+	SeedReadyFile string `env:"SEED_READY_FILE" envDefault:"/tmp/csi-debugger.seeded"`
+
+	// VersionValidation enforces a format on the versions set through the admin server:
+	// off, semver, integer or pattern, where versions must fully match VersionPattern.
+	VersionValidation string `env:"VERSION_VALIDATION" envDefault:"off"`
+	VersionPattern    string `env:"VERSION_PATTERN"`
+
+	// MountWhileSeeding is what Mount answers until RequireSeed seeding completes: serve the
+	// store as is, unavailable, or a placeholder file.
+	MountWhileSeeding string `env:"MOUNT_WHILE_SEEDING" envDefault:"serve"`
+
+	// GRPCRestartAttempts is how many times a failed gRPC Serve is restarted, with a
+	// backoff starting at GRPCRestartBackoff, before the process gives up. 0 disables it.
+	GRPCRestartAttempts int           `env:"GRPC_RESTART_ATTEMPTS" envDefault:"0"`
+	GRPCRestartBackoff  time.Duration `env:"GRPC_RESTART_BACKOFF" envDefault:"1s"`
+
+	// ServeDelay is how long the gRPC socket exists before it is served, connections
+	// made in between hang like with a provider that isn't answering yet.
+	ServeDelay time.Duration `env:"SERVE_DELAY" envDefault:"0"`
+
+	// ExpectedProviders are the accepted values of the provider attribute, a mismatch is
+	// logged, or rejected with ProviderCheckStrict. Empty disables the check.
+	ExpectedProviders   []string `env:"EXPECTED_PROVIDERS" envSeparator:","`
+	ProviderCheckStrict bool     `env:"PROVIDER_CHECK_STRICT" envDefault:"false"`
+
+	// OmitVersions makes Mount return files without any object version.
+	OmitVersions bool `env:"OMIT_VERSIONS" envDefault:"false"`
+
+	// MountCache memoizes Mount responses per attributes until the store generation changes.
+	MountCache bool `env:"MOUNT_CACHE" envDefault:"true"`
+
+	// MaxSecretBytes is the largest file Mount serves, larger ones are skipped or truncated
+	// according to OversizePolicy. Defaults to 1 MiB, 0 disables the limit.
+	MaxSecretBytes int    `env:"MAX_SECRET_BYTES" envDefault:"1048576"`
+	OversizePolicy string `env:"OVERSIZE_POLICY" envDefault:"skip"`
+
+	// GenerationFile adds a file holding the store generation to every Mount, empty disables it.
+	GenerationFile string `env:"GENERATION_FILE"`
+
+	// TemplatePath replaces the embedded admin UI template with an external html/template file.
+	TemplatePath string `env:"TEMPLATE_PATH"`
+
+	// HTTPHandlerTimeout bounds admin handlers, except long polls and load tests, answering
+	// 503 past it. 0 disables it.
+	HTTPHandlerTimeout time.Duration `env:"HTTP_HANDLER_TIMEOUT" envDefault:"30s"`
+
+	// Admin http.Server limits against slow or oversized clients. The write timeout leaves
+	// room for the 5m long polls and load tests.
+	HTTPReadTimeout    time.Duration `env:"HTTP_READ_TIMEOUT" envDefault:"30s"`
+	HTTPWriteTimeout   time.Duration `env:"HTTP_WRITE_TIMEOUT" envDefault:"6m"`
+	HTTPIdleTimeout    time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"2m"`
+	HTTPMaxHeaderBytes int           `env:"HTTP_MAX_HEADER_BYTES" envDefault:"65536"`
+
+	// HTTPShutdownTimeout bounds the admin server shutdown, event streams included.
+	HTTPShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" envDefault:"5s"`
+
+	// LoadTestMaxConcurrency caps the concurrency of POST /api/loadtest.
+	LoadTestMaxConcurrency int `env:"LOADTEST_MAX_CONCURRENCY" envDefault:"100"`
+
+	// PersistFile keeps the store content across restarts, empty disables persistence.
+	PersistFile string `env:"PERSIST_FILE"`
+
+	// PersistBackups is the number of timestamped backups of PersistFile kept, written before each flush.
+	PersistBackups int `env:"PERSIST_BACKUPS" envDefault:"3"`
+
+	// SpillThresholdBytes moves values larger than this to temp files instead of the heap, 0 disables it.
+	SpillThresholdBytes int `env:"SPILL_THRESHOLD_BYTES" envDefault:"0"`
 }
 
 // In-Memory Secret Store
 type Secret struct {
-	Name    string
-	Value   string
-	Version string
-	Mode    int32
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	Version string `json:"version"`
+	Mode    int32  `json:"mode"`
+
+	// Annotations are free-form notes (e.g. "ticket=ABC-123"), they don't affect mounts.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels select the secret in mounts passing a labels attribute, e.g. "app=frontend".
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Transform overrides the global content transform applied at mount time.
+	Transform string `json:"transform,omitempty"`
+
+	// RotateOnMount serves a different content and version on every Mount by
+	// appending a counter, the stored value is left untouched.
+	RotateOnMount bool `json:"rotateOnMount,omitempty"`
+
+	// ServiceAccount scopes the secret to mounts of pods running as that service account.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+
+	// Bundle groups secrets managed as a unit, Disabled secrets are never mounted.
+	Bundle   string `json:"bundle,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+
+	// Failing secrets are left out of mounts, which then report the
+	// failingObjectErrorCode error next to the healthy files.
+	Failing bool `json:"failing,omitempty"`
+
+	// Type is SecretTypeStatic (the default), SecretTypeFileRef, where Value is
+	// a node file read on every mount, SecretTypeHTTPRef, where Value is a URL, or
+	// SecretTypeVariants, where Value is a JSON array of values picked from on every
+	// mount according to VariantStrategy.
+	Type            string `json:"type,omitempty"`
+	VariantStrategy string `json:"variantStrategy,omitempty"`
+
+	// Encoding and LineEnding re-encode the served content, e.g. for Windows consumers
+	// expecting a BOM and CRLF, the raw value is served when unset.
+	Encoding   string `json:"encoding,omitempty"`
+	LineEnding string `json:"lineEnding,omitempty"`
+
+	// MountAs, when set, is the mounted file path instead of Name, the store key.
+	MountAs string `json:"mountAs,omitempty"`
+
+	// Owner, when set, is the token required in the X-Owner-Token header to mutate the
+	// secret through the admin server. It is kept across updates not setting a new one.
+	Owner string `json:"owner,omitempty"`
+
+	// UpdatedAt is set by the store on every write.
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// ExpiresAt, when set, is when the secret is deleted from the store, see the ttl field.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+
+	// spillPath is the temp file holding Value when it exceeded SPILL_THRESHOLD_BYTES
+	spillPath string
+	spillSize int
+
+	// previous is the version served while this one propagates, see PROPAGATION_DELAY
+	previous *Secret
+}
+
+// MountPath is the path of the secret in mounts, MountAs when set.
+func (sec Secret) MountPath() string {
+	if sec.MountAs != "" {
+		return sec.MountAs
+	}
+	return sec.Name
+}
+
+// Equal reports whether both secrets hold the same content and settings.
+func (sec Secret) Equal(o Secret) bool {
+	return sec.Name == o.Name &&
+		sec.Value == o.Value &&
+		sec.Version == o.Version &&
+		sec.Mode == o.Mode &&
+		sec.Transform == o.Transform &&
+		sec.RotateOnMount == o.RotateOnMount &&
+		sec.ServiceAccount == o.ServiceAccount &&
+		sec.Type == o.Type &&
+		sec.VariantStrategy == o.VariantStrategy &&
+		sec.Bundle == o.Bundle &&
+		sec.Disabled == o.Disabled &&
+		sec.Failing == o.Failing &&
+		sec.MountAs == o.MountAs &&
+		sec.Encoding == o.Encoding &&
+		sec.LineEnding == o.LineEnding &&
+		sec.ExpiresAt.Equal(o.ExpiresAt) &&
+		maps.Equal(sec.Annotations, o.Annotations) &&
+		maps.Equal(sec.Labels, o.Labels)
+}
+
+// Matches reports whether the secret name or one of its annotations contains query.
+func (sec Secret) Matches(query string) bool {
+	query = strings.ToLower(query)
+	if strings.Contains(strings.ToLower(sec.Name), query) {
+		return true
+	}
+	for k, v := range sec.Annotations {
+		if strings.Contains(strings.ToLower(k+"="+v), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAnnotations parses "key=value" pairs, one per line.
+func parseAnnotations(s string) (map[string]string, error) {
+	annotations := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid annotation %q, expected key=value", line)
+		}
+		annotations[k] = strings.TrimSpace(v)
+	}
+	return annotations, nil
 }
 
 type MemoryStore struct {
 	mu      sync.RWMutex
 	secrets map[string]Secret
+	cfg     Config
+	logger  *slog.Logger
+
+	// changed is closed and replaced on every mutation to wake up waiters
+	changed chan struct{}
+	// generation counts mutations, guarded by mu
+	generation uint64
+
+	// rotations counts serves of RotateOnMount secrets and renders the renders of
+	// templates, guarded by rotMu since GetFiles only holds the read lock
+	rotMu     sync.Mutex
+	rotations map[string]uint64
+	renders   map[string]uint64
+
+	// history holds the prior values of each secret, guarded by mu
+	history map[string][]SecretVersion
+
+	// spillDir holds the temp files of spilled values, created on first use
+	spillDir string
+
+	// now stamps UpdatedAt, tests replace it to backdate writes
+	now func() time.Time
+
+	// httpRefs fetches and caches the content of httpref secrets
+	httpRefs *httpRefCache
+
+	// frozen makes the admin server reject mutations with 423 Locked
+	frozen atomic.Bool
+
+	// seeding is set while REQUIRE_SEED startup seeding is running
+	seeding atomic.Bool
+
+	// persistMu serializes writes of PERSIST_FILE and its backups
+	persistMu sync.Mutex
 }
 
-func NewMemoryStore() *MemoryStore {
+func NewMemoryStore(logger *slog.Logger, cfg Config) *MemoryStore {
 	return &MemoryStore{
-		secrets: make(map[string]Secret),
+		secrets:   make(map[string]Secret),
+		cfg:       cfg,
+		logger:    logger,
+		changed:   make(chan struct{}),
+		rotations: make(map[string]uint64),
+		renders:   make(map[string]uint64),
+		history:   make(map[string][]SecretVersion),
+		now:       time.Now,
+		httpRefs:  newHTTPRefCache(logger, cfg),
 	}
 }
 
+// nextRotation increments and returns the rotation counter of name.
+func (s *MemoryStore) nextRotation(name string) uint64 {
+	s.rotMu.Lock()
+	defer s.rotMu.Unlock()
+	s.rotations[name]++
+	return s.rotations[name]
+}
+
+// nextRender increments and returns the template render counter of name.
+func (s *MemoryStore) nextRender(name string) uint64 {
+	s.rotMu.Lock()
+	defer s.rotMu.Unlock()
+	s.renders[name]++
+	return s.renders[name]
+}
+
+// resetRotation restarts the rotation and render counters of name, e.g. when its base
+// value changes.
+func (s *MemoryStore) resetRotation(name string) {
+	s.rotMu.Lock()
+	defer s.rotMu.Unlock()
+	delete(s.rotations, name)
+	delete(s.renders, name)
+}
+
+// Changed returns a channel closed on the next store mutation.
+func (s *MemoryStore) Changed() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.changed
+}
+
+// Generation returns the store generation, bumped on every mutation.
+func (s *MemoryStore) Generation() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.generation
+}
+
+// notifyLocked bumps the generation and wakes up everyone waiting on Changed, s.mu must
+// be held for writing.
+func (s *MemoryStore) notifyLocked() {
+	s.generation++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// normalizeName lowercases and cleans a secret name, e.g. " ./Config.JSON" becomes "config.json".
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return name
+	}
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+// key returns the map key used for name, applying normalization when enabled.
+func (s *MemoryStore) key(name string) string {
+	if !s.cfg.NameNormalize {
+		return name
+	}
+	return normalizeName(name)
+}
+
 func (s *MemoryStore) Set(name, value, version string, mode int32) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.secrets[name] = Secret{
+	s.Put(Secret{
 		Name:    name,
 		Value:   value,
 		Version: version,
 		Mode:    mode,
+	})
+}
+
+// Put stores sec as is, replacing any secret with the same name.
+func (s *MemoryStore) Put(sec Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(sec)
+	s.notifyLocked()
+}
+
+// Update applies fn to the named secret under a single write lock, it returns
+// false if the secret doesn't exist.
+func (s *MemoryStore) Update(name string, fn func(sec *Secret)) (Secret, bool) {
+	sec, ok, _ := s.UpdateChecked(name, func(sec *Secret) error {
+		fn(sec)
+		return nil
+	})
+	return sec, ok
+}
+
+// UpdateChecked is Update with a fn that can veto the change, nothing is stored when
+// it returns an error.
+func (s *MemoryStore) UpdateChecked(name string, fn func(sec *Secret) error) (Secret, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, ok := s.secrets[s.key(name)]
+	if !ok {
+		return Secret{}, false, nil
+	}
+	sec, err := s.loadLocked(sec)
+	if err != nil {
+		s.logger.Error("failed to load secret", "name", name, "error", err)
+	}
+	if err := fn(&sec); err != nil {
+		return Secret{}, true, err
+	}
+	sec.Name = s.key(name)
+	s.putLocked(sec)
+	s.notifyLocked()
+	return sec, true, nil
+}
+
+func (s *MemoryStore) putLocked(sec Secret) {
+	if key := s.key(sec.Name); key != sec.Name {
+		_, collision := s.secrets[key]
+		s.logger.Info("secret name normalized", "name", sec.Name, "normalized", key, "collision", collision)
+		sec.Name = key
+	}
+	sec.Annotations = maps.Clone(sec.Annotations)
+	sec.Labels = maps.Clone(sec.Labels)
+	sec.UpdatedAt = s.now()
+	sec.spillPath, sec.spillSize = "", 0
+	s.spillLocked(&sec)
+	sec.previous = nil
+	if old, ok := s.secrets[sec.Name]; ok {
+		s.keepPreviousLocked(&sec, old)
+		if !s.recordHistoryLocked(sec, old) {
+			s.removeSpillLocked(old)
+		}
+		if sec.Owner == "" {
+			sec.Owner = old.Owner
+		}
+	}
+	s.secrets[sec.Name] = sec
+	s.resetRotation(sec.Name)
+}
+
+func (s *MemoryStore) Get(name string) (Secret, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sec, ok := s.secrets[s.key(name)]
+	if !ok || s.expired(sec, s.now()) {
+		return sec, false
+	}
+	sec, err := s.loadLocked(sec)
+	if err != nil {
+		s.logger.Error("failed to load secret", "name", name, "error", err)
 	}
+	return sec, true
 }
 
-func (s *MemoryStore) Delete(name string) {
+// Delete removes the named secret, it reports whether it existed.
+func (s *MemoryStore) Delete(name string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if !s.deleteLocked(name) {
+		return false
+	}
+	s.notifyLocked()
+	return true
+}
+
+// deleteLocked removes the named secret, it reports whether it existed.
+func (s *MemoryStore) deleteLocked(name string) bool {
+	name = s.key(name)
+	sec, ok := s.secrets[name]
+	if ok {
+		s.removeSpillLocked(sec)
+	}
 	delete(s.secrets, name)
+	s.deleteHistoryLocked(name)
+	s.resetRotation(name)
+	return ok
+}
+
+// Len returns the number of secrets in the store.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.secrets)
 }
 
 func (s *MemoryStore) List() []Secret {
+	// Runs after the read lock is released, deleting what was skipped as expired
+	var expired bool
+	defer func() {
+		if expired {
+			s.ExpireSecrets()
+		}
+	}()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	now := s.now()
 	var list []Secret
 	for _, v := range s.secrets {
+		if s.expired(v, now) {
+			expired = true
+			continue
+		}
+		v, err := s.loadLocked(v)
+		if err != nil {
+			s.logger.Error("failed to load secret", "name", v.Name, "error", err)
+		}
 		list = append(list, v)
 	}
 	// Sort for stable UI rendering
@@ -84,56 +656,442 @@ func (s *MemoryStore) List() []Secret {
 	return list
 }
 
-func (s *MemoryStore) GetFiles() ([]*v1alpha1.File, []*v1alpha1.ObjectVersion) {
+// HasServiceAccount reports whether any secret is scoped to the service account sa.
+func (s *MemoryStore) HasServiceAccount(sa string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	for _, sec := range s.secrets {
+		if sec.ServiceAccount == sa {
+			return true
+		}
+	}
+	return false
+}
 
-	var files []*v1alpha1.File
-	var versions []*v1alpha1.ObjectVersion
+// GetFiles is GetFilesContext without a deadline on fetching httpref secrets.
+func (s *MemoryStore) GetFiles(match func(Secret) bool) (files []*v1alpha1.File, versions []*v1alpha1.ObjectVersion, generation uint64) {
+	return s.GetFilesContext(context.Background(), match)
+}
 
-	for _, sec := range s.secrets {
-		files = append(files, &v1alpha1.File{
-			Path:     sec.Name,
-			Mode:     sec.Mode,
-			Contents: []byte(sec.Value),
-		})
-		versions = append(versions, &v1alpha1.ObjectVersion{
-			Id:      sec.Name,
-			Version: sec.Version,
-		})
+// GetFilesContext renders the secrets accepted by match, or all of them if match is nil, as
+// mount files. Files are sorted by name, generation is the store generation they were
+// rendered at. The secrets are snapshotted under the read lock and rendered after it is
+// released, so slow httpref fetches, bounded by ctx, and templates don't hold up writers.
+func (s *MemoryStore) GetFilesContext(ctx context.Context, match func(Secret) bool) (files []*v1alpha1.File, versions []*v1alpha1.ObjectVersion, generation uint64) {
+	secrets, generation := s.snapshot(match)
+	for _, sec := range secrets {
+		file, version, ok := s.render(ctx, sec)
+		if !ok {
+			continue
+		}
+		files = append(files, file)
+		versions = append(versions, version)
 	}
-	return files, versions
+	return files, versions, generation
 }
 
+// snapshot returns the secrets of a mount accepted by match, sorted by name with their
+// spilled values loaded, along with the store generation.
+func (s *MemoryStore) snapshot(match func(Secret) bool) (secrets []Secret, generation uint64) {
+	// Runs after the read lock is released, deleting what was skipped as expired
+	var expired bool
+	defer func() {
+		if expired {
+			s.ExpireSecrets()
+		}
+	}()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	for _, name := range slices.Sorted(maps.Keys(s.secrets)) {
+		sec := s.secrets[name]
+		if s.expired(sec, now) {
+			expired = true
+			continue
+		}
+		if sec.Disabled {
+			continue
+		}
+		if match != nil && !match(sec) {
+			continue
+		}
+		sec, ok := s.visibleLocked(sec)
+		if !ok {
+			continue
+		}
+		// Spilled values are only read back for the duration of the mount, while the
+		// lock keeps their file from being removed
+		sec, err := s.loadLocked(sec)
+		if err != nil {
+			s.logger.Error("failed to load secret, skipping it", "name", sec.Name, "error", err)
+			continue
+		}
+		secrets = append(secrets, sec)
+	}
+	return secrets, s.generation
+}
+
+// render produces the file and version served for sec, ok is false when the secret has
+// to be skipped for this mount. It runs without the store lock.
+func (s *MemoryStore) render(ctx context.Context, sec Secret) (file *v1alpha1.File, version *v1alpha1.ObjectVersion, ok bool) {
+	if sec.Type == SecretTypeFileRef {
+		b, err := readFileRef(s.cfg.FileRefRoot, sec.Value, s.cfg.FileRefMaxBytes)
+		if err != nil {
+			s.logger.Error("failed to read fileref secret, skipping it", "name", sec.Name, "path", sec.Value, "error", err)
+			return nil, nil, false
+		}
+		sec.Value = string(b)
+	}
+
+	if sec.Type == SecretTypeHTTPRef {
+		b, err := s.httpRefs.Get(ctx, sec.Value)
+		if err != nil {
+			s.logger.Error("failed to fetch httpref secret, skipping it", "name", sec.Name, "url", sec.Value, "error", err)
+			return nil, nil, false
+		}
+		sec.Value = string(b)
+	}
+
+	if sec.Type == SecretTypeVariants {
+		variants, err := parseVariants(sec.Value)
+		if err != nil {
+			s.logger.Error("invalid variants secret, skipping it", "name", sec.Name, "error", err)
+			return nil, nil, false
+		}
+		i := s.pickVariant(sec, len(variants))
+		sec.Value = variants[i]
+		sec.Version = fmt.Sprintf("%s-%d", sec.Version, i)
+		s.logger.Info("picked secret variant on mount", "name", sec.Name, "strategy", sec.VariantStrategy, "index", i, "version", sec.Version)
+	}
+
+	if sec.RotateOnMount {
+		n := s.nextRotation(sec.Name)
+		sec.Value = fmt.Sprintf("%s-%d", sec.Value, n)
+		sec.Version = fmt.Sprintf("%s-%d", sec.Version, n)
+		s.logger.Info("rotated secret on mount", "name", sec.Name, "rotation", n, "version", sec.Version)
+	}
+
+	transform := sec.Transform
+	if transform == "" {
+		transform = s.cfg.ContentTransform
+	}
+	contents, err := applyTransform(transform, sec, s.cfg.NodeName, func() uint64 { return s.nextRender(sec.Name) })
+	if err != nil {
+		// The raw value of a broken template is its source, never what the pod expects
+		s.logger.Error("failed to transform secret, skipping it", "name", sec.Name, "transform", transform, "error", err)
+		return nil, nil, false
+	}
+	if transform != "" && transform != TransformNone {
+		s.logger.Debug("applied content transform", "name", sec.Name, "transform", transform)
+	}
+
+	contents = encodeContents(contents, sec.Encoding, sec.LineEnding)
+
+	// Jitter simulates a flaky backend reporting spurious version changes, content is untouched
+	if p := s.cfg.VersionJitter; p > 0 && rand.Float64() < p {
+		jittered := fmt.Sprintf("%s-j%06x", sec.Version, rand.Uint32N(1<<24))
+		s.logger.Info("applied version jitter", "name", sec.Name, "version", sec.Version, "jittered", jittered)
+		sec.Version = jittered
+	}
+
+	path := sec.MountPath()
+	return &v1alpha1.File{
+		Path:     path,
+		Mode:     sec.Mode,
+		Contents: contents,
+	}, &v1alpha1.ObjectVersion{
+		Id:      path,
+		Version: sec.Version,
+	}, true
+}
+
+// generationTrailer is the gRPC trailer carrying the store generation of a Mount response.
+const generationTrailer = "x-csi-debugger-generation"
+
 // gRPC Provider Server (Implements the CSI Driver Provider Interface)
 type ProviderServer struct {
 	v1alpha1.UnimplementedCSIDriverProviderServer
-	store  *MemoryStore
-	logger *slog.Logger
+	store   *MemoryStore
+	cfg     Config
+	metrics *Metrics
+	stats   *MountStats
+	cache   *mountCache
+	conns   *connTracker
+	logger  *slog.Logger
+
+	// versionFault is injected into Version calls, set from the admin API
+	versionFault atomic.Pointer[VersionFault]
+	// versionOverride replaces fields of the Version response, set from the admin API
+	versionOverride atomic.Pointer[VersionInfo]
+	// mountFault makes Mount fail, set from FAULT_MODE or the admin API
+	mountFault atomic.Pointer[MountFault]
+	// mountDelay holds every Mount, in nanoseconds, set from MOUNT_DELAY or the admin API
+	mountDelay atomic.Int64
+	// history retains the last Mount requests, nil when MOUNT_HISTORY_SIZE is 0
+	history *mountHistory
 }
 
-func (s *ProviderServer) Mount(ctx context.Context, req *v1alpha1.MountRequest) (*v1alpha1.MountResponse, error) {
+func NewProviderServer(logger *slog.Logger, cfg Config, store *MemoryStore, metrics *Metrics) *ProviderServer {
+	s := &ProviderServer{
+		store:   store,
+		cfg:     cfg,
+		metrics: metrics,
+		stats:   NewMountStats(),
+		cache:   newMountCache(),
+		conns:   newConnTracker(),
+		history: newMountHistory(cfg.MountHistorySize),
+		logger:  logger,
+	}
+	s.mountDelay.Store(int64(max(cfg.MountDelay, 0)))
+	// An invalid FAULT_MODE is rejected at startup
+	if f, err := parseFaultMode(cfg.FaultMode); err == nil && f != nil {
+		s.mountFault.Store(f)
+	}
+	return s
+}
+
+func (s *ProviderServer) Mount(ctx context.Context, req *v1alpha1.MountRequest) (resp *v1alpha1.MountResponse, err error) {
+	s.metrics.mountCalls.Inc()
+	received := time.Now()
+	defer s.metrics.ObserveMount(ctx, received, s.cfg.Tracing)
+	defer func() { s.recordMount(req, received, resp, err) }()
 	s.logger.Info("Mount request received",
 		"target_path", req.GetTargetPath(),
 		"attributes", req.GetAttributes(),
+		"current_objects", len(req.GetCurrentObjectVersion()),
 	)
 
-	// In a real provider, we would parse req.GetAttributes() to know WHICH secrets to fetch.
-	// For this debugger, we return everything currently in the MemoryStore to the mount point.
-	files, versions := s.store.GetFiles()
+	// Time between mounts of the same target path is the driver's rotation poll interval
+	if interval, ok := s.stats.Observe(req.GetTargetPath(), time.Now()); ok {
+		s.metrics.mountInterval.Observe(interval.Seconds())
+		s.logger.Debug("Mount interval observed", "target_path", req.GetTargetPath(), "interval", interval)
+	}
+
+	if err := s.injectMountFault(req.GetTargetPath()); err != nil {
+		return nil, err
+	}
+
+	attrs, err := parseAttributes(req.GetAttributes())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid attributes: %v", err)
+	}
+
+	if s.cfg.MountRequireToken != "" &&
+		subtle.ConstantTimeCompare([]byte(attrs[authTokenAttribute]), []byte(s.cfg.MountRequireToken)) != 1 {
+		s.logger.Warn("Mount denied, missing or wrong auth token",
+			"target_path", req.GetTargetPath(),
+			"pod", attrs[podNamespaceAttribute]+"/"+attrs[podNameAttribute],
+			"token_present", attrs[authTokenAttribute] != "",
+		)
+		return nil, status.Errorf(codes.PermissionDenied, "missing or invalid %s attribute", authTokenAttribute)
+	}
+
+	if resp, done, err := s.whileSeeding(req.GetTargetPath()); done {
+		return resp, err
+	}
+
+	// Identical attributes at an unchanged generation get the memoized response
+	cacheKey := ""
+	if s.cfg.MountCache {
+		if s.store.Dynamic() {
+			s.metrics.mountCache.WithLabelValues("bypass").Inc()
+		} else {
+			cacheKey = mountCacheKey(attrs, req.GetCurrentObjectVersion())
+			if e, ok := s.cache.Get(cacheKey, s.store.Generation()); ok {
+				s.metrics.mountCache.WithLabelValues("hit").Inc()
+				for _, name := range e.served {
+					s.metrics.SecretServed(name)
+				}
+				if e.diff != nil {
+					s.logObjectVersionDiff(req.GetTargetPath(), *e.diff)
+				}
+				grpc.SetTrailer(ctx, metadata.Pairs(generationTrailer, strconv.FormatUint(e.generation, 10)))
+				if err := s.throttle(ctx, req.GetTargetPath(), e.resp); err != nil {
+					return nil, err
+				}
+				return e.resp, nil
+			}
+			s.metrics.mountCache.WithLabelValues("miss").Inc()
+		}
+	}
 
-	return &v1alpha1.MountResponse{
+	if err := s.checkProvider(req.GetTargetPath(), attrs[providerAttribute]); err != nil {
+		return nil, err
+	}
+
+	// Pods whose service account has scoped secrets only get those, everyone else
+	// gets the global (unscoped) secrets.
+	scope := ""
+	if sa := attrs[serviceAccountAttribute]; sa != "" && s.store.HasServiceAccount(sa) {
+		scope = sa
+		s.logger.Info("Mount scoped to service account", "target_path", req.GetTargetPath(), "service_account", sa)
+	}
+	limit := -1
+	if v, ok := attrs[limitAttribute]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s attribute %q, must be a non negative integer", limitAttribute, v)
+		}
+		limit = n
+	}
+
+	selected, err := s.selectedObjects(attrs)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	bundle := attrs[bundleAttribute]
+	if bundle != "" {
+		s.logger.Info("Mount restricted to bundle", "target_path", req.GetTargetPath(), "bundle", bundle)
+	}
+	labels, err := parseLabels(attrs[labelsAttribute])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s attribute: %v", labelsAttribute, err)
+	}
+	if len(labels) > 0 {
+		s.logger.Info("Mount restricted to labels", "target_path", req.GetTargetPath(), "labels", formatLabels(labels))
+	}
+	matched := make(map[string]bool, len(selected))
+	var failing []string
+	// names maps mounted paths back to secret names, which label the served metric
+	names := make(map[string]string)
+	files, versions, generation := s.store.GetFilesContext(ctx, func(sec Secret) bool {
+		names[sec.MountPath()] = sec.Name
+		if sec.ServiceAccount != scope || (bundle != "" && sec.Bundle != bundle) || !matchLabels(sec.Labels, labels) {
+			return false
+		}
+		if selected != nil {
+			if !selected[sec.Name] {
+				return false
+			}
+			matched[sec.Name] = true
+		}
+		if sec.Failing {
+			failing = append(failing, sec.Name)
+			return false
+		}
+		return true
+	})
+	if selected != nil {
+		var missing []string
+		for name := range selected {
+			if !matched[name] {
+				missing = append(missing, name)
+			}
+		}
+		slices.Sort(missing)
+		s.logger.Info("Mount restricted to selected objects", "target_path", req.GetTargetPath(), "selected", len(selected), "missing", missing)
+	}
+	files, versions, oversize := s.enforceMaxSize(files, versions)
+	if limit >= 0 && len(files) > limit {
+		omitted := make([]string, 0, len(files)-limit)
+		for _, f := range files[limit:] {
+			omitted = append(omitted, f.GetPath())
+		}
+		s.logger.Info("Mount limited", "target_path", req.GetTargetPath(), "limit", limit, "omitted", omitted)
+		files, versions = files[:limit], versions[:limit]
+	}
+	// Show what the driver rotation reconciler should rewrite in an already populated target
+	var diff *ObjectVersionDiff
+	if current := req.GetCurrentObjectVersion(); len(current) > 0 {
+		d := diffObjectVersions(current, versions)
+		diff = &d
+		s.logObjectVersionDiff(req.GetTargetPath(), d)
+	}
+	// Only send the objects the target doesn't have at their version, the manifest and
+	// concat file are still built from every file
+	onlyChanged := s.cfg.MountOnlyChanged && diff != nil
+	served := make([]string, 0, len(files))
+	for _, f := range files {
+		if onlyChanged && diff.IsUnchanged(f.GetPath()) {
+			continue
+		}
+		name := cmp.Or(names[f.GetPath()], f.GetPath())
+		s.metrics.SecretServed(name)
+		served = append(served, name)
+	}
+
+	concat := s.cfg.ConcatFile
+	if v, ok := attrs[concatFileAttribute]; ok {
+		concat = v
+	}
+	if concat != "" {
+		file, version := buildConcatFile(concat, files)
+		files, versions = []*v1alpha1.File{file}, []*v1alpha1.ObjectVersion{version}
+	}
+
+	// The trailer is only set when called through gRPC, not from the load test
+	grpc.SetTrailer(ctx, metadata.Pairs(generationTrailer, strconv.FormatUint(generation, 10)))
+	if s.cfg.GenerationFile != "" {
+		files = append(files, &v1alpha1.File{
+			Path:     s.cfg.GenerationFile,
+			Mode:     0o444,
+			Contents: []byte(strconv.FormatUint(generation, 10)),
+		})
+		versions = append(versions, &v1alpha1.ObjectVersion{
+			Id:      s.cfg.GenerationFile,
+			Version: strconv.FormatUint(generation, 10),
+		})
+	}
+
+	if s.cfg.MountManifest {
+		file, version, err := buildManifest(s.cfg.ManifestName, files, versions, oversize)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to build manifest: %v", err)
+		}
+		files = append(files, file)
+		versions = append(versions, version)
+	}
+	if onlyChanged {
+		// Against the final versions, so an unchanged manifest or concat file is left out too
+		files = s.onlyChanged(req.GetTargetPath(), diffObjectVersions(req.GetCurrentObjectVersion(), versions), files)
+	}
+
+	// A provider not reporting versions makes the driver fall back to its own tracking
+	if s.cfg.OmitVersions {
+		s.logger.Info("Mount object versions omitted (OMIT_VERSIONS)", "target_path", req.GetTargetPath(), "files", len(files))
+		versions = nil
+	}
+
+	resp = &v1alpha1.MountResponse{
 		Files:         files,
 		ObjectVersion: versions,
-	}, nil
+		Error:         s.failingObjectsError(req.GetTargetPath(), failing),
+	}
+	if cacheKey != "" {
+		s.cache.Put(cacheKey, mountCacheEntry{resp: resp, generation: generation, served: served, diff: diff})
+	}
+	if err := s.throttle(ctx, req.GetTargetPath(), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 func (s *ProviderServer) Version(ctx context.Context, req *v1alpha1.VersionRequest) (*v1alpha1.VersionResponse, error) {
+	s.metrics.versionCalls.Inc()
 	s.logger.Info("Version request received", "client_version", req.Version)
+
+	if f := s.versionFault.Load(); f != nil {
+		if err := f.inject(ctx); err != nil {
+			s.logger.Warn("Version answered with injected fault", "error", err)
+			return nil, err
+		}
+	}
+
+	// The v1alpha1 VersionResponse has no supported versions field, negotiation is
+	// simulated by rejecting clients outside of VERSION_ALLOWLIST.
+	if len(s.cfg.VersionAllowlist) > 0 && !slices.Contains(s.cfg.VersionAllowlist, req.GetVersion()) {
+		s.logger.Warn("Version rejected, client version not allowed",
+			"client_version", req.GetVersion(), "allowed", s.cfg.VersionAllowlist)
+		return nil, status.Errorf(codes.FailedPrecondition, "unsupported client version %q, allowed: %s",
+			req.GetVersion(), strings.Join(s.cfg.VersionAllowlist, ", "))
+	}
+	info := s.versionInfo()
 	return &v1alpha1.VersionResponse{
-		Version:        "v1alpha1",
-		RuntimeName:    "csi-debugger-provider",
-		RuntimeVersion: "0.0.1",
+		Version:        info.Version,
+		RuntimeName:    info.RuntimeName,
+		RuntimeVersion: info.RuntimeVersion,
 	}, nil
 }
 
@@ -145,28 +1103,31 @@ const adminHTML = `
 <html>
 <head>
     <title>CSI Debugger Admin</title>
-    <style>
-        body { font-family: sans-serif; max-width: 800px; margin: 0 auto; padding: 20px; }
-        table { width: 100%; border-collapse: collapse; margin-top: 20px; }
-        th, td { border: 1px solid #ddd; padding: 8px; text-align: left; }
-        th { background-color: #f2f2f2; }
-        .form-group { margin-bottom: 15px; }
-        label { display: block; margin-bottom: 5px; }
-        input, textarea { width: 100%; padding: 8px; box-sizing: border-box; }
-        button { padding: 10px 15px; background-color: #007bff; color: white; border: none; cursor: pointer; }
-        button.delete { background-color: #dc3545; }
-        .header { display: flex; justify-content: space-between; align-items: center; }
-    </style>
+    <link rel="icon" href="/favicon.ico">
+    <link rel="stylesheet" href="/static/admin.css">
 </head>
 <body>
     <div class="header">
         <h1>CSI Secret Debugger</h1>
-        <button onclick="location.reload()">Refresh</button>
+        <div>
+            <form action="/" method="GET">
+                <input type="hidden" name="q" value="{{.Query}}">
+                {{if not .Masked}}<input type="hidden" name="mask" value="true">{{end}}
+                <button type="submit">{{if .Masked}}Show values{{else}}Mask values{{end}}</button>
+            </form>
+            <button onclick="location.reload()">Refresh</button>
+            <a href="/requests">Mount requests</a>
+        </div>
     </div>
 
     <h3>Active Secrets (In-Memory)</h3>
     <p>These secrets will be returned to the CSI Driver upon the next <code>Mount</code> call.</p>
 
+    <form action="/" method="GET">
+        <input type="text" name="q" value="{{.Query}}" placeholder="Search by name or annotation (e.g. ticket=ABC-123)">
+        {{if .Masked}}<input type="hidden" name="mask" value="true">{{end}}
+    </form>
+
     <table>
         <thead>
             <tr>
@@ -174,28 +1135,44 @@ const adminHTML = `
                 <th>Content Preview</th>
                 <th>Version</th>
                 <th>Mode</th>
+                <th>Options</th>
+                <th>Annotations</th>
+                <th>Labels</th>
                 <th>Action</th>
             </tr>
         </thead>
         <tbody>
-            {{range .}}
-            <tr>
+            {{range .Groups}}
+            <tr class="bundle"><th colspan="8">{{if .Bundle}}Bundle {{.Bundle}}{{else}}No bundle{{end}}</th></tr>
+            {{range .Secrets}}
+            <tr{{if .Disabled}} class="disabled" title="Disabled with its bundle, not mounted"{{else if .Stale}} class="stale" title="Not updated since {{.UpdatedAt.Format "2006-01-02 15:04:05"}}"{{end}}>
                 <td>{{.Name}}</td>
-                <td>{{.Value}}</td>
-                <td>{{.Version}}</td>
+                <td>{{if $.Masked}}••••••{{else if .Spilled}}(spilled to disk, {{.Size}} bytes){{else if .Binary}}(binary, {{.Size}} bytes){{else}}{{.Secret.Value}}{{end}}</td>
+                <td>{{.Version}}{{if .RotateOnMount}} (rotates on mount){{end}}</td>
                 <td>{{.Mode}}</td>
+                <td>{{if .Disabled}}disabled<br>{{end}}{{if .Failing}}failing<br>{{end}}{{if .Type}}type={{.Type}}{{with .VariantStrategy}} ({{.}}){{end}}<br>{{end}}{{if .Transform}}transform={{.Transform}}<br>{{end}}{{if .ServiceAccount}}serviceAccount={{.ServiceAccount}}<br>{{end}}{{if .MountAs}}mountAs={{.MountAs}}<br>{{end}}{{if .Encoding}}encoding={{.Encoding}}<br>{{end}}{{if .LineEnding}}lineEnding={{.LineEnding}}<br>{{end}}{{if not .ExpiresAt.IsZero}}expires {{.ExpiresAt.Format "2006-01-02 15:04:05"}}<br>{{end}}</td>
+                <td>{{range $k, $v := .Annotations}}{{$k}}={{$v}}<br>{{end}}</td>
+                <td>{{range $k, $v := .Labels}}{{$k}}={{$v}}<br>{{end}}</td>
                 <td>
                     <form action="/delete" method="POST" style="margin:0;">
                         <input type="hidden" name="name" value="{{.Name}}">
+                        {{if .Owner}}<input type="password" name="owner_token" placeholder="owner token">{{end}}
                         <button type="submit" class="delete">Delete</button>
                     </form>
+                    <a href="/hex?name={{.Name}}">Hex</a>
                 </td>
             </tr>
+            {{end}}
             {{else}}
-            <tr><td colspan="5">No secrets configured.</td></tr>
+            <tr><td colspan="8">No secrets configured.</td></tr>
             {{end}}
         </tbody>
     </table>
+    <form action="/clear" method="POST" onsubmit="return confirm('Delete every secret?');">
+        <input type="hidden" name="confirm" value="yes">
+        <input type="password" name="owner_token" placeholder="owner token, if some secrets have one" style="width:auto;">
+        <button type="submit" class="delete">Clear All</button>
+    </form>
 
     <hr>
 
@@ -205,18 +1182,97 @@ const adminHTML = `
             <label>File Name (e.g., database.yaml)</label>
             <input type="text" name="name" required placeholder="config.json">
         </div>
+        <div class="form-group">
+            <label>Type</label>
+            <select name="type">
+                <option value="static">static (content below)</option>
+                <option value="fileref">fileref (content is a node file path under FILEREF_ROOT, read on every mount)</option>
+                <option value="httpref">httpref (content is a URL fetched on every mount)</option>
+                <option value="variants">variants (content is a JSON array of values, one picked on every mount)</option>
+            </select>
+            <select name="variant_strategy">
+                <option value="">round-robin</option>
+                <option value="random">random</option>
+            </select>
+        </div>
         <div class="form-group">
             <label>Content</label>
             <textarea name="value" rows="4" required placeholder="super-secret-value"></textarea>
+            <select name="content_encoding">
+                <option value="plain">plain text</option>
+                <option value="base64">base64 (decoded and stored as raw bytes, for binary content)</option>
+            </select>
         </div>
         <div class="form-group">
             <label>Version (Arbitrary string, changes trigger rotation)</label>
             <input type="text" name="version" value="v1">
         </div>
+        <div class="form-group">
+            <label><input type="checkbox" name="rotate_on_mount" value="true" style="width:auto;"> Rotate on every mount (appends a counter to content and version)</label>
+        </div>
+        <div class="form-group">
+            <label><input type="checkbox" name="failing" value="true" style="width:auto;"> Failing (left out of mounts, which report the CSI_DEBUGGER_FAILING_OBJECT error)</label>
+        </div>
         <div class="form-group">
             <label>File Mode (Octal, e.g. 0644)</label>
             <input type="number" name="mode" value="420" placeholder="420 is 0644 decimal">
         </div>
+        <div class="form-group">
+            <label>Transform applied at mount time (empty uses the global default)</label>
+            <select name="transform">
+                <option value=""></option>
+                <option value="none">none</option>
+                <option value="base64">base64</option>
+                <option value="json-wrap">json-wrap</option>
+                <option value="template">template (e.g. {{"{{"}}.NodeName{{"}}"}})</option>
+            </select>
+        </div>
+        <div class="form-group">
+            <label>Service Account (only mounted into pods running as it, empty for every pod)</label>
+            <input type="text" name="service_account" placeholder="default">
+        </div>
+        <div class="form-group">
+            <label>Bundle (groups secrets enabled, disabled or deleted together)</label>
+            <input type="text" name="bundle" placeholder="app-a">
+        </div>
+        <div class="form-group">
+            <label>Mount As (file path in the mount if it differs from the name)</label>
+            <input type="text" name="mount_as" placeholder="password.txt">
+        </div>
+        <div class="form-group">
+            <label>TTL (the secret is deleted once it elapses, e.g. 30s or 5m, empty never expires)</label>
+            <input type="text" name="ttl" placeholder="5m">
+        </div>
+        <div class="form-group">
+            <label>Encoding and line ending of the mounted file</label>
+            <select name="encoding">
+                <option value="">raw</option>
+                <option value="utf8">utf8</option>
+                <option value="utf8-bom">utf8-bom</option>
+                <option value="utf16le">utf16le</option>
+            </select>
+            <select name="line_ending">
+                <option value="">unchanged</option>
+                <option value="lf">lf</option>
+                <option value="crlf">crlf</option>
+            </select>
+        </div>
+        <div class="form-group">
+            <label>Annotations (one key=value per line, not used for mounting)</label>
+            <textarea name="annotations" rows="2" placeholder="ticket=ABC-123"></textarea>
+        </div>
+        <div class="form-group">
+            <label>Labels (key=value pairs, mounts with a labels parameter only get matching secrets)</label>
+            <input type="text" name="labels" placeholder="app=frontend,env=test">
+        </div>
+        <div class="form-group">
+            <label>Owner (token then required to change or delete the secret, empty leaves it open or keeps the current owner)</label>
+            <input type="password" name="owner">
+        </div>
+        <div class="form-group">
+            <label>Owner token (required to change a secret that has an owner)</label>
+            <input type="password" name="owner_token">
+        </div>
         <button type="submit">Save Secret</button>
     </form>
     
@@ -224,7 +1280,7 @@ const adminHTML = `
     <h3>Bulk Upload (JSON)</h3>
     <form action="/bulk" method="POST">
         <div class="form-group">
-            <label>JSON Array [{"name": "x", "value": "y", "version": "1"}]</label>
+            <label>JSON Array [{"name": "x", "value": "y", "version": "1", "mode": "0600", "annotations": {"k": "v"}}], mode defaults to 0644</label>
             <textarea name="json_data" rows="4"></textarea>
         </div>
         <button type="submit">Upload Bulk</button>
@@ -234,22 +1290,71 @@ const adminHTML = `
 `
 
 type WebServer struct {
-	store  *MemoryStore
-	logger *slog.Logger
-	tmpl   *template.Template
+	store    *MemoryStore
+	metrics  *Metrics
+	provider *ProviderServer
+	logger   *slog.Logger
+	tmpl     *template.Template
+	flapper  *flapper
+	streams  *streamRegistry
+
+	// versionRE is the VERSION_VALIDATION format of versions, nil accepts any
+	versionRE *regexp.Regexp
+}
+
+// indexData is rendered by the admin template.
+type indexData struct {
+	Groups []secretGroup
+	Query  string
+	Masked bool
 }
 
-func NewWebServer(logger *slog.Logger, store *MemoryStore) (*WebServer, error) {
-	tmpl, err := template.New("index").Parse(adminHTML)
+func NewWebServer(logger *slog.Logger, store *MemoryStore, metrics *Metrics, provider *ProviderServer) (*WebServer, error) {
+	src := adminHTML
+	if path := provider.cfg.TemplatePath; path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TEMPLATE_PATH: %w", err)
+		}
+		src = string(b)
+	}
+	tmpl, err := template.New("index").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	versionRE, err := versionRegexp(provider.cfg.VersionValidation, provider.cfg.VersionPattern)
 	if err != nil {
 		return nil, err
 	}
-	return &WebServer{store: store, logger: logger, tmpl: tmpl}, nil
+	return &WebServer{
+		store:    store,
+		metrics:  metrics,
+		provider: provider,
+		logger:   logger,
+		tmpl:     tmpl,
+		flapper:  newFlapper(logger, provider.cfg.ReadyzFlapPeriod, provider.cfg.ReadyzFlapDuty),
+		streams:  newStreamRegistry(),
+
+		versionRE: versionRE,
+	}, nil
 }
 
 func (w *WebServer) handleIndex(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
 	secrets := w.store.List()
-	if err := w.tmpl.Execute(rw, secrets); err != nil {
+	if query != "" {
+		filtered := secrets[:0]
+		for _, sec := range secrets {
+			if sec.Matches(query) {
+				filtered = append(filtered, sec)
+			}
+		}
+		secrets = filtered
+	}
+	// mask hides values during screen shares, the template replaces them with dots
+	masked, _ := strconv.ParseBool(r.URL.Query().Get("mask"))
+	data := indexData{groupByBundle(w.views(secrets, true)), query, masked}
+	if err := w.tmpl.Execute(rw, data); err != nil {
 		w.logger.Error("failed to render template", "error", err)
 		http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
 	}
@@ -266,19 +1371,89 @@ func (w *WebServer) handleUpdate(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	name := r.FormValue("name")
-	value := r.FormValue("value")
+	value, err := decodeContent(r.FormValue("value"), r.FormValue("content_encoding"))
+	if err != nil {
+		w.reject(rw, reasonEncoding, err.Error())
+		return
+	}
+	if err := w.checkSize(value); err != nil {
+		w.reject(rw, reasonSize, err.Error())
+		return
+	}
 	version := r.FormValue("version")
+	if err := w.checkVersion(version); err != nil {
+		w.reject(rw, reasonVersion, err.Error())
+		return
+	}
 
 	// Default mode 0644 (decimal 420)
 	mode := int32(420)
-	// You could parse r.FormValue("mode") here if you want robust handling
+	if v := r.FormValue("mode"); v != "" {
+		m, err := parseMode(v)
+		if err != nil {
+			w.reject(rw, reasonMode, err.Error())
+			return
+		}
+		mode = m
+	}
 
 	if name == "" || value == "" {
-		http.Error(rw, "Name and Value required", http.StatusBadRequest)
+		w.reject(rw, reasonRequired, "Name and Value required")
+		return
+	}
+
+	annotations, err := parseAnnotations(r.FormValue("annotations"))
+	if err != nil {
+		w.reject(rw, reasonAnnotations, err.Error())
+		return
+	}
+	labels, err := parseLabels(r.FormValue("labels"))
+	if err != nil {
+		w.reject(rw, reasonLabels, err.Error())
+		return
+	}
+
+	rotate, _ := strconv.ParseBool(r.FormValue("rotate_on_mount"))
+	failing, _ := strconv.ParseBool(r.FormValue("failing"))
+
+	ttl, err := parseTTL(r.FormValue("ttl"))
+	if err != nil {
+		w.reject(rw, reasonTTL, err.Error())
 		return
 	}
 
-	w.store.Set(name, value, version, mode)
+	sec := Secret{
+		Name:            name,
+		Value:           value,
+		Version:         version,
+		Mode:            mode,
+		Annotations:     annotations,
+		Labels:          labels,
+		Transform:       r.FormValue("transform"),
+		RotateOnMount:   rotate,
+		Failing:         failing,
+		ServiceAccount:  r.FormValue("service_account"),
+		Bundle:          r.FormValue("bundle"),
+		Type:            r.FormValue("type"),
+		VariantStrategy: r.FormValue("variant_strategy"),
+		MountAs:         r.FormValue("mount_as"),
+		Encoding:        r.FormValue("encoding"),
+		LineEnding:      r.FormValue("line_ending"),
+		Owner:           r.FormValue("owner"),
+		ExpiresAt:       w.store.expiresAt(ttl),
+	}
+	// The checks shared with the other write paths, httpref URLs and variants included
+	if err := validateSecret(sec); err != nil {
+		w.reject(rw, rejectionReason(err), err.Error())
+		return
+	}
+	err = w.store.Owned(ownerToken(r), fixedNames(name), func() bool {
+		w.store.putLocked(sec)
+		return true
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
 	w.logger.Info("Secret added/updated via UI", "name", name, "version", version)
 	http.Redirect(rw, r, "/", http.StatusSeeOther)
 }
@@ -289,7 +1464,12 @@ func (w *WebServer) handleDelete(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 	name := r.FormValue("name")
-	w.store.Delete(name)
+	err := w.store.Owned(ownerToken(r), fixedNames(name), func() bool {
+		return w.store.deleteLocked(name)
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
 	w.logger.Info("Secret deleted via UI", "name", name)
 	http.Redirect(rw, r, "/", http.StatusSeeOther)
 }
@@ -300,64 +1480,267 @@ func (w *WebServer) handleBulk(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 	data := r.FormValue("json_data")
-
-	var items []struct {
-		Name    string `json:"name"`
-		Value   string `json:"value"`
-		Version string `json:"version"`
+	// Scripts choosing a collision policy get a JSON summary, the UI goes back to the list
+	policy := r.FormValue("collisionPolicy")
+	wantSummary := policy != ""
+	if policy == "" {
+		policy = CollisionOverwrite
+	}
+	if !validCollisionPolicy(policy) {
+		w.reject(rw, reasonInvalid, "Invalid collision policy")
+		return
 	}
 
+	var items []bulkItem
 	if err := json.Unmarshal([]byte(data), &items); err != nil {
 		w.logger.Error("Bulk upload failed", "error", err)
-		http.Error(rw, "Invalid JSON", http.StatusBadRequest)
+		w.reject(rw, reasonJSON, "Invalid JSON")
 		return
 	}
 
-	for _, i := range items {
-		w.store.Set(i.Name, i.Value, i.Version, 420)
+	// Every item is checked before anything is applied, all the invalid ones are reported
+	secrets := make([]Secret, 0, len(items))
+	var failures []bulkItemError
+	for n, i := range items {
+		sec, err := i.secret()
+		if err == nil {
+			err = w.checkVersion(sec.Version)
+		}
+		if err == nil {
+			err = w.checkSize(sec.Value)
+		}
+		if err != nil {
+			failures = append(failures, bulkItemError{Index: n, Name: i.Name, Reason: rejectionReason(err), Error: err.Error()})
+			continue
+		}
+		secrets = append(secrets, sec)
+	}
+	if len(failures) > 0 {
+		// 413 when oversized values are all that's wrong with the upload
+		code := http.StatusRequestEntityTooLarge
+		for _, f := range failures {
+			w.metrics.validationRejections.WithLabelValues(f.Reason).Inc()
+			if f.Reason != reasonSize {
+				code = http.StatusBadRequest
+			}
+		}
+		w.logger.Warn("Bulk upload rejected", "count", len(items), "invalid", len(failures))
+		w.writeJSON(rw, code, map[string]any{
+			"error": fmt.Sprintf("%d of %d items are invalid, nothing was applied", len(failures), len(items)),
+			"items": failures,
+		})
+		return
 	}
 
-	w.logger.Info("Bulk secrets imported", "count", len(items))
-	http.Redirect(rw, r, "/", http.StatusSeeOther)
+	if err := checkImport(secrets, policy); err != nil {
+		w.reject(rw, rejectionReason(err), err.Error())
+		return
+	}
+	// A dry run goes through the same checks and policy but leaves the store untouched
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+	// All or nothing, pods never see a half applied import
+	var summary ImportSummary
+	var importErr error
+	err := w.store.Owned(ownerToken(r), fixedNames(secretNames(secrets)...), func() bool {
+		if dryRun {
+			summary, _, importErr = w.store.planImportLocked(secrets, policy, w.checkVersion)
+			return false
+		}
+		summary, importErr = w.store.importLocked(secrets, policy, w.checkVersion)
+		return len(summary.Written()) > 0
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if importErr != nil {
+		w.reject(rw, rejectionReason(importErr), importErr.Error())
+		return
+	}
+	if dryRun {
+		w.logger.Info("Bulk dry run", "count", len(secrets), "policy", policy, "created", len(summary.Created),
+			"overwritten", len(summary.Overwritten), "skipped", len(summary.Skipped), "bumped", len(summary.Bumped))
+		w.writeJSON(rw, http.StatusOK, summary)
+		return
+	}
+	if !wantSummary {
+		w.logger.Info("Bulk secrets imported", "count", len(secrets))
+		http.Redirect(rw, r, "/", http.StatusSeeOther)
+		return
+	}
+	w.logger.Info("Bulk secrets imported", "count", len(secrets), "policy", policy)
+	w.writeJSON(rw, http.StatusOK, summary)
 }
 
 func (w *WebServer) RegisterHandlers(mux *http.ServeMux) {
-	mux.HandleFunc("/", w.handleIndex)
-	mux.HandleFunc("/update", w.handleUpdate)
-	mux.HandleFunc("/delete", w.handleDelete)
-	mux.HandleFunc("/bulk", w.handleBulk)
+	// Quick handlers answer 503 past HTTP_HANDLER_TIMEOUT, every handler but the liveness
+	// probe requires ADMIN_TOKEN when set
+	handle := func(pattern string, h http.Handler) {
+		mux.Handle(pattern, w.requireAdmin(w.withTimeout(h)))
+	}
+	handleFunc := func(pattern string, h http.HandlerFunc) {
+		mux.Handle(pattern, w.requireAdmin(h))
+	}
+	handle("GET /{$}", http.HandlerFunc(w.handleIndex))
+	handle("/", http.HandlerFunc(w.handleNotFound))
+	handle("GET /favicon.ico", staticHandler())
+	handle("GET /static/", http.StripPrefix("/static/", staticHandler()))
+	handle("/update", w.mutating(w.handleUpdate))
+	handle("/delete", w.mutating(w.handleDelete))
+	handle("/bulk", w.mutatingDryRun(w.handleBulk))
+	handle("POST /clear", w.mutating(w.handleClear))
+	handle("GET /hex", http.HandlerFunc(w.handleHexEditor))
+	handle("POST /hex", w.mutating(w.handleHexSave))
+	handle("GET /api/secrets", http.HandlerFunc(w.handleListSecrets))
+	handle("POST /api/secrets", w.mutating(w.handleCreateSecret))
+	handle("GET /api/secrets/{name}", http.HandlerFunc(w.handleGetSecret))
+	handle("GET /api/secrets/{name}/history", http.HandlerFunc(w.handleSecretHistory))
+	handle("PUT /api/secrets/{name}", w.mutating(w.handleReplaceSecret))
+	handle("DELETE /api/secrets/{name}", w.mutating(w.handleDeleteSecret))
+	handle("PATCH /api/secrets/{name}", w.mutating(w.handlePatchSecret))
+	handle("POST /api/secrets/{name}/scope", w.mutating(w.handleScope))
+	handle("POST /api/secrets/{name}/chmod", w.mutating(w.handleChmod))
+	handle("GET /api/freeze", http.HandlerFunc(w.handleFreezeState))
+	handle("POST /api/freeze", http.HandlerFunc(w.handleFreeze))
+	handle("POST /api/unfreeze", http.HandlerFunc(w.handleUnfreeze))
+	handle("POST /api/import/dotenv", w.mutating(w.handleImportDotenv))
+	handle("POST /api/secrets/generate-tls", w.mutating(w.handleGenerateTLS))
+	handle("POST /api/bundles/{name}/enable", w.mutating(w.handleBundleEnable))
+	handle("POST /api/bundles/{name}/disable", w.mutating(w.handleBundleDisable))
+	handle("POST /api/bundles/{name}/delete", w.mutating(w.handleBundleDelete))
+	handle("GET /api/backups", http.HandlerFunc(w.handleBackups))
+	handle("POST /api/restore-backup", w.mutating(w.handleRestoreBackup))
+	mux.Handle("GET /healthz", w.withTimeout(http.HandlerFunc(w.handleHealthz)))
+	handle("GET /readyz", http.HandlerFunc(w.handleReadyz))
+	handle("GET /api/stats", http.HandlerFunc(w.handleStats))
+	handle("GET /api/logs", http.HandlerFunc(w.handleLogs))
+	handle("GET /requests", http.HandlerFunc(w.handleRequestsPage))
+	handle("GET /api/requests", http.HandlerFunc(w.handleListRequests))
+	handle("GET /api/grpc/conns", http.HandlerFunc(w.handleGRPCConns))
+	handle("GET /api/version-fault", http.HandlerFunc(w.handleGetVersionFault))
+	handle("PUT /api/version-fault", http.HandlerFunc(w.handleSetVersionFault))
+	handle("GET /api/version-info", http.HandlerFunc(w.handleGetVersionInfo))
+	handle("PUT /api/version-info", http.HandlerFunc(w.handleSetVersionInfo))
+	handle("GET /fault", http.HandlerFunc(w.handleGetMountFault))
+	handle("POST /fault", http.HandlerFunc(w.handleSetMountFault))
+	handle("GET /delay", http.HandlerFunc(w.handleGetMountDelay))
+	handle("POST /delay", http.HandlerFunc(w.handleSetMountDelay))
+	handle("GET /loglevel", http.HandlerFunc(w.handleGetLogLevel))
+	handle("POST /loglevel", http.HandlerFunc(w.handleSetLogLevel))
+	handle("GET /api/mode-preview", http.HandlerFunc(w.handleModePreview))
+	handle("GET /metrics", w.metrics.Handler())
+
+	// Long running handlers bound their own duration
+	handleFunc("GET /api/secrets/{name}/wait", w.handleWait)
+	handleFunc("POST /api/loadtest", w.handleLoadTest)
+	handleFunc("GET /api/events", w.handleEvents)
 }
 
 func main() {
+	// Subcommands talk to a running instance instead of starting the servers
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	var cfg Config
 	if err := env.Parse(&cfg); err != nil {
 		fmt.Printf("failed to parse config: %+v\n", err)
 		os.Exit(1)
 	}
 
+	cfg.InstanceID = resolveInstanceID(cfg)
 	logger := createLogger(cfg, appName)
 	slog.SetDefault(logger)
 
+	if !validTransform(cfg.ContentTransform) {
+		logger.Error("invalid CONTENT_TRANSFORM", "transform", cfg.ContentTransform, "valid", transforms)
+		os.Exit(1)
+	}
+
+	if _, err := parseFaultMode(cfg.FaultMode); err != nil {
+		logger.Error("invalid FAULT_MODE", "mode", cfg.FaultMode, "error", err)
+		os.Exit(1)
+	}
+
+	if !validSeedingMode(cfg.MountWhileSeeding) {
+		logger.Error("invalid MOUNT_WHILE_SEEDING", "mode", cfg.MountWhileSeeding, "valid", []string{SeedingServe, SeedingUnavailable, SeedingPlaceholder})
+		os.Exit(1)
+	}
+
+	if !validOversizePolicy(cfg.OversizePolicy) {
+		logger.Error("invalid OVERSIZE_POLICY", "policy", cfg.OversizePolicy, "valid", []string{OversizeSkip, OversizeTruncate})
+		os.Exit(1)
+	}
+
+	if cfg.VersionJitter < 0 || cfg.VersionJitter > 1 {
+		logger.Error("invalid VERSION_JITTER, must be between 0 and 1", "jitter", cfg.VersionJitter)
+		os.Exit(1)
+	}
+
+	if cfg.ReadyzFlapDuty < 0 || cfg.ReadyzFlapDuty > 1 {
+		logger.Error("invalid READYZ_FLAP_DUTY, must be between 0 and 1", "duty", cfg.ReadyzFlapDuty)
+		os.Exit(1)
+	}
+
+	if !validLogFormat(cfg.LogFormat) {
+		logger.Error("invalid LOG_FORMAT", "format", cfg.LogFormat, "valid", []string{LogFormatText, LogFormatJSON})
+		os.Exit(1)
+	}
+
+	if !validCompression(cfg.GRPCCompression) {
+		logger.Error("invalid GRPC_COMPRESSION", "compression", cfg.GRPCCompression, "valid", compressions)
+		os.Exit(1)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	logger.Info("Starting CSI Debugger", "http_port", cfg.HTTPPort, "socket", cfg.SocketPath)
-
-	store := NewMemoryStore()
-
-	// Pre-populate a dummy secret
-	store.Set("debug-secret.txt", "Initial value loaded at startup", "v1", 420)
+	checkSocketDir(logger, cfg)
+
+	store := NewMemoryStore(logger, cfg)
+	metrics := NewMetrics(cfg.InstanceID)
+	metrics.RegisterStore(store)
+	provider := NewProviderServer(logger, cfg, store, metrics)
+
+	if cfg.RequireSeed {
+		// Remove a stale file so waiters don't proceed before this run is seeded
+		if err := os.Remove(cfg.SeedReadyFile); err != nil && !os.IsNotExist(err) {
+			logger.Error("failed to remove SEED_READY_FILE", "file", cfg.SeedReadyFile, "error", err)
+			os.Exit(1)
+		}
+		store.seeding.Store(true)
+	} else if err := seedStore(logger, cfg, store); err != nil {
+		logger.Error("failed to seed store", "error", err)
+		os.Exit(1)
+	}
 
 	g, ctx := errgroup.WithContext(ctx)
 
+	// Seed with the servers up, /readyz answering 503 until done
+	if cfg.RequireSeed {
+		g.Go(func() error {
+			return seedAndSignal(logger, cfg, store)
+		})
+	}
+
 	// Start gRPC Provider Server (Unix Domain Socket)
 	g.Go(func() error {
-		return startGRPCServer(ctx, logger, cfg, store)
+		return startGRPCServer(ctx, logger, cfg, provider)
+	})
+
+	// Flush the store to PERSIST_FILE on changes
+	g.Go(func() error {
+		return store.RunPersist(ctx)
+	})
+
+	// Delete secrets past their TTL
+	g.Go(func() error {
+		return store.RunExpiry(ctx)
 	})
 
 	// Start HTTP Admin Server
 	g.Go(func() error {
-		return startHTTPServer(ctx, logger, cfg, store)
+		return startHTTPServer(ctx, logger, cfg, store, metrics, provider)
 	})
 
 	// Handle Signals
@@ -372,14 +1755,72 @@ func main() {
 		logger.Warn("context cancelled, starting graceful shutdown")
 	}
 
-	if err := g.Wait(); err != nil && err != context.Canceled && err != http.ErrServerClosed {
+	err := g.Wait()
+	if cerr := store.Close(); cerr != nil {
+		logger.Error("failed to clean up store", "error", cerr)
+	}
+	if err != nil && err != context.Canceled && err != http.ErrServerClosed {
 		logger.Error("server group returned an error", "error", err)
 		os.Exit(2)
 	}
 	logger.Info("debugger shut down gracefully")
 }
 
-func startGRPCServer(ctx context.Context, logger *slog.Logger, cfg Config, store *MemoryStore) error {
+// newGRPCServer returns the provider gRPC server with its interceptors, not yet serving.
+func newGRPCServer(logger *slog.Logger, cfg Config, providerSrv *ProviderServer) *grpc.Server {
+	var interceptors []grpc.UnaryServerInterceptor
+	if cfg.RecoverPanics {
+		interceptors = append(interceptors, recoveryInterceptor(logger))
+	}
+	interceptors = append(interceptors,
+		deadlineInterceptor(logger, cfg.GRPCDefaultDeadline),
+		compressionInterceptor(logger, cfg.GRPCCompression),
+	)
+	if cfg.AccessLog {
+		// Outermost but tracing, so the logged code is the one the driver gets
+		interceptors = append([]grpc.UnaryServerInterceptor{accessLogInterceptor(logger)}, interceptors...)
+	}
+	if cfg.Tracing {
+		interceptors = append([]grpc.UnaryServerInterceptor{tracingInterceptor()}, interceptors...)
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.StatsHandler(providerSrv.conns),
+	)
+	v1alpha1.RegisterCSIDriverProviderServer(grpcServer, providerSrv)
+	if cfg.GRPCReflection {
+		reflection.Register(grpcServer)
+	}
+	return grpcServer
+}
+
+// listenUnlessCancelled binds address unless ctx is already cancelled, e.g. by the other
+// server failing to start, in which case it returns a nil listener. A listener bound
+// while ctx got cancelled is closed rather than leaked.
+func listenUnlessCancelled(ctx context.Context, network, address string) (net.Listener, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		lis.Close()
+		return nil, nil
+	}
+	return lis, nil
+}
+
+func startGRPCServer(ctx context.Context, logger *slog.Logger, cfg Config, providerSrv *ProviderServer) error {
+	return superviseGRPC(ctx, logger, cfg.GRPCRestartAttempts, cfg.GRPCRestartBackoff, func(ctx context.Context) error {
+		return serveGRPC(ctx, logger, cfg, providerSrv)
+	})
+}
+
+// serveGRPC listens on the provider socket and serves until ctx is cancelled or Serve fails.
+func serveGRPC(ctx context.Context, logger *slog.Logger, cfg Config, providerSrv *ProviderServer) error {
 	// Cleanup old socket
 	if err := os.Remove(cfg.SocketPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove existing socket: %w", err)
@@ -389,10 +1830,28 @@ func startGRPCServer(ctx context.Context, logger *slog.Logger, cfg Config, store
 		return fmt.Errorf("failed to create socket directory: %w", err)
 	}
 
-	lis, err := net.Listen("unix", cfg.SocketPath)
+	lis, err := listenUnlessCancelled(ctx, "unix", cfg.SocketPath)
 	if err != nil {
 		return fmt.Errorf("gRPC server failed to listen on unix socket: %w", err)
 	}
+	if lis == nil {
+		logger.Info("startup cancelled, not starting the gRPC server")
+		return nil
+	}
+
+	var tcpLis net.Listener
+	if cfg.GRPCTCPAddr != "" {
+		tcpLis, err = listenUnlessCancelled(ctx, "tcp", cfg.GRPCTCPAddr)
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("gRPC server failed to listen on %s: %w", cfg.GRPCTCPAddr, err)
+		}
+		if tcpLis == nil {
+			lis.Close()
+			logger.Info("startup cancelled, not starting the gRPC server")
+			return nil
+		}
+	}
 
 	// Set socket permissions to allow any user to connect (required for secrets-store-csi-driver)
 	// Using 0777 to ensure read, write, and execute permissions for all users
@@ -403,64 +1862,185 @@ func startGRPCServer(ctx context.Context, logger *slog.Logger, cfg Config, store
 		logger.Info("set socket permissions to 0777")
 	}
 
-	grpcServer := grpc.NewServer()
-	providerSrv := &ProviderServer{store: store, logger: logger}
-
-	v1alpha1.RegisterCSIDriverProviderServer(grpcServer, providerSrv)
-
-	// Create a health check function if strictly required by the driver,
-	// though usually Version() is enough for the driver's health check.
+	grpcServer := newGRPCServer(logger, cfg, providerSrv)
+	hs := registerHealth(ctx, logger, grpcServer, providerSrv.store)
+
+	if cfg.ServeDelay > 0 {
+		logger.Warn("gRPC socket created, delaying serving (SERVE_DELAY)", "address", cfg.SocketPath, "delay", cfg.ServeDelay)
+		t := time.NewTimer(cfg.ServeDelay)
+		select {
+		case <-t.C:
+			logger.Info("gRPC serve delay elapsed", "delay", cfg.ServeDelay)
+		case <-ctx.Done():
+			t.Stop()
+			lis.Close()
+			if tcpLis != nil {
+				tcpLis.Close()
+			}
+			return nil
+		}
+	}
 
 	logger.Info("gRPC Provider server listening", "address", cfg.SocketPath)
 
-	go func() {
-		<-ctx.Done()
+	stop := context.AfterFunc(ctx, func() {
 		logger.Info("shutting down gRPC server")
+		// Probes answered while draining see NOT_SERVING
+		hs.Shutdown()
 		grpcServer.GracefulStop()
-	}()
-
-	return grpcServer.Serve(lis)
+	})
+	defer stop()
+
+	// Both listeners share grpcServer, GracefulStop drains and closes them together
+	tcpErr := make(chan error, 1)
+	if tcpLis != nil {
+		logger.Info("gRPC Provider server listening", "address", tcpLis.Addr().String())
+		go func() {
+			err := grpcServer.Serve(tcpLis)
+			if err != nil {
+				// Restart the whole server rather than serve on the socket alone
+				logger.Error("gRPC TCP listener failed", "address", cfg.GRPCTCPAddr, "error", err)
+				grpcServer.Stop()
+			}
+			tcpErr <- err
+		}()
+	}
+	err = grpcServer.Serve(lis)
+	if tcpLis != nil {
+		if err != nil {
+			grpcServer.Stop()
+		}
+		if e := <-tcpErr; err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
-func startHTTPServer(ctx context.Context, logger *slog.Logger, cfg Config, store *MemoryStore) error {
-	webServer, err := NewWebServer(logger, store)
+func startHTTPServer(ctx context.Context, logger *slog.Logger, cfg Config, store *MemoryStore, metrics *Metrics, provider *ProviderServer) error {
+	webServer, err := NewWebServer(logger, store, metrics, provider)
 	if err != nil {
 		return err
 	}
 
+	// Load the key pair upfront, a bad one fails startup instead of every handshake
+	var tlsConfig *tls.Config
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load the admin server TLS key pair: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
 	mux := http.NewServeMux()
 	webServer.RegisterHandlers(mux)
 
+	var handler http.Handler = mux
+	handler = headersMiddleware(cfg.AdminHeaders, handler)
+	if cfg.RecoverPanics {
+		handler = recoverMiddleware(logger, handler)
+	}
+	if cfg.AccessLog {
+		handler = accessLogMiddleware(logger, handler)
+	}
+
 	addr := fmt.Sprintf(":%d", cfg.HTTPPort)
-	server := &http.Server{
-		Addr:    addr,
-		Handler: mux,
+	server := newHTTPServer(cfg, addr, handler)
+	server.TLSConfig = tlsConfig
+	// Shutdown waits for the event streams to send their close event and return
+	server.RegisterOnShutdown(webServer.streams.Close)
+
+	lis, err := listenUnlessCancelled(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if lis == nil {
+		logger.Info("startup cancelled, not starting the HTTP admin server")
+		return nil
 	}
 
-	logger.Info("HTTP Admin server listening", "address", addr)
+	logger.Info("HTTP Admin server listening", "address", addr, "tls", tlsConfig != nil)
 
 	go func() {
 		<-ctx.Done()
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPShutdownTimeout)
 		defer cancel()
 		if err := server.Shutdown(shutdownCtx); err != nil {
-			logger.Error("HTTP server shutdown error", "error", err)
+			logger.Error("HTTP server shutdown error", "error", err, "open_streams", webServer.streams.Active())
 		}
 	}()
 
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	if tlsConfig != nil {
+		// The certificate is already in TLSConfig
+		err = server.ServeTLS(lis, "", "")
+	} else {
+		err = server.Serve(lis)
+	}
+	if err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// newHTTPServer returns the admin server with the configured timeouts and header limit.
+func newHTTPServer(cfg Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:           addr,
+		Handler:        handler,
+		ReadTimeout:    cfg.HTTPReadTimeout,
+		WriteTimeout:   cfg.HTTPWriteTimeout,
+		IdleTimeout:    cfg.HTTPIdleTimeout,
+		MaxHeaderBytes: cfg.HTTPMaxHeaderBytes,
+	}
+}
+
+// Log line formats of LOG_FORMAT.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+func validLogFormat(f string) bool {
+	return f == LogFormatText || f == LogFormatJSON
+}
+
+// newLogHandler returns the handler writing log lines of level or above to w in
+// LOG_FORMAT, an invalid format falling back to text.
+func newLogHandler(w io.Writer, cfg Config, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level:     level,
+		AddSource: cfg.LogSource,
+	}
+	if cfg.LogFormat == LogFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
 func createLogger(cfg Config, appName string) *slog.Logger {
-	level := slog.LevelInfo
-	if cfg.LogLevel == "DEBUG" {
-		level = slog.LevelDebug
+	level, _ := parseLogLevel(cfg.LogLevel)
+	logLevel.Set(level)
+	handler := newLogHandler(os.Stdout, cfg, &logLevel)
+	// Retaining outermost lets the admin server find the ring from its logger
+	if cfg.LogBufferSize > 0 {
+		handler = &ringHandler{ring: newLogRing(cfg.LogBufferSize), next: handler}
 	}
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}).WithAttrs([]slog.Attr{slog.String("app", appName)})
+	handler = handler.WithAttrs([]slog.Attr{slog.String("app", appName), slog.String("instance_id", cfg.InstanceID)})
 	return slog.New(handler)
 }
+
+// resolveInstanceID returns INSTANCE_ID, defaulting to the node name then the hostname.
+func resolveInstanceID(cfg Config) string {
+	if cfg.InstanceID != "" {
+		return cfg.InstanceID
+	}
+	if cfg.NodeName != "" {
+		return cfg.NodeName
+	}
+	host, _ := os.Hostname()
+	return host
+}
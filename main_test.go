@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newTestWeb returns a WebServer over store, with its own metrics and provider.
+func newTestWeb(t *testing.T, cfg Config, store *MemoryStore) *WebServer {
+	t.Helper()
+	metrics := NewMetrics("")
+	web, err := NewWebServer(testLogger(), store, metrics, NewProviderServer(testLogger(), cfg, store, metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return web
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[string]string{
+		"config.json":       "config.json",
+		"Config.JSON":       "config.json",
+		"  Config.json  ":   "config.json",
+		"./Config.json":     "config.json",
+		"dir//Sub/../a.txt": "dir/a.txt",
+		"/abs/Path.txt":     "abs/path.txt",
+	}
+	for in, want := range tests {
+		if got := normalizeName(in); got != want {
+			t.Errorf("normalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMemoryStoreNameNormalize(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{NameNormalize: true})
+	store.Set("config.json", "a", "v1", 420)
+	store.Set("Config.json", "b", "v2", 420)
+
+	list := store.List()
+	if len(list) != 1 {
+		t.Fatalf("expected names to collapse into 1 secret, got %d", len(list))
+	}
+	if list[0].Name != "config.json" || list[0].Value != "b" || list[0].Version != "v2" {
+		t.Errorf("unexpected secret after collision: %+v", list[0])
+	}
+
+	store.Delete("CONFIG.JSON")
+	if n := len(store.List()); n != 0 {
+		t.Errorf("expected normalized delete to remove secret, %d left", n)
+	}
+}
+
+func TestMemoryStoreNameNormalizeDisabled(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("config.json", "a", "v1", 420)
+	store.Set("Config.json", "b", "v1", 420)
+
+	if n := len(store.List()); n != 2 {
+		t.Fatalf("expected exact names to be kept, got %d secrets", n)
+	}
+}
+
+func TestBulkDryRun(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("same.txt", "same", "v1", 420)
+	store.Set("changed.txt", "old", "v1", 420)
+	store.Put(Secret{Name: "owned.txt", Value: "o", Version: "v1", Owner: "secret-a"})
+
+	web := newTestWeb(t, Config{}, store)
+	dryRun := func(data, policy string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"json_data": {data}, "collisionPolicy": {policy}}
+		req := httptest.NewRequest(http.MethodPost, "/bulk?dryRun=true", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		web.handleBulk(rec, req)
+		return rec
+	}
+
+	data := `[{"name":"same.txt","value":"same","version":"v1"},
+		{"name":"changed.txt","value":"new","version":"v2"},
+		{"name":"added.txt","value":"x","version":"v1"}]`
+	for policy, want := range map[string]ImportSummary{
+		CollisionOverwrite:   {Created: []string{"added.txt"}, Overwritten: []string{"same.txt", "changed.txt"}, Skipped: []string{}, Bumped: []string{}},
+		CollisionSkip:        {Created: []string{"added.txt"}, Overwritten: []string{}, Skipped: []string{"same.txt", "changed.txt"}, Bumped: []string{}},
+		CollisionVersionBump: {Created: []string{"added.txt"}, Overwritten: []string{}, Skipped: []string{}, Bumped: []string{"same.txt", "changed.txt"}},
+	} {
+		rec := dryRun(data, policy)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", policy, rec.Code, rec.Body.String())
+		}
+		var summary ImportSummary
+		if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(summary, want) {
+			t.Errorf("%s: unexpected summary: %+v", policy, summary)
+		}
+	}
+
+	// Previews fail where the import would
+	if rec := dryRun(data, CollisionError); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a collision with the error policy, got %d", rec.Code)
+	}
+	if rec := dryRun(`[{"name":"x.txt","value":"not a url","type":"httpref"}]`, CollisionOverwrite); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid item, got %d", rec.Code)
+	}
+	if rec := dryRun(`[{"name":"owned.txt","value":"x"}]`, CollisionOverwrite); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the owner token, got %d", rec.Code)
+	}
+
+	list := store.List()
+	if len(list) != 3 {
+		t.Fatalf("dry run mutated the store: %+v", list)
+	}
+	if sec, _ := store.Get("changed.txt"); sec.Value != "old" || sec.Version != "v1" {
+		t.Errorf("dry run updated a secret: %+v", sec)
+	}
+}
+
+func TestBulkMode(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	bulk := func(data string) int {
+		form := url.Values{"json_data": {data}}
+		req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		web.handleBulk(rec, req)
+		return rec.Code
+	}
+
+	if code := bulk(`[{"name":"octal.txt","value":"x","mode":"0600"},{"name":"decimal.txt","value":"x","mode":384},{"name":"default.txt","value":"x"}]`); code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d", code)
+	}
+	for name, want := range map[string]int32{"octal.txt": 0o600, "decimal.txt": 0o600, "default.txt": 0o644} {
+		if sec, _ := store.Get(name); sec.Mode != want {
+			t.Errorf("%s: mode %#o, want %#o", name, sec.Mode, want)
+		}
+	}
+
+	if code := bulk(`[{"name":"ok.txt","value":"x","mode":"0640"},{"name":"bad.txt","value":"x","mode":"rwx"}]`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid mode, got %d", code)
+	}
+	if _, ok := store.Get("ok.txt"); ok {
+		t.Error("a batch with an invalid mode was partially applied")
+	}
+}
+
+func TestSecretAnnotations(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	annotations := map[string]string{"source": "prod-clone", "ticket": "ABC-123"}
+	store.Put(Secret{Name: "db.txt", Value: "pw", Version: "v1", Mode: 420, Annotations: annotations})
+
+	// Mutating the caller's map must not leak into the store
+	annotations["source"] = "changed"
+
+	sec, ok := store.Get("db.txt")
+	if !ok {
+		t.Fatal("secret not found")
+	}
+	want := map[string]string{"source": "prod-clone", "ticket": "ABC-123"}
+	if !reflect.DeepEqual(sec.Annotations, want) {
+		t.Errorf("annotations = %v, want %v", sec.Annotations, want)
+	}
+
+	if !sec.Matches("ticket=abc") || !sec.Matches("PROD") || sec.Matches("staging") {
+		t.Error("unexpected search match result on annotations")
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	got, err := parseAnnotations("source=prod-clone\n\n ticket = ABC-123 \n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"source": "prod-clone", "ticket": "ABC-123"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAnnotations = %v, want %v", got, want)
+	}
+
+	if _, err := parseAnnotations("missing-separator"); err == nil {
+		t.Error("expected an error for a line without '='")
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "a.txt", Value: "1", Annotations: map[string]string{"ticket": "ABC-123"}})
+	store.Put(Secret{Name: "b.txt", Value: "2"})
+	web := newTestWeb(t, Config{}, store)
+
+	rec := httptest.NewRecorder()
+	web.handleIndex(rec, httptest.NewRequest(http.MethodGet, "/?q=abc-123", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "a.txt") || strings.Contains(body, "b.txt") {
+		t.Errorf("unexpected search result (%d):\n%s", rec.Code, body)
+	}
+}
+
+func TestStartupCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	cfg := Config{HTTPPort: port, SocketPath: filepath.Join(t.TempDir(), "provider.sock")}
+	store := NewMemoryStore(testLogger(), cfg)
+	metrics := NewMetrics("")
+	provider := NewProviderServer(testLogger(), cfg, store, metrics)
+
+	if err := startGRPCServer(ctx, testLogger(), cfg, provider); err != nil {
+		t.Errorf("gRPC server: expected a clean abort, got %v", err)
+	}
+	if _, err := os.Stat(cfg.SocketPath); !os.IsNotExist(err) {
+		t.Errorf("expected no socket left behind, stat returned %v", err)
+	}
+
+	if err := startHTTPServer(ctx, testLogger(), cfg, store, metrics, provider); err != nil {
+		t.Errorf("HTTP server: expected a clean abort, got %v", err)
+	}
+	lis, err = net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		t.Fatalf("admin port still bound after a cancelled startup: %v", err)
+	}
+	lis.Close()
+}
+
+func TestGRPCTCPListener(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := Config{SocketPath: filepath.Join(t.TempDir(), "provider.sock"), GRPCTCPAddr: addr}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.Set("a.txt", "1", "v1", 420)
+	provider := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+	done := make(chan error, 1)
+	go func() { done <- startGRPCServer(ctx, testLogger(), cfg, provider) }()
+
+	for _, target := range []string{"unix://" + cfg.SocketPath, "passthrough:///" + addr} {
+		conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		mctx, mcancel := context.WithTimeout(ctx, 5*time.Second)
+		resp, err := v1alpha1.NewCSIDriverProviderClient(conn).Mount(mctx, &v1alpha1.MountRequest{}, grpc.WaitForReady(true))
+		mcancel()
+		if err != nil {
+			t.Fatalf("Mount through %s: %v", target, err)
+		}
+		if len(resp.Files) != 1 {
+			t.Errorf("Mount through %s: got %d files, want 1", target, len(resp.Files))
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("gRPC server did not stop")
+	}
+	lis, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("TCP address still bound after shutdown: %v", err)
+	}
+	lis.Close()
+}
+
+func TestServeDelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := Config{SocketPath: filepath.Join(t.TempDir(), "provider.sock"), ServeDelay: 500 * time.Millisecond}
+	provider := NewProviderServer(testLogger(), cfg, NewMemoryStore(testLogger(), cfg), NewMetrics(""))
+	done := make(chan error, 1)
+	go func() { done <- startGRPCServer(ctx, testLogger(), cfg, provider) }()
+
+	// The socket exists before the server answers
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(cfg.SocketPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("socket not created")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := grpc.NewClient("unix://"+cfg.SocketPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := v1alpha1.NewCSIDriverProviderClient(conn)
+
+	early, cancelEarly := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelEarly()
+	if _, err := client.Version(early, &v1alpha1.VersionRequest{}); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expected a call in the window to hang until its deadline, got %v", err)
+	}
+
+	late, cancelLate := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelLate()
+	if _, err := client.Version(late, &v1alpha1.VersionRequest{}, grpc.WaitForReady(true)); err != nil {
+		t.Errorf("expected an answer once the delay elapsed, got %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestHTTPServerTLS(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	dir := t.TempDir()
+	certPEM, keyPEM, err := generateTLS("localhost", time.Hour, KeyTypeECDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{HTTPPort: port, TLSCertFile: filepath.Join(dir, "tls.crt"), TLSKeyFile: filepath.Join(dir, "tls.key"), HTTPShutdownTimeout: time.Second}
+	if err := os.WriteFile(cfg.TLSCertFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.TLSKeyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore(testLogger(), cfg)
+	metrics := NewMetrics("")
+	provider := NewProviderServer(testLogger(), cfg, store, metrics)
+
+	// A bad key pair is a startup error, not a fallback to plain HTTP
+	bad := cfg
+	bad.TLSKeyFile = cfg.TLSCertFile
+	if err := startHTTPServer(context.Background(), testLogger(), bad, store, metrics, provider); err == nil {
+		t.Error("expected an error for an invalid key pair")
+	}
+	bad = cfg
+	bad.TLSKeyFile = ""
+	if err := startHTTPServer(context.Background(), testLogger(), bad, store, metrics, provider); err == nil {
+		t.Error("expected an error for a certificate without key")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- startHTTPServer(ctx, testLogger(), cfg, store, metrics, provider) }()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	endpoint := fmt.Sprintf("https://127.0.0.1:%d/healthz", port)
+	var resp *http.Response
+	for range 50 {
+		if resp, err = client.Get(endpoint); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a 200 over TLS, got %d (TLS %v)", resp.StatusCode, resp.TLS != nil)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("HTTPS server didn't shut down")
+	}
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(newLogHandler(&buf, Config{LogFormat: LogFormatJSON}, slog.LevelDebug).
+		WithAttrs([]slog.Attr{slog.String("app", "csi-debugger")}))
+	logger.Debug("secret expired", "name", "db.txt")
+
+	var line map[string]any
+	if err := json.Unmarshal([]byte(buf.String()), &line); err != nil {
+		t.Fatalf("expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+	if line["level"] != "DEBUG" || line["msg"] != "secret expired" || line["app"] != "csi-debugger" || line["name"] != "db.txt" {
+		t.Errorf("unexpected log line %v", line)
+	}
+
+	buf.Reset()
+	slog.New(newLogHandler(&buf, Config{LogFormat: LogFormatJSON}, slog.LevelInfo)).Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("expected debug records to be dropped at INFO, got %q", buf.String())
+	}
+}
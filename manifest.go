@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// ManifestEntry describes one served file in the mount manifest.
+type ManifestEntry struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Size    int    `json:"size"`
+	// Truncated is set when the file was cut to MAX_SECRET_BYTES
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Manifest lists every other file present in a mount, for consumers enumerating their secrets.
+type Manifest struct {
+	Files []ManifestEntry `json:"files"`
+	// Skipped lists the secrets left out for exceeding MAX_SECRET_BYTES
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// buildManifest returns a manifest file named name describing files and the oversize
+// ones, along with its object version which changes whenever the described set does.
+func buildManifest(name string, files []*v1alpha1.File, versions []*v1alpha1.ObjectVersion, oversize oversizeReport) (*v1alpha1.File, *v1alpha1.ObjectVersion, error) {
+	byID := make(map[string]string, len(versions))
+	for _, v := range versions {
+		byID[v.GetId()] = v.GetVersion()
+	}
+
+	m := Manifest{Files: make([]ManifestEntry, 0, len(files)), Skipped: oversize.Skipped}
+	for _, f := range files {
+		m.Files = append(m.Files, ManifestEntry{
+			Path:      f.GetPath(),
+			Version:   byID[f.GetPath()],
+			Size:      len(f.GetContents()),
+			Truncated: slices.Contains(oversize.Truncated, f.GetPath()),
+		})
+	}
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+
+	contents, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(contents)
+	return &v1alpha1.File{Path: name, Mode: 0o444, Contents: contents},
+		&v1alpha1.ObjectVersion{Id: name, Version: hex.EncodeToString(sum[:8])},
+		nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// maxServedNames caps the distinct name label values of csi_debugger_secret_served_total,
+// secrets served once the cap is reached are counted under otherServedName.
+const (
+	maxServedNames  = 100
+	otherServedName = "other"
+)
+
+// Metrics holds the Prometheus registry exposed on /metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+	// registerer adds the instance_id label, for collectors registered after NewMetrics
+	registerer prometheus.Registerer
+
+	mountInterval prometheus.Histogram
+	mountDuration prometheus.Histogram
+	mountCalls    prometheus.Counter
+	versionCalls  prometheus.Counter
+	secretServed  *prometheus.CounterVec
+	oversize      *prometheus.CounterVec
+	mountCache    *prometheus.CounterVec
+
+	validationRejections *prometheus.CounterVec
+
+	servedMu    sync.Mutex
+	servedNames map[string]struct{}
+}
+
+// NewMetrics returns the metrics of an instance, instanceID, when set, is added as the
+// instance_id label of every metric.
+func NewMetrics(instanceID string) *Metrics {
+	reg := prometheus.NewRegistry()
+	m := &Metrics{
+		registry: reg,
+		mountInterval: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "csi_debugger_mount_interval_seconds",
+			Help:    "Time between successive Mount calls for the same target path, i.e. the driver rotation poll interval.",
+			Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800},
+		}),
+		mountDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "csi_debugger_mount_duration_seconds",
+			Help:    "Time taken to answer Mount calls, with the caller trace as exemplar when TRACING is on.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		mountCalls: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "csi_debugger_mount_requests_total",
+			Help: "Number of Mount calls received.",
+		}),
+		versionCalls: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "csi_debugger_version_requests_total",
+			Help: "Number of Version calls received.",
+		}),
+		secretServed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csi_debugger_secret_served_total",
+			Help: "Number of times a secret was served by Mount, names past the cardinality cap are counted as \"other\".",
+		}, []string{"name"}),
+		servedNames: make(map[string]struct{}),
+		oversize: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csi_debugger_oversize_secrets_total",
+			Help: "Number of mounted files larger than MAX_SECRET_BYTES, by action taken (skip or truncate).",
+		}, []string{"action"}),
+		mountCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csi_debugger_mount_cache_total",
+			Help: "Mount response cache lookups by result (hit, miss, or bypass when the store holds secrets rendered differently on every mount).",
+		}, []string{"result"}),
+		validationRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "csi_debugger_validation_rejections_total",
+			Help: "Admin requests rejected for invalid input, by reason.",
+		}, []string{"reason"}),
+	}
+	var r prometheus.Registerer = reg
+	if instanceID != "" {
+		r = prometheus.WrapRegistererWith(prometheus.Labels{"instance_id": instanceID}, reg)
+	}
+	m.registerer = r
+	r.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.mountInterval,
+		m.mountDuration,
+		m.mountCalls,
+		m.versionCalls,
+		m.secretServed,
+		m.oversize,
+		m.mountCache,
+		m.validationRejections,
+	)
+	return m
+}
+
+// RegisterStore exposes the number of secrets in store as csi_debugger_secrets.
+func (m *Metrics) RegisterStore(store *MemoryStore) {
+	m.registerer.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "csi_debugger_secrets",
+		Help: "Number of secrets in the store, disabled ones included.",
+	}, func() float64 {
+		return float64(store.Len())
+	}))
+}
+
+// SecretServed counts one serve of the named secret. Only the first maxServedNames
+// distinct names get their own label value, to bound the series count.
+func (m *Metrics) SecretServed(name string) {
+	m.servedMu.Lock()
+	if _, ok := m.servedNames[name]; !ok {
+		if len(m.servedNames) >= maxServedNames {
+			name = otherServedName
+		} else {
+			m.servedNames[name] = struct{}{}
+		}
+	}
+	m.servedMu.Unlock()
+	m.secretServed.WithLabelValues(name).Inc()
+}
+
+// Handler serves the registry, using the OpenMetrics format (with exemplars) when the
+// scraper asks for application/openmetrics-text and the classic text format otherwise.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+}
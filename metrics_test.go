@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMetricsContentNegotiation(t *testing.T) {
+	handler := NewMetrics("").Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected classic text format by default, got %q", ct)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("expected OpenMetrics format, got %q", ct)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "# EOF\n") {
+		t.Error("OpenMetrics exposition should end with # EOF")
+	}
+}
+
+func TestSecretServedCardinality(t *testing.T) {
+	m := NewMetrics("")
+	for i := range maxServedNames + 5 {
+		m.SecretServed(fmt.Sprintf("s%d.txt", i))
+	}
+	m.SecretServed("s0.txt")
+
+	if got := testutil.ToFloat64(m.secretServed.WithLabelValues("s0.txt")); got != 2 {
+		t.Errorf("s0.txt served = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.secretServed.WithLabelValues(otherServedName)); got != 5 {
+		t.Errorf("other served = %v, want 5", got)
+	}
+	if n := testutil.CollectAndCount(m.secretServed); n != maxServedNames+1 {
+		t.Errorf("expected %d series, got %d", maxServedNames+1, n)
+	}
+}
+
+func TestMetricsInstanceID(t *testing.T) {
+	m := NewMetrics("node-a")
+	m.SecretServed("a.txt")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`csi_debugger_secret_served_total{instance_id="node-a",name="a.txt"} 1`,
+		`go_goroutines{instance_id="node-a"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in:\n%s", want, body)
+		}
+	}
+}
+
+func TestResolveInstanceID(t *testing.T) {
+	if got := resolveInstanceID(Config{InstanceID: "explicit", NodeName: "node-a"}); got != "explicit" {
+		t.Errorf("resolveInstanceID = %q, want explicit", got)
+	}
+	if got := resolveInstanceID(Config{NodeName: "node-a"}); got != "node-a" {
+		t.Errorf("resolveInstanceID = %q, want the node name", got)
+	}
+	if host, _ := os.Hostname(); resolveInstanceID(Config{}) != host {
+		t.Errorf("resolveInstanceID = %q, want the hostname %q", resolveInstanceID(Config{}), host)
+	}
+}
+
+func TestProviderCallMetrics(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v1", 420)
+	m := NewMetrics("")
+	m.RegisterStore(store)
+	srv := NewProviderServer(testLogger(), Config{}, store, m)
+
+	for range 3 {
+		if _, err := srv.Mount(t.Context(), &v1alpha1.MountRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := srv.Version(t.Context(), &v1alpha1.VersionRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.ToFloat64(m.mountCalls); got != 3 {
+		t.Errorf("mount calls = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(m.versionCalls); got != 1 {
+		t.Errorf("version calls = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(m.mountDuration); n != 1 {
+		t.Errorf("expected the Mount duration histogram, got %d series", n)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "csi_debugger_secrets 2\n") {
+		t.Errorf("expected the secret count gauge:\n%s", body)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// requestIDHeader carries the ID correlating an admin request with its access log record.
+const requestIDHeader = "X-Request-ID"
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the flusher of event streams.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLogMiddleware logs every request once answered, with its status and duration,
+// under a request ID echoed in X-Request-ID. A request ID sent by the client is kept.
+// Probes and metrics scrapes are logged at debug level.
+func accessLogMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = newUUID()
+		}
+		rw.Header().Set(requestIDHeader, id)
+		rec := &statusRecorder{ResponseWriter: rw}
+		defer func() {
+			level := slog.LevelInfo
+			switch r.URL.Path {
+			case "/healthz", "/readyz", "/metrics":
+				level = slog.LevelDebug
+			}
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			logger.Log(r.Context(), level, "HTTP request",
+				"request_id", id,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"duration", time.Since(start),
+				"remote", r.RemoteAddr,
+			)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// recoverMiddleware answers 500 when next panics, logging the stack trace
+// instead of letting the panic escape to net/http.
+func recoverMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				// Deliberate abort of the response, let net/http handle it
+				panic(p)
+			}
+			logger.Error("recovered from panic in HTTP handler",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", p,
+				"stack", string(debug.Stack()),
+			)
+			http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// headersMiddleware sets headers on every response before calling next.
+func headersMiddleware(headers map[string]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			rw.Header().Set(k, v)
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// withTimeout answers 503 when h runs longer than HTTP_HANDLER_TIMEOUT.
+func (w *WebServer) withTimeout(h http.Handler) http.Handler {
+	timeout := w.provider.cfg.HTTPHandlerTimeout
+	if timeout <= 0 {
+		return h
+	}
+	return http.TimeoutHandler(h, timeout, "handler timed out")
+}
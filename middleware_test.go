@@ -0,0 +1,146 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	handler := recoverMiddleware(testLogger(), http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 after panic, got %d", rec.Code)
+	}
+}
+
+func TestHeadersMiddleware(t *testing.T) {
+	var cfg Config
+	if err := env.ParseWithOptions(&cfg, env.Options{Environment: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	newTestWeb(t, cfg, NewMemoryStore(testLogger(), cfg)).RegisterHandlers(mux)
+	handler := headersMiddleware(cfg.AdminHeaders, mux)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	for k, v := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "no-store",
+		"X-Frame-Options":        "DENY",
+	} {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("header %s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestHandlerTimeout(t *testing.T) {
+	web := newTestWeb(t, Config{HTTPHandlerTimeout: 20 * time.Millisecond}, NewMemoryStore(testLogger(), Config{}))
+	slow := web.withTimeout(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			rw.Write([]byte("too late"))
+		case <-r.Context().Done():
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	slow.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 past the timeout, got %d", rec.Code)
+	}
+}
+
+func TestHTTPServerCutsSlowHeaders(t *testing.T) {
+	cfg := Config{HTTPReadTimeout: 100 * time.Millisecond, HTTPMaxHeaderBytes: 1024}
+	server := newHTTPServer(cfg, "", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Never finish the headers
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("expected the server to close the connection, got %v", err)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("slow client cut off after %s", took)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+lis.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Big", strings.Repeat("x", 8<<10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expected 431 for oversized headers, got %d", resp.StatusCode)
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	handler := accessLogMiddleware(logger, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "missing", http.StatusNotFound)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/secrets/x", nil))
+	id := rec.Header().Get(requestIDHeader)
+	if id == "" {
+		t.Fatal("expected a generated X-Request-ID")
+	}
+	for _, want := range []string{"request_id=" + id, "method=GET", "path=/api/secrets/x", "status=404", "duration="} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("access log %q lacks %q", logs.String(), want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(requestIDHeader, "from-client")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get(requestIDHeader); got != "from-client" {
+		t.Errorf("expected the client request ID to be kept, got %q", got)
+	}
+
+	logs.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if logs.Len() != 0 {
+		t.Errorf("expected probes to be logged at debug level, got %q", logs.String())
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseMode parses a file permission mode. "420" is read as decimal, "0644" or "0o644" as octal
+// and "rw-r--r--" (optionally prefixed by the "-" file type) as symbolic.
+func parseMode(s string) (int32, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "rwx-") {
+		return parseSymbolicMode(s)
+	}
+	m, err := strconv.ParseInt(s, 0, 32)
+	if err != nil || m < 0 || m > 0o777 {
+		return 0, fmt.Errorf("invalid mode %q", s)
+	}
+	return int32(m), nil
+}
+
+func parseSymbolicMode(s string) (int32, error) {
+	if len(s) == 10 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) != 9 {
+		return 0, fmt.Errorf("invalid symbolic mode %q, expected e.g. rw-r--r--", s)
+	}
+	var m int32
+	for i, c := range s {
+		bit := int32(1) << (8 - i)
+		switch {
+		case c == '-':
+		case c == rune("rwx"[i%3]):
+			m |= bit
+		default:
+			return 0, fmt.Errorf("invalid symbolic mode %q, expected e.g. rw-r--r--", s)
+		}
+	}
+	return m, nil
+}
+
+// formatMode renders a mode the way ls does, e.g. 420 is "rw-r--r--".
+func formatMode(m int32) string {
+	return os.FileMode(m).Perm().String()[1:]
+}
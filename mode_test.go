@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int32
+	}{
+		{"420", 420},
+		{"0644", 0o644},
+		{"0o600", 0o600},
+		{"rw-r--r--", 0o644},
+		{"-rwxr-x---", 0o750},
+		{"---------", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseMode(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseMode(%q) = %#o, %v, want %#o", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "0999", "-1", "01000", "rw-r--r-", "rw-r--r-x-", "wr-r--r--"} {
+		if _, err := parseMode(in); err == nil {
+			t.Errorf("parseMode(%q) should fail", in)
+		}
+	}
+}
+
+func TestModePreview(t *testing.T) {
+	srv := newTestWebServer(t, NewMemoryStore(testLogger(), Config{}))
+
+	tests := map[string]modePreview{
+		"0644":      {Mode: 420, Octal: "0644", Symbolic: "rw-r--r--"},
+		"420":       {Mode: 420, Octal: "0644", Symbolic: "rw-r--r--"},
+		"rwxr--r--": {Mode: 484, Octal: "0744", Symbolic: "rwxr--r--"},
+		"0400":      {Mode: 256, Octal: "0400", Symbolic: "r--------"},
+	}
+	for in, want := range tests {
+		resp, err := http.Get(srv.URL + "/api/mode-preview?mode=" + in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got modePreview
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK || got != want {
+			t.Errorf("mode-preview %q = %+v (%d, %v), want %+v", in, got, resp.StatusCode, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "0999", "rwxrwxrwz"} {
+		resp, err := http.Get(srv.URL + "/api/mode-preview?mode=" + in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("mode-preview %q: expected 400, got %d", in, resp.StatusCode)
+		}
+	}
+}
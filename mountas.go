@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// validMountAs checks a MountAs path stays inside the mount directory, empty means unset.
+func validMountAs(p string) error {
+	if p == "" {
+		return nil
+	}
+	if p == "." || !filepath.IsLocal(p) || strings.Contains(p, `\`) || filepath.Clean(p) != p {
+		return fmt.Errorf("mountAs %q must be a clean relative path inside the mount", p)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestValidMountAs(t *testing.T) {
+	for _, p := range []string{"", "password.txt", "db/password.txt"} {
+		if err := validMountAs(p); err != nil {
+			t.Errorf("validMountAs(%q) = %v, want nil", p, err)
+		}
+	}
+	for _, p := range []string{"/etc/passwd", "../escape", "a/../../b", "a/./b", `a\b`, "."} {
+		if err := validMountAs(p); err == nil {
+			t.Errorf("validMountAs(%q) = nil, want an error", p)
+		}
+	}
+}
+
+func TestMountAs(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "db-password", Value: "pw", Version: "v1", Mode: 420, MountAs: "password.txt"})
+	store.Set("plain.txt", "x", "v1", 420)
+
+	metrics := NewMetrics("")
+	srv := NewProviderServer(testLogger(), Config{}, store, metrics)
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for i, f := range resp.Files {
+		got[f.Path] = string(f.Contents)
+		if resp.ObjectVersion[i].Id != f.Path {
+			t.Errorf("object version id %q doesn't match file %q", resp.ObjectVersion[i].Id, f.Path)
+		}
+	}
+	if len(got) != 2 || got["password.txt"] != "pw" || got["plain.txt"] != "x" {
+		t.Errorf("unexpected mounted files: %v", got)
+	}
+
+	// The served metric counts secrets by name, not by mounted path
+	if got := testutil.ToFloat64(metrics.secretServed.WithLabelValues("db-password")); got != 1 {
+		t.Errorf("db-password served = %v, want 1", got)
+	}
+
+	// The store key is untouched
+	if _, ok := store.Get("db-password"); !ok {
+		t.Error("expected the secret still stored under its name")
+	}
+}
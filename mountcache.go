@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"slices"
+	"sync"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// mountCacheEntry is a memoized Mount response along with what Mount records on every call.
+type mountCacheEntry struct {
+	resp       *v1alpha1.MountResponse
+	generation uint64
+	served     []string
+	// diff compares the current object versions of the request, which are part of the
+	// key, with the response, nil when the request had none
+	diff *ObjectVersionDiff
+}
+
+// mountCache memoizes Mount responses by attributes for a single store generation,
+// every entry is dropped as soon as a response for a newer generation is stored.
+type mountCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entries    map[string]mountCacheEntry
+}
+
+func newMountCache() *mountCache {
+	return &mountCache{entries: make(map[string]mountCacheEntry)}
+}
+
+// Get returns the response cached for key if it was built at generation.
+func (c *mountCache) Get(key string, generation uint64) (mountCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || e.generation != generation {
+		return mountCacheEntry{}, false
+	}
+	return e, true
+}
+
+// Put caches e under key, unless a newer generation was already cached.
+func (c *mountCache) Put(key string, e mountCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case e.generation < c.generation:
+		return
+	case e.generation > c.generation:
+		clear(c.entries)
+		c.generation = e.generation
+	}
+	c.entries[key] = e
+}
+
+// mountCacheKey hashes the Mount attributes, in key order, and the object versions
+// the driver currently has.
+func mountCacheKey(attributes map[string]string, current []*v1alpha1.ObjectVersion) string {
+	h := sha256.New()
+	for _, k := range slices.Sorted(maps.Keys(attributes)) {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(attributes[k]))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{0})
+	for _, v := range current {
+		h.Write([]byte(v.GetId()))
+		h.Write([]byte{0})
+		h.Write([]byte(v.GetVersion()))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Dynamic reports whether two mounts at the same generation may render differently, in
+// which case Mount responses can't be cached: fileref and httpref secrets are read on
+// every mount, rotate on mount, templates, variants and version jitter change on every mount, propagating
+// secrets become visible and secrets with a TTL disappear as time passes, even between
+// TTL sweeps.
+func (s *MemoryStore) Dynamic() bool {
+	if s.cfg.VersionJitter > 0 || s.cfg.ContentTransform == TransformTemplate || s.propagatingAny() {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sec := range s.secrets {
+		if sec.RotateOnMount || sec.Transform == TransformTemplate || sec.Type == SecretTypeFileRef || sec.Type == SecretTypeHTTPRef || sec.Type == SecretTypeVariants || !sec.ExpiresAt.IsZero() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMountCache(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	metrics := NewMetrics("")
+	cfg := Config{MountCache: true}
+	srv := NewProviderServer(testLogger(), cfg, store, metrics)
+
+	mount := func(attrs map[string]string) *v1alpha1.MountResponse {
+		t.Helper()
+		b, err := json.Marshal(attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: string(b)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	count := func(result string) float64 {
+		return testutil.ToFloat64(metrics.mountCache.WithLabelValues(result))
+	}
+
+	podA := map[string]string{podNameAttribute: "a"}
+	first := mount(podA)
+	if second := mount(podA); second != first {
+		t.Error("expected the memoized response for identical attributes")
+	}
+	if count("hit") != 1 || count("miss") != 1 {
+		t.Errorf("hit=%v miss=%v, want 1 and 1", count("hit"), count("miss"))
+	}
+	if got := testutil.ToFloat64(metrics.secretServed.WithLabelValues("a.txt")); got != 2 {
+		t.Errorf("served metric = %v on cache hits, want 2", got)
+	}
+
+	// Different attributes
+	if resp := mount(map[string]string{podNameAttribute: "b"}); resp == first {
+		t.Error("expected a new response for different attributes")
+	}
+	if count("miss") != 2 {
+		t.Errorf("miss=%v, want 2", count("miss"))
+	}
+
+	// Store change
+	store.Set("a.txt", "2", "v2", 420)
+	resp := mount(podA)
+	if resp == first || string(resp.Files[0].Contents) != "2" {
+		t.Errorf("expected a fresh response after a store change, got %q", resp.Files[0].Contents)
+	}
+	if count("miss") != 3 || count("hit") != 1 {
+		t.Errorf("hit=%v miss=%v, want 1 and 3", count("hit"), count("miss"))
+	}
+
+	// Secrets rendered differently on every mount bypass the cache
+	store.Put(Secret{Name: "r.txt", Value: "x", Version: "v1", Mode: 420, RotateOnMount: true})
+	if mount(podA) == mount(podA) {
+		t.Error("expected no caching with a rotate on mount secret")
+	}
+	if count("bypass") != 2 {
+		t.Errorf("bypass=%v, want 2", count("bypass"))
+	}
+}
+
+func TestMountCacheExpiringSecrets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(testLogger(), Config{})
+	store.now = func() time.Time { return now }
+	store.Put(Secret{Name: "a.txt", Value: "1", Version: "v1", Mode: 420})
+	store.Put(Secret{Name: "ttl.txt", Value: "2", Version: "v1", Mode: 420, ExpiresAt: now.Add(time.Minute)})
+	srv := NewProviderServer(testLogger(), Config{MountCache: true}, store, NewMetrics(""))
+
+	mount := func() *v1alpha1.MountResponse {
+		t.Helper()
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: "{}"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if n := len(mount().Files); n != 2 {
+		t.Fatalf("expected 2 files before expiry, got %d", n)
+	}
+	// No TTL sweep ran, the expired secret must still not be served from the cache
+	now = now.Add(2 * time.Minute)
+	if n := len(mount().Files); n != 1 {
+		t.Errorf("expected the expired secret left out, got %d files", n)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"google.golang.org/grpc/codes"
+)
+
+// MountFault makes Mount fail with a gRPC status, to test the driver retry and backoff.
+type MountFault struct {
+	// Code is a gRPC code name like "UNAVAILABLE" or "DEADLINE_EXCEEDED", empty clears the fault
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Count is the number of Mount calls failing before the fault clears itself, 0 fails
+	// them all until cleared
+	Count int64 `json:"count,omitempty"`
+	// Remaining is the number of failing calls left out of Count
+	Remaining int64 `json:"remaining,omitempty"`
+	// Details is attached to the error status as an ErrorInfo, for clients reading status details
+	Details *FaultDetails `json:"details,omitempty"`
+
+	code codes.Code
+	// injected counts the calls failed so far
+	injected atomic.Int64
+}
+
+// parse validates the fault and fills its parsed fields.
+func (f *MountFault) parse() error {
+	f.code = codes.OK
+	if f.Code != "" {
+		if err := f.code.UnmarshalJSON([]byte(strconv.Quote(f.Code))); err != nil {
+			return fmt.Errorf("unknown gRPC code %q", f.Code)
+		}
+	}
+	if f.code == codes.OK && (f.Count != 0 || f.Details != nil) {
+		return fmt.Errorf("count and details need an error code")
+	}
+	if f.Count < 0 {
+		return fmt.Errorf("count must not be negative")
+	}
+	return nil
+}
+
+// parseFaultMode parses FAULT_MODE, a gRPC code name failing every Mount until cleared,
+// empty for none.
+func parseFaultMode(mode string) (*MountFault, error) {
+	if mode == "" {
+		return nil, nil
+	}
+	f := &MountFault{Code: mode}
+	if err := f.parse(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// injectMountFault returns the error Mount must answer with while a fault is set,
+// clearing a counted fault once its last call failed.
+func (s *ProviderServer) injectMountFault(targetPath string) error {
+	f := s.mountFault.Load()
+	if f == nil || f.code == codes.OK {
+		return nil
+	}
+	n := f.injected.Add(1)
+	if f.Count > 0 && n > f.Count {
+		// Concurrent calls raced past the last one
+		return nil
+	}
+	msg := f.Message
+	if msg == "" {
+		msg = "injected Mount fault"
+	}
+	if f.Count > 0 {
+		msg = fmt.Sprintf("%s (%d of %d)", msg, n, f.Count)
+		if n == f.Count {
+			s.mountFault.CompareAndSwap(f, nil)
+		}
+	}
+	s.logger.Warn("Mount fault injected", "target_path", targetPath, "code", f.code, "message", msg)
+	return faultError(f.code, msg, f.Details)
+}
+
+func (w *WebServer) handleGetMountFault(rw http.ResponseWriter, r *http.Request) {
+	f := w.provider.mountFault.Load()
+	if f == nil {
+		w.writeJSON(rw, http.StatusOK, &MountFault{})
+		return
+	}
+	view := MountFault{Code: f.Code, Message: f.Message, Count: f.Count, Details: f.Details}
+	if f.Count > 0 {
+		view.Remaining = max(f.Count-f.injected.Load(), 0)
+	}
+	w.writeJSON(rw, http.StatusOK, &view)
+}
+
+// handleSetMountFault replaces the Mount fault, an empty object clears it.
+func (w *WebServer) handleSetMountFault(rw http.ResponseWriter, r *http.Request) {
+	var f MountFault
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if err := f.parse(); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	f.Remaining = f.Count
+	if f.code == codes.OK {
+		w.provider.mountFault.Store(nil)
+		w.logger.Info("Mount fault cleared via API")
+	} else {
+		w.provider.mountFault.Store(&f)
+		w.logger.Info("Mount fault set via API", "code", f.code, "count", f.Count, "message", f.Message)
+	}
+	w.writeJSON(rw, http.StatusOK, &f)
+}
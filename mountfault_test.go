@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMountFault(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	post := func(body string) int {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/fault", strings.NewReader(body)))
+		return rec.Code
+	}
+	mount := func() error {
+		_, err := web.provider.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: "{}"})
+		return err
+	}
+
+	for _, body := range []string{`{"code":"NOPE"}`, `{"count":2}`, `{"code":"UNAVAILABLE","count":-1}`, `{"codes":"UNAVAILABLE"}`} {
+		if code := post(body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, code)
+		}
+	}
+
+	if code := post(`{"code":"DEADLINE_EXCEEDED","message":"backend slow","count":2}`); code != http.StatusOK {
+		t.Fatalf("set fault: expected 200, got %d", code)
+	}
+	for i := 1; i <= 2; i++ {
+		err := mount()
+		if st := status.Convert(err); st.Code() != codes.DeadlineExceeded || !strings.Contains(st.Message(), "backend slow") {
+			t.Errorf("call %d: expected the injected fault, got %v", i, err)
+		}
+		if i == 1 {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fault", nil))
+			var f MountFault
+			if err := json.NewDecoder(rec.Body).Decode(&f); err != nil || f.Remaining != 1 {
+				t.Errorf("expected 1 remaining call, got %+v (%v)", &f, err)
+			}
+		}
+	}
+	if err := mount(); err != nil {
+		t.Errorf("expected the fault cleared after 2 calls, got %v", err)
+	}
+
+	if code := post(`{"code":"UNAVAILABLE"}`); code != http.StatusOK {
+		t.Fatalf("set fault: expected 200, got %d", code)
+	}
+	for range 3 {
+		if err := mount(); status.Code(err) != codes.Unavailable {
+			t.Errorf("expected Unavailable until cleared, got %v", err)
+		}
+	}
+	if code := post(`{}`); code != http.StatusOK {
+		t.Fatalf("clear fault: expected 200, got %d", code)
+	}
+	if err := mount(); err != nil {
+		t.Errorf("expected Mount to succeed once cleared, got %v", err)
+	}
+}
+
+func TestFaultMode(t *testing.T) {
+	if _, err := parseFaultMode("SOMETIMES"); err == nil {
+		t.Error("expected an invalid FAULT_MODE to be rejected")
+	}
+	srv := NewProviderServer(testLogger(), Config{FaultMode: "UNAVAILABLE"}, NewMemoryStore(testLogger(), Config{}), NewMetrics(""))
+	if _, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: "{}"}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected FAULT_MODE to fail Mount, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// MountRecord is a Mount request retained by a mountHistory.
+type MountRecord struct {
+	Time       time.Time `json:"time"`
+	TargetPath string    `json:"targetPath"`
+	// Attributes are the raw request attributes, tokens redacted
+	Attributes string `json:"attributes"`
+	Files      int    `json:"files"`
+	// Error is the gRPC status Mount failed with, empty on success
+	Error string `json:"error,omitempty"`
+}
+
+// mountHistory keeps the last Mount requests, for /requests and GET /api/requests.
+type mountHistory struct {
+	mu      sync.Mutex
+	records []MountRecord
+	next    int
+	full    bool
+}
+
+func newMountHistory(size int) *mountHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &mountHistory{records: make([]MountRecord, size)}
+}
+
+func (h *mountHistory) add(rec MountRecord) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Records returns the retained requests, most recent first.
+func (h *mountHistory) Records() []MountRecord {
+	if h == nil {
+		return []MountRecord{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.next
+	if h.full {
+		n = len(h.records)
+	}
+	out := make([]MountRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, h.records[(h.next-i+len(h.records))%len(h.records)])
+	}
+	return out
+}
+
+// redactedAttributes are the attributes carrying credentials, never retained.
+var redactedAttributes = []string{authTokenAttribute, serviceAccountTokensAttribute}
+
+// redactAttributes masks the credentials of raw request attributes, unparsable
+// attributes are kept as is.
+func redactAttributes(raw string) string {
+	attrs, err := parseAttributes(raw)
+	if err != nil {
+		return raw
+	}
+	redacted := false
+	for _, k := range redactedAttributes {
+		if _, ok := attrs[k]; ok {
+			attrs[k] = "REDACTED"
+			redacted = true
+		}
+	}
+	if !redacted {
+		return raw
+	}
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		return raw
+	}
+	return string(b)
+}
+
+// recordMount retains a Mount request received at start and its outcome.
+func (s *ProviderServer) recordMount(req *v1alpha1.MountRequest, start time.Time, resp *v1alpha1.MountResponse, err error) {
+	rec := MountRecord{
+		Time:       start,
+		TargetPath: req.GetTargetPath(),
+		Attributes: redactAttributes(req.GetAttributes()),
+		Files:      len(resp.GetFiles()),
+	}
+	if err != nil {
+		st := status.Convert(err)
+		rec.Error = st.Code().String() + ": " + st.Message()
+	}
+	s.history.add(rec)
+}
+
+func (w *WebServer) handleListRequests(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, w.provider.history.Records())
+}
+
+var requestsTmpl = template.Must(template.New("requests").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>Mount requests - CSI Debugger</title>
+    <link rel="icon" href="/favicon.ico">
+    <link rel="stylesheet" href="/static/admin.css">
+</head>
+<body>
+    <h3>Last Mount requests, most recent first</h3>
+    <p><a href="/">Back to the admin UI</a> - <a href="/api/requests">JSON</a></p>
+    <table>
+        <thead>
+            <tr><th>Time</th><th>Target Path</th><th>Attributes</th><th>Files</th><th>Error</th></tr>
+        </thead>
+        <tbody>
+            {{range .}}
+            <tr>
+                <td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td>
+                <td>{{.TargetPath}}</td>
+                <td><code>{{.Attributes}}</code></td>
+                <td>{{.Files}}</td>
+                <td>{{.Error}}</td>
+            </tr>
+            {{else}}
+            <tr><td colspan="5">No Mount request received yet.</td></tr>
+            {{end}}
+        </tbody>
+    </table>
+</body>
+</html>
+`))
+
+// handleRequestsPage lists the last Mount requests.
+func (w *WebServer) handleRequestsPage(rw http.ResponseWriter, r *http.Request) {
+	if err := requestsTmpl.Execute(rw, w.provider.history.Records()); err != nil {
+		w.logger.Error("failed to render requests page", "error", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMountHistory(t *testing.T) {
+	h := newMountHistory(3)
+	for i := range 5 {
+		h.add(MountRecord{TargetPath: fmt.Sprintf("/p%d", i)})
+	}
+	var got []string
+	for _, rec := range h.Records() {
+		got = append(got, rec.TargetPath)
+	}
+	if want := "/p4 /p3 /p2"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	if records := newMountHistory(0).Records(); len(records) != 0 {
+		t.Errorf("expected no records when disabled, got %v", records)
+	}
+}
+
+func TestMountRequests(t *testing.T) {
+	cfg := Config{MountHistorySize: 100, MountRequireToken: "s3cret"}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v1", 420)
+	web := newTestWeb(t, cfg, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			web.provider.Mount(context.Background(), &v1alpha1.MountRequest{
+				TargetPath: fmt.Sprintf("/pods/%d", i),
+				Attributes: `{"auth_token":"s3cret"}`,
+			})
+		})
+	}
+	wg.Wait()
+	web.provider.Mount(context.Background(), &v1alpha1.MountRequest{TargetPath: "/pods/denied", Attributes: `{"auth_token":"wrong"}`})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/requests", nil))
+	var records []MountRecord
+	if err := json.NewDecoder(rec.Body).Decode(&records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 11 {
+		t.Fatalf("expected 11 records, got %d", len(records))
+	}
+	if last := records[0]; last.TargetPath != "/pods/denied" || last.Files != 0 || !strings.HasPrefix(last.Error, "PermissionDenied") {
+		t.Errorf("unexpected most recent record %+v", last)
+	}
+	for _, r := range records[1:] {
+		if r.Files != 2 || r.Error != "" {
+			t.Errorf("unexpected record %+v", r)
+		}
+	}
+	for _, r := range records {
+		if strings.Contains(r.Attributes, "s3cret") || strings.Contains(r.Attributes, "wrong") {
+			t.Errorf("token not redacted: %s", r.Attributes)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/requests", nil))
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "/pods/denied") {
+		t.Errorf("unexpected requests page (%d):\n%s", rec.Code, body)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// failingObjectErrorCode is the MountResponse error code reported when secrets marked
+// failing were left out of a mount. The driver logs it as
+// "mount request failed with provider error code CSI_DEBUGGER_FAILING_OBJECT" and
+// counts it in its provider error metrics.
+const failingObjectErrorCode = "CSI_DEBUGGER_FAILING_OBJECT"
+
+// failingObjectsError returns the MountResponse error for the failing secrets left out
+// of a mount, nil when there are none.
+func (s *ProviderServer) failingObjectsError(targetPath string, failing []string) *v1alpha1.Error {
+	if len(failing) == 0 {
+		return nil
+	}
+	s.logger.Warn("Mount reporting failing objects", "target_path", targetPath, "failing", failing, "code", failingObjectErrorCode)
+	return &v1alpha1.Error{Code: failingObjectErrorCode}
+}
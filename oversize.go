@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// What Mount does with a file larger than MAX_SECRET_BYTES.
+const (
+	OversizeSkip     = "skip"
+	OversizeTruncate = "truncate"
+)
+
+func validOversizePolicy(p string) bool {
+	return p == OversizeSkip || p == OversizeTruncate
+}
+
+// checkSize rejects a value larger than MAX_SECRET_BYTES, value being the decoded bytes
+// that end up in the mount.
+func (w *WebServer) checkSize(value string) error {
+	if limit := w.provider.cfg.MaxSecretBytes; limit > 0 && len(value) > limit {
+		return invalid(reasonSize, fmt.Errorf("value of %d bytes exceeds MAX_SECRET_BYTES (%d)", len(value), limit))
+	}
+	return nil
+}
+
+// oversizeReport lists the files Mount skipped or truncated for exceeding MAX_SECRET_BYTES.
+type oversizeReport struct {
+	Skipped   []string
+	Truncated []string
+}
+
+// enforceMaxSize applies OVERSIZE_POLICY to the files larger than MAX_SECRET_BYTES, so a
+// single huge secret can't break a whole mount. versions must be in the order of files.
+func (s *ProviderServer) enforceMaxSize(files []*v1alpha1.File, versions []*v1alpha1.ObjectVersion) ([]*v1alpha1.File, []*v1alpha1.ObjectVersion, oversizeReport) {
+	var report oversizeReport
+	limit := s.cfg.MaxSecretBytes
+	if limit <= 0 {
+		return files, versions, report
+	}
+	for i := 0; i < len(files); i++ {
+		f := files[i]
+		size := len(f.GetContents())
+		if size <= limit {
+			continue
+		}
+		s.metrics.oversize.WithLabelValues(s.cfg.OversizePolicy).Inc()
+		if s.cfg.OversizePolicy == OversizeTruncate {
+			s.logger.Warn("secret larger than MAX_SECRET_BYTES, truncating it", "name", f.GetPath(), "size", size, "limit", limit)
+			f.Contents = f.Contents[:limit]
+			report.Truncated = append(report.Truncated, f.GetPath())
+			continue
+		}
+		s.logger.Warn("secret larger than MAX_SECRET_BYTES, skipping it", "name", f.GetPath(), "size", size, "limit", limit)
+		report.Skipped = append(report.Skipped, f.GetPath())
+		files = slices.Delete(files, i, i+1)
+		versions = slices.Delete(versions, i, i+1)
+		i--
+	}
+	return files, versions, report
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	// ownerTokenHeader carries the owner token required to mutate an owned secret.
+	ownerTokenHeader = "X-Owner-Token"
+	// ownerTokenField is the form field alternative to ownerTokenHeader, HTML forms
+	// can't set headers.
+	ownerTokenField = "owner_token"
+)
+
+// ownerError reports a write to a secret owned by another token.
+type ownerError struct {
+	name string
+}
+
+func (e *ownerError) Error() string {
+	return "owner token mismatch for secret " + e.name
+}
+
+// ownerToken returns the owner token sent with r, the header taking precedence over
+// the form field.
+func ownerToken(r *http.Request) string {
+	if token := r.Header.Get(ownerTokenHeader); token != "" {
+		return token
+	}
+	return r.FormValue(ownerTokenField)
+}
+
+// checkOwner returns an *ownerError unless sec is open or owned by token.
+func checkOwner(sec Secret, token string) error {
+	if sec.Owner != "" && subtle.ConstantTimeCompare([]byte(sec.Owner), []byte(token)) != 1 {
+		return &ownerError{name: sec.Name}
+	}
+	return nil
+}
+
+// secretNames returns the names of secrets.
+func secretNames(secrets []Secret) []string {
+	names := make([]string, 0, len(secrets))
+	for _, sec := range secrets {
+		names = append(names, sec.Name)
+	}
+	return names
+}
+
+// fixedNames returns the names argument of Owned for a known set of secrets.
+func fixedNames(names ...string) func() []string {
+	return func() []string { return names }
+}
+
+// Owned calls fn under the store write lock once token matches the owner of every secret
+// names returns, so no write can change an owner between the check and fn. Both run
+// with the lock held and must only use the Locked methods, fn reports whether it changed
+// the store. It returns an *ownerError, and doesn't call fn, on a mismatch.
+func (s *MemoryStore) Owned(token string, names func() []string, fn func() bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names() {
+		sec, ok := s.secrets[s.key(name)]
+		if !ok {
+			continue
+		}
+		if err := checkOwner(sec, token); err != nil {
+			return err
+		}
+	}
+	if fn() {
+		s.notifyLocked()
+	}
+	return nil
+}
+
+// namesLocked returns the names of every secret in the store.
+func (s *MemoryStore) namesLocked() []string {
+	return slices.Collect(maps.Keys(s.secrets))
+}
+
+// bundleMembersLocked returns the names of the secrets in bundle.
+func (s *MemoryStore) bundleMembersLocked(bundle string) []string {
+	var names []string
+	for name, sec := range s.secrets {
+		if sec.Bundle == bundle {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ownerRejected answers 403 when err is an *ownerError, it reports whether it did.
+func (w *WebServer) ownerRejected(rw http.ResponseWriter, r *http.Request, err error) bool {
+	var oe *ownerError
+	if !errors.As(err, &oe) {
+		return false
+	}
+	w.logger.Warn("Mutation rejected, owner token mismatch", "name", oe.name, "method", r.Method, "path", r.URL.Path)
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.writeJSONError(rw, http.StatusForbidden, oe.Error())
+	} else {
+		http.Error(rw, "Owner token mismatch for secret "+oe.name, http.StatusForbidden)
+	}
+	return true
+}
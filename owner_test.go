@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOwnerTokens(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("open.txt", "v", "v1", 420)
+	srv := newTestWebServer(t, store)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	post := func(path, token string, form url.Values) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set(ownerTokenHeader, token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post("/update", "", url.Values{"name": {"team-a.txt"}, "value": {"a"}, "version": {"v1"}, "owner": {"secret-a"}}); code != http.StatusSeeOther {
+		t.Fatalf("create owned secret: expected 303, got %d", code)
+	}
+
+	// Open secrets stay editable by everyone
+	if code := post("/update", "", url.Values{"name": {"open.txt"}, "value": {"w"}}); code != http.StatusSeeOther {
+		t.Errorf("update open secret: expected 303, got %d", code)
+	}
+
+	denied := map[string]url.Values{
+		"/update":                       {"name": {"team-a.txt"}, "value": {"b"}},
+		"/delete":                       {"name": {"team-a.txt"}},
+		"/bulk":                         {"json_data": {`[{"name":"team-a.txt","value":"b"}]`}},
+		"/api/secrets/team-a.txt/scope": {},
+	}
+	for _, token := range []string{"", "secret-b"} {
+		for path, form := range denied {
+			if code := post(path, token, form); code != http.StatusForbidden {
+				t.Errorf("%s with token %q: expected 403, got %d", path, token, code)
+			}
+		}
+	}
+	if sec, _ := store.Get("team-a.txt"); sec.Value != "a" || sec.ServiceAccount != "" {
+		t.Fatalf("owned secret changed without its token: %+v", sec)
+	}
+
+	// The owner is kept across an update that doesn't set one
+	if code := post("/update", "secret-a", url.Values{"name": {"team-a.txt"}, "value": {"b"}}); code != http.StatusSeeOther {
+		t.Fatalf("update with token: expected 303, got %d", code)
+	}
+	if sec, _ := store.Get("team-a.txt"); sec.Value != "b" || sec.Owner != "secret-a" {
+		t.Errorf("unexpected secret after owner update: %+v", sec)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/secrets/team-a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var view map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := view["owner"]; ok || view["owned"] != true {
+		t.Errorf("expected the owner token hidden and owned set, got %v", view)
+	}
+
+	if code := post("/delete", "secret-a", url.Values{"name": {"team-a.txt"}}); code != http.StatusSeeOther {
+		t.Errorf("delete with token: expected 303, got %d", code)
+	}
+	if _, ok := store.Get("team-a.txt"); ok {
+		t.Error("expected the owned secret deleted with its token")
+	}
+}
+
+func TestOwnerTokenFormFieldAndJSON(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	srv := newTestWebServer(t, store)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/secrets", strings.NewReader(`{"name":"team-a.txt","value":"a","owner":"secret-a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create owned secret: expected 201, got %d", resp.StatusCode)
+	}
+	if sec, _ := store.Get("team-a.txt"); sec.Owner != "secret-a" {
+		t.Fatalf("expected the JSON owner stored, got %+v", sec)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	for token, want := range map[string]int{"secret-b": http.StatusForbidden, "secret-a": http.StatusSeeOther} {
+		resp, err := client.PostForm(srv.URL+"/update", url.Values{"name": {"team-a.txt"}, "value": {token}, ownerTokenField: {token}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("update with form token %q: expected %d, got %d", token, want, resp.StatusCode)
+		}
+	}
+	if sec, _ := store.Get("team-a.txt"); sec.Value != "secret-a" {
+		t.Errorf("expected the update with the owner form token applied, got %+v", sec)
+	}
+}
+
+func TestOwnedChecksUnderLock(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "a.txt", Value: "v", Owner: "secret-a"})
+
+	called := false
+	err := store.Owned("secret-b", fixedNames("a.txt", "missing.txt"), func() bool {
+		called = true
+		return false
+	})
+	var oe *ownerError
+	if !errors.As(err, &oe) || oe.name != "a.txt" || called {
+		t.Errorf("expected an owner error for a.txt without calling fn, got %v (called %v)", err, called)
+	}
+	if err := store.Owned("secret-a", fixedNames("a.txt"), func() bool {
+		return store.deleteLocked("a.txt")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("a.txt"); ok {
+		t.Error("expected a.txt deleted by the owner")
+	}
+}
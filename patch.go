@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// secretPatch holds the fields of a PATCH /api/secrets/{name} body, nil fields are left
+// untouched.
+type secretPatch struct {
+	Value           *string            `json:"value"`
+	Version         *string            `json:"version"`
+	Mode            *patchMode         `json:"mode"`
+	Annotations     *map[string]string `json:"annotations"`
+	Labels          *map[string]string `json:"labels"`
+	Transform       *string            `json:"transform"`
+	RotateOnMount   *bool              `json:"rotateOnMount"`
+	ServiceAccount  *string            `json:"serviceAccount"`
+	Bundle          *string            `json:"bundle"`
+	Disabled        *bool              `json:"disabled"`
+	Failing         *bool              `json:"failing"`
+	Type            *string            `json:"type"`
+	VariantStrategy *string            `json:"variantStrategy"`
+	MountAs         *string            `json:"mountAs"`
+	Encoding        *string            `json:"encoding"`
+	LineEnding      *string            `json:"lineEnding"`
+}
+
+// patchMode accepts a mode as a JSON number (420) or any string parseMode accepts ("0644").
+type patchMode int32
+
+func (m *patchMode) UnmarshalJSON(b []byte) error {
+	var n int32
+	if err := json.Unmarshal(b, &n); err == nil {
+		mode, err := parseMode(strconv.Itoa(int(n)))
+		*m = patchMode(mode)
+		if err != nil {
+			return invalid(reasonMode, err)
+		}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return invalid(reasonMode, fmt.Errorf("mode must be a number or a string"))
+	}
+	mode, err := parseMode(s)
+	*m = patchMode(mode)
+	if err != nil {
+		return invalid(reasonMode, err)
+	}
+	return nil
+}
+
+func (p secretPatch) validate() error {
+	if p.Transform != nil && !validTransform(*p.Transform) {
+		return invalid(reasonTransform, fmt.Errorf("unknown transform %q", *p.Transform))
+	}
+	if p.Type != nil && !validSecretType(*p.Type) {
+		return invalid(reasonType, fmt.Errorf("unknown secret type %q", *p.Type))
+	}
+	if p.VariantStrategy != nil && !validVariantStrategy(*p.VariantStrategy) {
+		return invalid(reasonType, fmt.Errorf("unknown variant strategy %q", *p.VariantStrategy))
+	}
+	if p.MountAs != nil {
+		if err := validMountAs(*p.MountAs); err != nil {
+			return invalid(reasonPath, err)
+		}
+	}
+	if p.Encoding != nil && !validEncoding(*p.Encoding) {
+		return invalid(reasonEncoding, fmt.Errorf("unknown encoding %q", *p.Encoding))
+	}
+	if p.LineEnding != nil && !validLineEnding(*p.LineEnding) {
+		return invalid(reasonEncoding, fmt.Errorf("unknown line ending %q", *p.LineEnding))
+	}
+	return nil
+}
+
+// validatePatched checks the secret a patch results in, a patch of valid fields can still
+// combine into an invalid secret, such as variants with rotate on mount.
+func (w *WebServer) validatePatched(sec Secret) error {
+	if sec.Value == "" {
+		return invalid(reasonRequired, errors.New("value required"))
+	}
+	if err := validateSecret(sec); err != nil {
+		return err
+	}
+	return w.checkSize(sec.Value)
+}
+
+func (p secretPatch) apply(sec *Secret) {
+	if p.Value != nil {
+		sec.Value = *p.Value
+	}
+	if p.Version != nil {
+		sec.Version = *p.Version
+	}
+	if p.Mode != nil {
+		sec.Mode = int32(*p.Mode)
+	}
+	if p.Annotations != nil {
+		sec.Annotations = *p.Annotations
+	}
+	if p.Labels != nil {
+		sec.Labels = *p.Labels
+	}
+	if p.Transform != nil {
+		sec.Transform = *p.Transform
+	}
+	if p.RotateOnMount != nil {
+		sec.RotateOnMount = *p.RotateOnMount
+	}
+	if p.ServiceAccount != nil {
+		sec.ServiceAccount = *p.ServiceAccount
+	}
+	if p.Bundle != nil {
+		sec.Bundle = *p.Bundle
+	}
+	if p.Disabled != nil {
+		sec.Disabled = *p.Disabled
+	}
+	if p.Failing != nil {
+		sec.Failing = *p.Failing
+	}
+	if p.Type != nil {
+		sec.Type = *p.Type
+	}
+	if p.VariantStrategy != nil {
+		sec.VariantStrategy = *p.VariantStrategy
+	}
+	if p.MountAs != nil {
+		sec.MountAs = *p.MountAs
+	}
+	if p.Encoding != nil {
+		sec.Encoding = *p.Encoding
+	}
+	if p.LineEnding != nil {
+		sec.LineEnding = *p.LineEnding
+	}
+}
+
+// handlePatchSecret merges the fields given in the JSON body into the named secret.
+func (w *WebServer) handlePatchSecret(rw http.ResponseWriter, r *http.Request) {
+	var patch secretPatch
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patch); err != nil {
+		// A bad mode fails decoding through patchMode
+		reason := reasonJSON
+		var v *validationError
+		if errors.As(err, &v) {
+			reason = v.reason
+		}
+		w.rejectJSON(rw, reason, "invalid JSON: "+err.Error())
+		return
+	}
+	if err := patch.validate(); err != nil {
+		w.rejectJSON(rw, rejectionReason(err), err.Error())
+		return
+	}
+	if patch.Version != nil {
+		if err := w.checkVersion(*patch.Version); err != nil {
+			w.rejectJSON(rw, reasonVersion, err.Error())
+			return
+		}
+	}
+
+	token := ownerToken(r)
+	sec, ok, err := w.store.UpdateChecked(r.PathValue("name"), func(sec *Secret) error {
+		if err := checkOwner(*sec, token); err != nil {
+			return err
+		}
+		patch.apply(sec)
+		return w.validatePatched(*sec)
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if !ok {
+		w.writeJSONError(rw, http.StatusNotFound, "secret not found")
+		return
+	}
+	if err != nil {
+		w.rejectJSON(rw, rejectionReason(err), err.Error())
+		return
+	}
+	w.logger.Info("Secret patched via API", "name", sec.Name, "version", sec.Version)
+	w.writeJSON(rw, http.StatusOK, w.view(sec, true))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func patchSecret(t *testing.T, web *WebServer, name, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/api/secrets/"+name, strings.NewReader(body)))
+	return rec
+}
+
+func TestPatchSecretVersion(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "app.txt", Value: "v", Version: "v1", Mode: 0o600, Annotations: map[string]string{"a": "b"}})
+	web := newTestWeb(t, Config{}, store)
+
+	if rec := patchSecret(t, web, "app.txt", `{"version":"v2"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	sec, _ := store.Get("app.txt")
+	if sec.Version != "v2" || sec.Value != "v" || sec.Mode != 0o600 || sec.Annotations["a"] != "b" {
+		t.Errorf("unexpected patched secret: %+v", sec)
+	}
+}
+
+func TestPatchSecretMode(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "v", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+
+	for body, want := range map[string]int32{`{"mode":"0600"}`: 0o600, `{"mode":256}`: 0o400} {
+		if rec := patchSecret(t, web, "app.txt", body); rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", body, rec.Code, rec.Body.String())
+		}
+		if sec, _ := store.Get("app.txt"); sec.Mode != want || sec.Value != "v" || sec.Version != "v1" {
+			t.Errorf("%s: unexpected patched secret: %+v", body, sec)
+		}
+	}
+
+	for body, code := range map[string]int{
+		`{"mode":"0999"}`:   http.StatusBadRequest,
+		`{"modee":"0600"}`:  http.StatusBadRequest,
+		`{"transform":"x"}`: http.StatusBadRequest,
+	} {
+		if rec := patchSecret(t, web, "app.txt", body); rec.Code != code {
+			t.Errorf("%s: expected %d, got %d", body, code, rec.Code)
+		}
+	}
+	if rec := patchSecret(t, web, "missing.txt", `{"version":"v2"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing secret, got %d", rec.Code)
+	}
+}
+
+func TestPatchSecretRevalidatesMerged(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "app.txt", Value: "plain", Version: "v1"})
+	store.Put(Secret{Name: "pool.txt", Value: `["a","b"]`, Version: "v1", Type: SecretTypeVariants})
+	web := newTestWeb(t, Config{MaxSecretBytes: 8}, store)
+
+	for _, tc := range []struct {
+		name, body, reason string
+		code               int
+	}{
+		{"app.txt", `{"type":"variants"}`, reasonJSON, http.StatusBadRequest},
+		{"app.txt", `{"type":"httpref"}`, reasonURL, http.StatusBadRequest},
+		{"app.txt", `{"value":""}`, reasonRequired, http.StatusBadRequest},
+		{"app.txt", `{"value":"too long for the limit"}`, reasonSize, http.StatusRequestEntityTooLarge},
+		{"pool.txt", `{"rotateOnMount":true}`, reasonType, http.StatusBadRequest},
+	} {
+		before := testutil.ToFloat64(web.metrics.validationRejections.WithLabelValues(tc.reason))
+		if rec := patchSecret(t, web, tc.name, tc.body); rec.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d: %s", tc.name, tc.body, tc.code, rec.Code, rec.Body.String())
+		}
+		if got := testutil.ToFloat64(web.metrics.validationRejections.WithLabelValues(tc.reason)); got != before+1 {
+			t.Errorf("%s %s: %s rejections = %v, want %v", tc.name, tc.body, tc.reason, got, before+1)
+		}
+	}
+	if sec, _ := store.Get("app.txt"); sec.Value != "plain" || sec.Type != "" {
+		t.Errorf("rejected patches must leave the secret untouched: %+v", sec)
+	}
+	if sec, _ := store.Get("pool.txt"); sec.RotateOnMount {
+		t.Errorf("rejected patches must leave the secret untouched: %+v", sec)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// persistDebounce coalesces bursts of mutations, e.g. a bulk import, into a single flush
+	persistDebounce = 100 * time.Millisecond
+
+	backupTimeFormat = "20060102T150405.000000000"
+	backupSuffix     = ".bak"
+)
+
+// LoadPersisted replaces the store content with the PERSIST_FILE one, it returns
+// false when persistence is disabled or the file doesn't exist yet.
+func (s *MemoryStore) LoadPersisted() (bool, error) {
+	if s.cfg.PersistFile == "" {
+		return false, nil
+	}
+	secrets, err := readPersisted(s.cfg.PersistFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	s.replace(secrets)
+	s.logger.Info("store loaded", "file", s.cfg.PersistFile, "secrets", len(secrets))
+	return true, nil
+}
+
+// RunPersist flushes the store to PERSIST_FILE right away and after every mutation
+// until ctx is done, then flushes one last time.
+func (s *MemoryStore) RunPersist(ctx context.Context) error {
+	if s.cfg.PersistFile == "" {
+		return nil
+	}
+	changed := s.Changed()
+	if err := s.Flush(); err != nil {
+		return fmt.Errorf("failed to persist store: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return s.Flush()
+		case <-changed:
+		}
+		select {
+		case <-ctx.Done():
+			return s.Flush()
+		case <-time.After(persistDebounce):
+		}
+		changed = s.Changed()
+		if err := s.Flush(); err != nil {
+			s.logger.Error("failed to persist store", "file", s.cfg.PersistFile, "error", err)
+		}
+	}
+}
+
+// Flush writes the store to PERSIST_FILE, keeping the previous file as a timestamped
+// backup and pruning all but the PERSIST_BACKUPS most recent ones.
+func (s *MemoryStore) Flush() error {
+	if s.cfg.PersistFile == "" {
+		return nil
+	}
+	secrets := s.List()
+	if secrets == nil {
+		secrets = []Secret{}
+	}
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	if err := s.backupLocked(); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.cfg.PersistFile, data)
+}
+
+// backupLocked copies the current persist file to a timestamped backup, s.persistMu must be held.
+func (s *MemoryStore) backupLocked() error {
+	if s.cfg.PersistBackups <= 0 {
+		return nil
+	}
+	old, err := os.ReadFile(s.cfg.PersistFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read persist file: %w", err)
+	}
+	name := s.cfg.PersistFile + "." + s.now().UTC().Format(backupTimeFormat) + backupSuffix
+	if err := writeFileAtomic(name, old); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(len(backups), s.cfg.PersistBackups):] {
+		if err := os.Remove(filepath.Join(filepath.Dir(s.cfg.PersistFile), b)); err != nil {
+			return fmt.Errorf("failed to prune backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// Backups returns the backup file names of PERSIST_FILE, most recent first.
+func (s *MemoryStore) Backups() ([]string, error) {
+	if s.cfg.PersistFile == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(s.cfg.PersistFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	prefix := filepath.Base(s.cfg.PersistFile) + "."
+	var backups []string
+	for _, e := range entries {
+		if name := e.Name(); strings.HasPrefix(name, prefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	// The timestamp format sorts lexically
+	slices.Sort(backups)
+	slices.Reverse(backups)
+	return backups, nil
+}
+
+// RestoreBackup replaces the store content with the named backup, as returned by Backups.
+func (s *MemoryStore) RestoreBackup(name string) error {
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(backups, name) {
+		return fmt.Errorf("backup %q: %w", name, fs.ErrNotExist)
+	}
+	secrets, err := readPersisted(filepath.Join(filepath.Dir(s.cfg.PersistFile), name))
+	if err != nil {
+		return err
+	}
+	s.replace(secrets)
+	s.logger.Info("store restored from backup", "backup", name, "secrets", len(secrets))
+	return nil
+}
+
+// replace swaps the whole store content for secrets, keeping their UpdatedAt.
+func (s *MemoryStore) replace(secrets []Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, sec := range s.secrets {
+		s.removeSpillLocked(sec)
+		s.deleteHistoryLocked(name)
+		s.resetRotation(name)
+	}
+	clear(s.secrets)
+	for _, sec := range secrets {
+		updatedAt := sec.UpdatedAt
+		s.putLocked(sec)
+		if !updatedAt.IsZero() {
+			sec := s.secrets[s.key(sec.Name)]
+			sec.UpdatedAt = updatedAt
+			s.secrets[sec.Name] = sec
+		}
+	}
+	s.notifyLocked()
+}
+
+// handleBackups lists the available backups of the persist file, most recent first.
+func (w *WebServer) handleBackups(rw http.ResponseWriter, r *http.Request) {
+	backups, err := w.store.Backups()
+	if err != nil {
+		w.writeJSONError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if backups == nil {
+		backups = []string{}
+	}
+	w.writeJSON(rw, http.StatusOK, backups)
+}
+
+// handleRestoreBackup rolls the store back to the backup given by the name query parameter.
+func (w *WebServer) handleRestoreBackup(rw http.ResponseWriter, r *http.Request) {
+	if w.store.cfg.PersistFile == "" {
+		w.writeJSONError(rw, http.StatusConflict, "persistence is disabled")
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if err := w.store.RestoreBackup(name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			w.writeJSONError(rw, http.StatusNotFound, err.Error())
+			return
+		}
+		w.writeJSONError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.logger.Info("Store restored via API", "backup", name)
+	w.writeJSON(rw, http.StatusOK, w.views(w.store.List(), false))
+}
+
+func readPersisted(name string) ([]Secret, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var secrets []Secret
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return secrets, nil
+}
+
+// writeFileAtomic writes data to a temp file next to name and renames it over name,
+// so a crash never leaves a truncated file behind.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Name(), err)
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", f.Name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPersistLoad(t *testing.T) {
+	cfg := Config{PersistFile: filepath.Join(t.TempDir(), "store.json")}
+	store := NewMemoryStore(testLogger(), cfg)
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.now = func() time.Time { return updatedAt }
+	store.Put(Secret{Name: "app.txt", Value: "v", Version: "v1", Mode: 0o600, Annotations: map[string]string{"a": "b"}})
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewMemoryStore(testLogger(), cfg)
+	loaded, err := reloaded.LoadPersisted()
+	if err != nil || !loaded {
+		t.Fatalf("LoadPersisted() = %v, %v", loaded, err)
+	}
+	sec, ok := reloaded.Get("app.txt")
+	if !ok || sec.Value != "v" || sec.Mode != 0o600 || sec.Annotations["a"] != "b" || !sec.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("unexpected reloaded secret: %+v", sec)
+	}
+}
+
+func TestPersistBackupRestore(t *testing.T) {
+	cfg := Config{PersistFile: filepath.Join(t.TempDir(), "store.json"), PersistBackups: 2}
+	store := NewMemoryStore(testLogger(), cfg)
+	srv := newTestWebServer(t, store)
+
+	for _, version := range []string{"v1", "v2", "v3", "v4"} {
+		store.Set("app.txt", "value-"+version, version, 420)
+		if err := store.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// v4 is the persist file, v3 and v2 the kept backups, v1 got pruned
+	backups, err := store.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+
+	resp, err := http.Post(srv.URL+"/api/restore-backup?name="+backups[1], "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if sec, _ := store.Get("app.txt"); sec.Version != "v2" {
+		t.Errorf("expected the oldest kept backup (v2) to be restored, got %+v", sec)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/restore-backup?name=../store.json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown backup, got %d", resp.StatusCode)
+	}
+}
+
+func TestRunPersistFlushesOnChange(t *testing.T) {
+	cfg := Config{PersistFile: filepath.Join(t.TempDir(), "store.json")}
+	store := NewMemoryStore(testLogger(), cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- store.RunPersist(ctx) }()
+
+	store.Set("app.txt", "v", "v1", 420)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		secrets, err := readPersisted(cfg.PersistFile)
+		if err == nil && len(secrets) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("store was not persisted: %v, %v", secrets, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	store.Set("late.txt", "v", "v1", 420)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := readPersisted(cfg.PersistFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 2 {
+		t.Errorf("expected a final flush on shutdown, got %+v", secrets)
+	}
+}
+
+func TestPersistRestoreDropsHistory(t *testing.T) {
+	cfg := Config{PersistFile: filepath.Join(t.TempDir(), "store.json"), PersistBackups: 1, SecretHistoryDepth: 5, SpillThresholdBytes: 16}
+	store := NewMemoryStore(testLogger(), cfg)
+	defer store.Close()
+	srv := newTestWebServer(t, store)
+
+	store.Set("app.txt", "v1", "v1", 420)
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	store.Set("app.txt", "v2", "v2", 420)
+	big := strings.Repeat("x", 64)
+	store.Set("gone.bin", big, "v1", 420)
+	store.Set("gone.bin", big+"y", "v2", 420)
+	if err := store.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	store.mu.RLock()
+	spilled := store.history["gone.bin"][0].spillPath
+	store.mu.RUnlock()
+	if spilled == "" {
+		t.Fatal("expected the history of gone.bin to be spilled")
+	}
+
+	backups, err := store.Backups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v, %v", backups, err)
+	}
+	resp, err := http.Post(srv.URL+"/api/restore-backup?name="+backups[0], "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	// The history belonged to the replaced store
+	resp, err = http.Get(srv.URL + "/api/secrets/app.txt/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var history []SecretVersion
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("expected no history after a restore, got %+v", history)
+	}
+	if _, err := os.Stat(spilled); !os.IsNotExist(err) {
+		t.Errorf("history spill file of a secret missing from the backup should be removed, stat: %v", err)
+	}
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	if _, ok := store.history["gone.bin"]; ok {
+		t.Error("expected the history of gone.bin dropped")
+	}
+}
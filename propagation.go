@@ -0,0 +1,58 @@
+package main
+
+// Eventual consistency simulation: with PROPAGATION_DELAY set, a write only becomes
+// visible to mounts once the delay elapsed since its UpdatedAt, mounts get the previous
+// version, or nothing for a new secret, until then.
+
+// keepPreviousLocked records on sec the version mounts keep getting while sec propagates,
+// old being the secret sec replaces.
+func (s *MemoryStore) keepPreviousLocked(sec *Secret, old Secret) {
+	if s.cfg.PropagationDelay <= 0 {
+		return
+	}
+	if s.propagatingLocked(old) {
+		// old was never visible, mounts still get what they got before it
+		sec.previous = old.previous
+		return
+	}
+	prev, err := s.loadLocked(old)
+	if err != nil {
+		s.logger.Error("failed to load previous secret version, it won't be served while propagating", "name", old.Name, "error", err)
+		return
+	}
+	// The spill file of old is about to be removed, prev holds its value in memory
+	prev.spillPath, prev.spillSize, prev.previous = "", 0, nil
+	sec.previous = &prev
+}
+
+// propagatingLocked reports whether sec was written less than PROPAGATION_DELAY ago.
+func (s *MemoryStore) propagatingLocked(sec Secret) bool {
+	return s.cfg.PropagationDelay > 0 && s.now().Sub(sec.UpdatedAt) < s.cfg.PropagationDelay
+}
+
+// visibleLocked returns the version of sec mounts currently see, false when there is none.
+func (s *MemoryStore) visibleLocked(sec Secret) (Secret, bool) {
+	if !s.propagatingLocked(sec) {
+		return sec, true
+	}
+	if sec.previous == nil {
+		s.logger.Info("secret withheld, still propagating", "name", sec.Name, "version", sec.Version,
+			"visible_at", sec.UpdatedAt.Add(s.cfg.PropagationDelay))
+		return Secret{}, false
+	}
+	s.logger.Info("secret withheld, serving previous version while propagating", "name", sec.Name,
+		"version", sec.Version, "previous_version", sec.previous.Version, "visible_at", sec.UpdatedAt.Add(s.cfg.PropagationDelay))
+	return *sec.previous, true
+}
+
+// propagatingAny reports whether any secret is still propagating.
+func (s *MemoryStore) propagatingAny() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sec := range s.secrets {
+		if s.propagatingLocked(sec) {
+			return true
+		}
+	}
+	return false
+}
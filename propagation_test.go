@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPropagationDelay(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{PropagationDelay: time.Minute})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	mounted := func() map[string]string {
+		t.Helper()
+		files, versions, _ := store.GetFiles(nil)
+		got := make(map[string]string)
+		for i, f := range files {
+			got[f.Path] = versions[i].Version + ":" + string(f.Contents)
+		}
+		return got
+	}
+
+	store.Set("a.txt", "one", "v1", 420)
+	if got := mounted(); len(got) != 0 {
+		t.Fatalf("new secret mounted before propagating: %v", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := mounted()["a.txt"]; got != "v1:one" {
+		t.Fatalf("expected v1 once propagated, got %q", got)
+	}
+
+	// The previous version is served while the update propagates, even across several writes
+	store.Set("a.txt", "two", "v2", 420)
+	now = now.Add(30 * time.Second)
+	store.Set("a.txt", "three", "v3", 420)
+	if got := mounted()["a.txt"]; got != "v1:one" {
+		t.Errorf("expected v1 while v3 propagates, got %q", got)
+	}
+	if !store.Dynamic() {
+		t.Error("expected mounts to bypass the cache while secrets propagate")
+	}
+
+	now = now.Add(time.Minute)
+	if got := mounted()["a.txt"]; got != "v3:three" {
+		t.Errorf("expected v3 once propagated, got %q", got)
+	}
+	if store.Dynamic() {
+		t.Error("expected mounts to be cacheable once everything propagated")
+	}
+}
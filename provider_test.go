@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMountRotateOnMount(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "rot.txt", Value: "base", Version: "v1", Mode: 420, RotateOnMount: true})
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	first, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a, b := string(first.Files[0].Contents), string(second.Files[0].Contents); a != "base-1" || b != "base-2" {
+		t.Errorf("expected rotating contents, got %q then %q", a, b)
+	}
+	if a, b := first.ObjectVersion[0].Version, second.ObjectVersion[0].Version; a != "v1-1" || b != "v1-2" {
+		t.Errorf("expected rotating versions, got %q then %q", a, b)
+	}
+	if sec, _ := store.Get("rot.txt"); sec.Value != "base" || sec.Version != "v1" {
+		t.Errorf("rotation mutated the stored secret: %+v", sec)
+	}
+}
+
+func TestMountManifest(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("b.txt", "bb", "v2", 420)
+	store.Set("a.txt", "a", "v1", 420)
+	cfg := Config{MountManifest: true, ManifestName: "__manifest__.json"}
+	srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 3 || len(resp.ObjectVersion) != 3 {
+		t.Fatalf("expected 2 secrets and a manifest, got %d files", len(resp.Files))
+	}
+
+	var manifest Manifest
+	for _, f := range resp.Files {
+		if f.Path == "__manifest__.json" {
+			if err := json.Unmarshal(f.Contents, &manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []ManifestEntry{
+		{Path: "a.txt", Version: "v1", Size: 1},
+		{Path: "b.txt", Version: "v2", Size: 2},
+	}
+	if !reflect.DeepEqual(manifest.Files, want) {
+		t.Errorf("manifest = %+v, want %+v", manifest.Files, want)
+	}
+}
+
+func mountedPaths(t *testing.T, srv *ProviderServer, attrs map[string]string) []string {
+	t.Helper()
+	b, err := json.Marshal(attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: string(b)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range resp.Files {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestMountServiceAccountScope(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "global.txt", Value: "g"})
+	store.Put(Secret{Name: "frontend.txt", Value: "f", ServiceAccount: "frontend"})
+	store.Put(Secret{Name: "frontend-2.txt", Value: "f2", ServiceAccount: "frontend"})
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	tests := []struct {
+		sa   string
+		want []string
+	}{
+		{"frontend", []string{"frontend-2.txt", "frontend.txt"}},
+		{"backend", []string{"global.txt"}},
+		{"", []string{"global.txt"}},
+	}
+	for _, tt := range tests {
+		attrs := map[string]string{}
+		if tt.sa != "" {
+			attrs[serviceAccountAttribute] = tt.sa
+		}
+		if got := mountedPaths(t, srv, attrs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("service account %q mounted %v, want %v", tt.sa, got, tt.want)
+		}
+	}
+
+	if _, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: "{not json"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for malformed attributes, got %v", err)
+	}
+}
+
+func TestMountRequireToken(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("app.txt", "v", "v1", 420)
+	srv := NewProviderServer(testLogger(), Config{MountRequireToken: "s3cret"}, store, NewMetrics(""))
+
+	tests := []struct {
+		name  string
+		attrs map[string]string
+		want  codes.Code
+	}{
+		{"correct", map[string]string{authTokenAttribute: "s3cret"}, codes.OK},
+		{"wrong", map[string]string{authTokenAttribute: "guess"}, codes.PermissionDenied},
+		{"missing", map[string]string{}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		attrs, err := json.Marshal(tt.attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: string(attrs)})
+		if code := status.Code(err); code != tt.want {
+			t.Errorf("%s token: got %v, want %v", tt.name, code, tt.want)
+		}
+		if tt.want == codes.OK && len(resp.GetFiles()) != 1 {
+			t.Errorf("%s token: expected the secret to be served, got %d files", tt.name, len(resp.GetFiles()))
+		}
+	}
+}
+
+func TestVersionAllowlist(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	tests := []struct {
+		allowlist []string
+		version   string
+		want      codes.Code
+	}{
+		{nil, "v1alpha1", codes.OK},
+		{nil, "anything", codes.OK},
+		{[]string{"v1alpha1"}, "v1alpha1", codes.OK},
+		{[]string{"v1alpha1"}, "v1beta1", codes.FailedPrecondition},
+		{[]string{"v1alpha1"}, "", codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		srv := NewProviderServer(testLogger(), Config{VersionAllowlist: tt.allowlist}, store, NewMetrics(""))
+		_, err := srv.Version(context.Background(), &v1alpha1.VersionRequest{Version: tt.version})
+		if code := status.Code(err); code != tt.want {
+			t.Errorf("allowlist %v, version %q: got %v, want %v", tt.allowlist, tt.version, code, tt.want)
+		}
+	}
+}
+
+func TestMountVersionJitter(t *testing.T) {
+	cfg := Config{VersionJitter: 1.0}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.Set("app.txt", "stable", "v1", 420)
+	srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+
+	for range 10 {
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := resp.ObjectVersion[0].Version; v == "v1" || !strings.HasPrefix(v, "v1-j") {
+			t.Errorf("expected a jittered version, got %q", v)
+		}
+		if c := string(resp.Files[0].Contents); c != "stable" {
+			t.Errorf("jitter changed the content to %q", c)
+		}
+	}
+}
+
+func TestMountGeneration(t *testing.T) {
+	cfg := Config{GenerationFile: "__generation__"}
+	store := NewMemoryStore(testLogger(), cfg)
+	srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+
+	mountedGeneration := func() string {
+		t.Helper()
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range resp.Files {
+			if f.Path == "__generation__" {
+				return string(f.Contents)
+			}
+		}
+		t.Fatal("generation file not mounted")
+		return ""
+	}
+
+	if g := mountedGeneration(); g != "0" {
+		t.Errorf("expected generation 0 on an empty store, got %s", g)
+	}
+	store.Set("a.txt", "a", "v1", 420)
+	store.Set("a.txt", "b", "v2", 420)
+	store.Delete("a.txt")
+	if g := store.Generation(); g != 3 {
+		t.Errorf("expected generation 3 after 3 mutations, got %d", g)
+	}
+	if g := mountedGeneration(); g != "3" {
+		t.Errorf("expected the mount to report generation 3, got %s", g)
+	}
+}
+
+func TestMountConcatFile(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("b.txt", "line1\nline2", "v1", 420)
+	store.Set("a.txt", "plain", "v1", 420)
+	store.Set("c.txt", `say "hi"`, "v1", 420)
+	srv := NewProviderServer(testLogger(), Config{ConcatFile: "all.env"}, store, NewMetrics(""))
+
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].Path != "all.env" || len(resp.ObjectVersion) != 1 {
+		t.Fatalf("expected a single all.env file, got %d files", len(resp.Files))
+	}
+	want := "a.txt=plain\nb.txt=\"line1\\nline2\"\nc.txt=\"say \\\"hi\\\"\"\n"
+	if got := string(resp.Files[0].Contents); got != want {
+		t.Errorf("concatenated file =\n%s\nwant\n%s", got, want)
+	}
+
+	// The concatenated format reads back through the dotenv parser
+	entries, err := parseDotenv(string(resp.Files[0].Contents))
+	if err != nil || len(entries) != 3 || entries[1].Value != "line1\nline2" || entries[2].Value != `say "hi"` {
+		t.Errorf("unexpected dotenv round trip: %q, %v", entries, err)
+	}
+
+	// An empty attribute switches back to individual files
+	if got := mountedPaths(t, srv, map[string]string{concatFileAttribute: ""}); len(got) != 3 {
+		t.Errorf("expected individual files, got %v", got)
+	}
+}
+
+func TestMountOversize(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("big.txt", "0123456789", "v1", 420)
+	store.Set("small.txt", "ok", "v1", 420)
+
+	for _, policy := range []string{OversizeSkip, OversizeTruncate} {
+		t.Run(policy, func(t *testing.T) {
+			cfg := Config{MaxSecretBytes: 4, OversizePolicy: policy, MountManifest: true, ManifestName: "__manifest__.json"}
+			metrics := NewMetrics("")
+			srv := NewProviderServer(testLogger(), cfg, store, metrics)
+			resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents := map[string]string{}
+			var manifest Manifest
+			for _, f := range resp.Files {
+				contents[f.Path] = string(f.Contents)
+				if f.Path == "__manifest__.json" {
+					if err := json.Unmarshal(f.Contents, &manifest); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if len(resp.Files) != len(resp.ObjectVersion) {
+				t.Errorf("files and versions out of sync: %d vs %d", len(resp.Files), len(resp.ObjectVersion))
+			}
+			if contents["small.txt"] != "ok" {
+				t.Errorf("small file changed: %q", contents["small.txt"])
+			}
+
+			switch policy {
+			case OversizeSkip:
+				if _, ok := contents["big.txt"]; ok {
+					t.Error("expected big.txt to be skipped")
+				}
+				if !reflect.DeepEqual(manifest.Skipped, []string{"big.txt"}) {
+					t.Errorf("expected big.txt listed as skipped, got %v", manifest.Skipped)
+				}
+			case OversizeTruncate:
+				if contents["big.txt"] != "0123" {
+					t.Errorf("expected big.txt truncated to 4 bytes, got %q", contents["big.txt"])
+				}
+				for _, e := range manifest.Files {
+					if e.Truncated != (e.Path == "big.txt") {
+						t.Errorf("unexpected truncated flag on %+v", e)
+					}
+				}
+			}
+			if got := testutil.ToFloat64(metrics.oversize.WithLabelValues(policy)); got != 1 {
+				t.Errorf("oversize metric = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestMountLimit(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("c.txt", "3", "v1", 420)
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v1", 420)
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	tests := map[string][]string{
+		"":   {"a.txt", "b.txt", "c.txt"},
+		"0":  nil,
+		"2":  {"a.txt", "b.txt"},
+		"3":  {"a.txt", "b.txt", "c.txt"},
+		"10": {"a.txt", "b.txt", "c.txt"},
+	}
+	for limit, want := range tests {
+		attrs := map[string]string{}
+		if limit != "" {
+			attrs[limitAttribute] = limit
+		}
+		if got := mountedPaths(t, srv, attrs); !reflect.DeepEqual(got, want) {
+			t.Errorf("limit %q: mounted %v, want %v", limit, got, want)
+		}
+	}
+
+	for _, limit := range []string{"-1", "many"} {
+		attrs := `{"` + limitAttribute + `":"` + limit + `"}`
+		if _, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: attrs}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("limit %q: expected InvalidArgument, got %v", limit, err)
+		}
+	}
+}
+
+func TestMountObjectsSelector(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v1", 420)
+	store.Set("c.txt", "3", "v1", 420)
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	tests := []struct {
+		attrs map[string]string
+		want  []string
+	}{
+		{map[string]string{}, []string{"a.txt", "b.txt", "c.txt"}},
+		{map[string]string{objectsAttribute: `["a.txt","c.txt"]`}, []string{"a.txt", "c.txt"}},
+		{map[string]string{objectsAttribute: `[{"objectName":"b.txt"}]`}, []string{"b.txt"}},
+		{map[string]string{objectsAttribute: "array:\n  - |\n    objectName: c.txt\n  - objectName: \"a.txt\"\n"}, []string{"a.txt", "c.txt"}},
+		{map[string]string{secretsAttribute: "b.txt, missing.txt"}, []string{"b.txt"}},
+		{map[string]string{objectsAttribute: "a.txt", secretsAttribute: "b.txt"}, []string{"a.txt", "b.txt"}},
+		{map[string]string{objectsAttribute: ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := mountedPaths(t, srv, tt.attrs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("attributes %v: mounted %v, want %v", tt.attrs, got, tt.want)
+		}
+	}
+
+	attrs := `{"` + objectsAttribute + `":"[1, 2]"}`
+	if _, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: attrs}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a malformed objects attribute, got %v", err)
+	}
+}
+
+func TestMountLabelsSelector(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "front.txt", Value: "1", Labels: map[string]string{"app": "frontend", "env": "test"}})
+	store.Put(Secret{Name: "back.txt", Value: "2", Labels: map[string]string{"app": "backend", "env": "test"}})
+	store.Put(Secret{Name: "plain.txt", Value: "3"})
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	tests := []struct {
+		labels string
+		want   []string
+	}{
+		{"", []string{"back.txt", "front.txt", "plain.txt"}},
+		{"app=frontend", []string{"front.txt"}},
+		{"env=test", []string{"back.txt", "front.txt"}},
+		{"app=backend, env=test", []string{"back.txt"}},
+		{"app=frontend,env=prod", nil},
+	}
+	for _, tt := range tests {
+		attrs := map[string]string{labelsAttribute: tt.labels}
+		if got := mountedPaths(t, srv, attrs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("labels %q: mounted %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+
+	attrs := `{"` + labelsAttribute + `":"app"}`
+	if _, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: attrs}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a malformed labels attribute, got %v", err)
+	}
+}
+
+func TestMountProviderCheck(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+
+	tests := []struct {
+		strict   bool
+		provider string
+		want     codes.Code
+	}{
+		{false, "csi-debugger", codes.OK},
+		{false, "vault", codes.OK},
+		{true, "csi-debugger", codes.OK},
+		{true, "debugger-b", codes.OK},
+		{true, "vault", codes.FailedPrecondition},
+		{true, "", codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		cfg := Config{ExpectedProviders: []string{"csi-debugger", "debugger-b"}, ProviderCheckStrict: tt.strict}
+		srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+		attrs := "{}"
+		if tt.provider != "" {
+			attrs = `{"` + providerAttribute + `":"` + tt.provider + `"}`
+		}
+		_, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{Attributes: attrs})
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("strict=%v provider=%q: got %v, want %v", tt.strict, tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestMountOmitVersions(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v1", 420)
+	srv := NewProviderServer(testLogger(), Config{OmitVersions: true, MountManifest: true, ManifestName: "manifest.json"}, store, NewMetrics(""))
+
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 3 {
+		t.Errorf("expected 2 secrets and the manifest, got %d files", len(resp.Files))
+	}
+	if len(resp.ObjectVersion) != 0 {
+		t.Errorf("expected no object versions, got %v", resp.ObjectVersion)
+	}
+}
+
+func TestMountFailingObjects(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	store.Put(Secret{Name: "b.txt", Value: "2", Version: "v1", Mode: 420, Failing: true})
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].Path != "a.txt" {
+		t.Errorf("expected only the healthy a.txt, got %v", resp.Files)
+	}
+	if resp.GetError().GetCode() != failingObjectErrorCode {
+		t.Errorf("expected the %s error code, got %v", failingObjectErrorCode, resp.GetError())
+	}
+
+	store.Update("b.txt", func(sec *Secret) { sec.Failing = false })
+	resp, err = srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 2 || resp.GetError() != nil {
+		t.Errorf("expected both files and no error once healthy, got %d files and %v", len(resp.Files), resp.GetError())
+	}
+}
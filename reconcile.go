@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// ObjectVersionDiff compares the objects of a mount with the currentObjectVersion the
+// driver reports, by object ID, each list sorted.
+type ObjectVersionDiff struct {
+	// New objects aren't in the target yet, Changed ones are at another version
+	New       []string `json:"new"`
+	Changed   []string `json:"changed"`
+	Unchanged []string `json:"unchanged"`
+	// Removed objects are in the target but no longer served
+	Removed []string `json:"removed"`
+}
+
+// diffObjectVersions classifies versions, the objects about to be served, against current.
+func diffObjectVersions(current, versions []*v1alpha1.ObjectVersion) ObjectVersionDiff {
+	known := make(map[string]string, len(current))
+	for _, v := range current {
+		known[v.GetId()] = v.GetVersion()
+	}
+	diff := ObjectVersionDiff{New: []string{}, Changed: []string{}, Unchanged: []string{}, Removed: []string{}}
+	for _, v := range versions {
+		old, ok := known[v.GetId()]
+		switch {
+		case !ok:
+			diff.New = append(diff.New, v.GetId())
+		case old != v.GetVersion():
+			diff.Changed = append(diff.Changed, v.GetId())
+		default:
+			diff.Unchanged = append(diff.Unchanged, v.GetId())
+		}
+		delete(known, v.GetId())
+	}
+	for id := range known {
+		diff.Removed = append(diff.Removed, id)
+	}
+	for _, ids := range [][]string{diff.New, diff.Changed, diff.Unchanged, diff.Removed} {
+		slices.Sort(ids)
+	}
+	return diff
+}
+
+// IsUnchanged reports whether the object id is at the same version in the target.
+func (d ObjectVersionDiff) IsUnchanged(id string) bool {
+	_, ok := slices.BinarySearch(d.Unchanged, id)
+	return ok
+}
+
+// logObjectVersionDiff logs what the driver rotation reconciler should rewrite in the target.
+func (s *ProviderServer) logObjectVersionDiff(targetPath string, diff ObjectVersionDiff) {
+	s.logger.Info("Mount object versions compared", "target_path", targetPath,
+		"new", diff.New, "changed", diff.Changed, "unchanged", diff.Unchanged, "removed", diff.Removed)
+}
+
+// onlyChanged drops the files the diff reports unchanged, the object versions are all
+// kept as they describe the whole mount.
+func (s *ProviderServer) onlyChanged(targetPath string, diff ObjectVersionDiff, files []*v1alpha1.File) []*v1alpha1.File {
+	changed := make([]*v1alpha1.File, 0, len(files))
+	for _, f := range files {
+		if diff.IsUnchanged(f.GetPath()) {
+			continue
+		}
+		changed = append(changed, f)
+	}
+	s.logger.Info("Mount only sending changed objects", "target_path", targetPath,
+		"changed", len(changed), "unchanged", len(files)-len(changed))
+	return changed
+}
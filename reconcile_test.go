@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMountOnlyChanged(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v2", 420)
+	store.Set("c.txt", "3", "v1", 420)
+
+	current := []*v1alpha1.ObjectVersion{
+		{Id: "a.txt", Version: "v1"},
+		{Id: "b.txt", Version: "v1"},
+	}
+	paths := func(cfg Config, current []*v1alpha1.ObjectVersion) ([]string, int) {
+		t.Helper()
+		srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{CurrentObjectVersion: current})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, f := range resp.Files {
+			paths = append(paths, f.Path)
+		}
+		return paths, len(resp.ObjectVersion)
+	}
+
+	all := []string{"a.txt", "b.txt", "c.txt"}
+	if got, _ := paths(Config{}, current); !slices.Equal(got, all) {
+		t.Errorf("flag off: got %v, want everything", got)
+	}
+	if got, _ := paths(Config{MountOnlyChanged: true}, nil); !slices.Equal(got, all) {
+		t.Errorf("no current versions: got %v, want everything", got)
+	}
+	got, versions := paths(Config{MountOnlyChanged: true}, current)
+	if !slices.Equal(got, []string{"b.txt", "c.txt"}) {
+		t.Errorf("only changed: got %v, want b.txt and c.txt", got)
+	}
+	if versions != 3 {
+		t.Errorf("expected every object version to be returned, got %d", versions)
+	}
+}
+
+func TestDiffObjectVersions(t *testing.T) {
+	current := []*v1alpha1.ObjectVersion{
+		{Id: "a.txt", Version: "v1"},
+		{Id: "b.txt", Version: "v1"},
+		{Id: "gone.txt", Version: "v1"},
+	}
+	versions := []*v1alpha1.ObjectVersion{
+		{Id: "c.txt", Version: "v1"},
+		{Id: "b.txt", Version: "v2"},
+		{Id: "a.txt", Version: "v1"},
+	}
+	diff := diffObjectVersions(current, versions)
+	want := ObjectVersionDiff{
+		New:       []string{"c.txt"},
+		Changed:   []string{"b.txt"},
+		Unchanged: []string{"a.txt"},
+		Removed:   []string{"gone.txt"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diffObjectVersions = %+v, want %+v", diff, want)
+	}
+
+	diff = diffObjectVersions(nil, versions)
+	if len(diff.New) != 3 || len(diff.Changed)+len(diff.Unchanged)+len(diff.Removed) != 0 {
+		t.Errorf("expected everything new without current versions, got %+v", diff)
+	}
+}
+
+func TestMountObjectVersionsLoggedOnCacheHits(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v2", 420)
+	var logs bytes.Buffer
+	metrics := NewMetrics("")
+	srv := NewProviderServer(slog.New(slog.NewTextHandler(&logs, nil)), Config{MountCache: true}, store, metrics)
+
+	req := &v1alpha1.MountRequest{Attributes: "{}", CurrentObjectVersion: []*v1alpha1.ObjectVersion{{Id: "a.txt", Version: "v1"}}}
+	for range 2 {
+		if _, err := srv.Mount(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := testutil.ToFloat64(metrics.mountCache.WithLabelValues("hit")); hits != 1 {
+		t.Fatalf("expected the second mount to be a cache hit, got %v hits", hits)
+	}
+	if n := strings.Count(logs.String(), `msg="Mount object versions compared" target_path="" new=[] changed=[a.txt]`); n != 2 {
+		t.Errorf("expected the changed objects logged on both mounts, got %d lines:\n%s", n, logs.String())
+	}
+}
+
+func TestMountOnlyChangedKeepsManifestComplete(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	store.Set("b.txt", "2", "v2", 420)
+	cfg := Config{MountOnlyChanged: true, MountManifest: true, ManifestName: "__manifest__.json"}
+	srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+
+	full, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{CurrentObjectVersion: full.ObjectVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed since the first mount, the manifest included
+	if len(resp.Files) != 0 || !reflect.DeepEqual(resp.ObjectVersion, full.ObjectVersion) {
+		t.Errorf("expected no file and the same versions, got %d files and %v vs %v", len(resp.Files), resp.ObjectVersion, full.ObjectVersion)
+	}
+
+	// A change sends the secret and a manifest still listing every file
+	store.Set("b.txt", "3", "v3", 420)
+	resp, err = srv.Mount(context.Background(), &v1alpha1.MountRequest{CurrentObjectVersion: full.ObjectVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	var manifest Manifest
+	for _, f := range resp.Files {
+		paths = append(paths, f.Path)
+		if f.Path == cfg.ManifestName {
+			if err := json.Unmarshal(f.Contents, &manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !slices.Equal(paths, []string{"b.txt", cfg.ManifestName}) {
+		t.Errorf("expected b.txt and the manifest, got %v", paths)
+	}
+	if len(manifest.Files) != 2 {
+		t.Errorf("expected the manifest to list every file, got %+v", manifest.Files)
+	}
+}
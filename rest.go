@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// secretRequest is the JSON body of POST /api/secrets and PUT /api/secrets/{name}.
+type secretRequest struct {
+	Name        string            `json:"name"`
+	Value       string            `json:"value"`
+	Version     string            `json:"version"`
+	Mode        *patchMode        `json:"mode"`
+	TTL         string            `json:"ttl"`
+	Annotations map[string]string `json:"annotations"`
+	Labels      map[string]string `json:"labels"`
+	Owner       string            `json:"owner"`
+}
+
+// decodeSecret reads a secretRequest body into a secret, mode defaulting to 0644. For
+// a request on the secret pathName, the body name may be omitted but not differ.
+func (w *WebServer) decodeSecret(r *http.Request, pathName string) (Secret, error) {
+	var req secretRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		// A bad mode fails decoding through patchMode
+		var v *validationError
+		if errors.As(err, &v) {
+			return Secret{}, err
+		}
+		return Secret{}, invalid(reasonJSON, fmt.Errorf("invalid JSON: %w", err))
+	}
+	if pathName != "" {
+		if req.Name != "" && req.Name != pathName {
+			return Secret{}, invalid(reasonInvalid, fmt.Errorf("body name %q doesn't match the path name %q", req.Name, pathName))
+		}
+		req.Name = pathName
+	}
+
+	sec := Secret{Name: req.Name, Value: req.Value, Version: req.Version, Mode: 420, Annotations: req.Annotations, Labels: req.Labels, Owner: req.Owner}
+	for k := range sec.Annotations {
+		if strings.TrimSpace(k) == "" {
+			return Secret{}, invalid(reasonAnnotations, errors.New("annotation keys can't be empty"))
+		}
+	}
+	if req.Mode != nil {
+		sec.Mode = int32(*req.Mode)
+	}
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		return Secret{}, invalid(reasonTTL, err)
+	}
+	sec.ExpiresAt = w.store.expiresAt(ttl)
+	if sec.Name == "" || sec.Value == "" {
+		return Secret{}, invalid(reasonRequired, errors.New("name and value required"))
+	}
+	if err := validateSecret(sec); err != nil {
+		return Secret{}, err
+	}
+	if err := w.checkVersion(sec.Version); err != nil {
+		return Secret{}, err
+	}
+	if err := w.checkSize(sec.Value); err != nil {
+		return Secret{}, err
+	}
+	return sec, nil
+}
+
+// Create stores sec unless a secret of that name exists, it reports whether it did.
+func (s *MemoryStore) Create(sec Secret) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.secrets[s.key(sec.Name)]; ok {
+		return false
+	}
+	s.putLocked(sec)
+	s.notifyLocked()
+	return true
+}
+
+// Replace stores sec, it reports whether it created the secret rather than replaced it.
+func (s *MemoryStore) Replace(sec Secret) (created bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created = s.replaceLocked(sec)
+	s.notifyLocked()
+	return created
+}
+
+func (s *MemoryStore) replaceLocked(sec Secret) (created bool) {
+	_, exists := s.secrets[s.key(sec.Name)]
+	s.putLocked(sec)
+	return !exists
+}
+
+// handleCreateSecret creates the secret of the JSON body, answering 201, or 409 when a
+// secret of that name exists.
+func (w *WebServer) handleCreateSecret(rw http.ResponseWriter, r *http.Request) {
+	sec, err := w.decodeSecret(r, "")
+	if err != nil {
+		w.rejectJSON(rw, rejectionReason(err), err.Error())
+		return
+	}
+	if !w.store.Create(sec) {
+		w.writeJSONError(rw, http.StatusConflict, "secret already exists")
+		return
+	}
+	sec, _ = w.store.Get(sec.Name)
+	w.logger.Info("Secret created via API", "name", sec.Name, "version", sec.Version)
+	w.writeJSON(rw, http.StatusCreated, w.view(sec, true))
+}
+
+// handleReplaceSecret stores the JSON body as the named secret, replacing every field of
+// an existing one. It answers 200 on replace and 201 on create.
+func (w *WebServer) handleReplaceSecret(rw http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	sec, err := w.decodeSecret(r, name)
+	if err != nil {
+		w.rejectJSON(rw, rejectionReason(err), err.Error())
+		return
+	}
+	var created bool
+	err = w.store.Owned(ownerToken(r), fixedNames(name), func() bool {
+		created = w.store.replaceLocked(sec)
+		return true
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	sec, _ = w.store.Get(name)
+	w.logger.Info("Secret replaced via API", "name", sec.Name, "version", sec.Version, "created", code == http.StatusCreated)
+	w.writeJSON(rw, code, w.view(sec, true))
+}
+
+// handleDeleteSecret deletes the named secret, answering 204, or 404 when it is missing.
+func (w *WebServer) handleDeleteSecret(rw http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var deleted bool
+	err := w.store.Owned(ownerToken(r), fixedNames(name), func() bool {
+		deleted = w.store.deleteLocked(name)
+		return deleted
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	if !deleted {
+		w.writeJSONError(rw, http.StatusNotFound, "secret not found")
+		return
+	}
+	w.logger.Info("Secret deleted via API", "name", name)
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSecretsRESTAPI(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	srv := newTestWebServer(t, store)
+
+	do := func(method, path, body string) (int, SecretView) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var view SecretView
+		if resp.StatusCode < 300 && resp.StatusCode != http.StatusNoContent {
+			if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, view
+	}
+
+	code, view := do(http.MethodPost, "/api/secrets", `{"name":"db.txt","value":"s3cret","version":"v1","mode":"0600"}`)
+	if code != http.StatusCreated || view.Name != "db.txt" || view.Mode != 0o600 || view.Value == nil || *view.Value != "s3cret" {
+		t.Fatalf("unexpected create: %d %+v", code, view)
+	}
+	if code, _ := do(http.MethodPost, "/api/secrets", `{"name":"db.txt","value":"other"}`); code != http.StatusConflict {
+		t.Errorf("expected 409 creating an existing secret, got %d", code)
+	}
+
+	code, view = do(http.MethodPut, "/api/secrets/db.txt", `{"value":"rotated","version":"v2"}`)
+	if code != http.StatusOK || view.Version != "v2" || view.Mode != 0o644 {
+		t.Errorf("unexpected replace: %d %+v", code, view)
+	}
+	if sec, _ := store.Get("db.txt"); sec.Value != "rotated" {
+		t.Errorf("replace not visible in the store: %+v", sec)
+	}
+	if code, _ := do(http.MethodPut, "/api/secrets/new.txt", `{"name":"new.txt","value":"x","mode":420}`); code != http.StatusCreated {
+		t.Errorf("expected 201 putting a new secret, got %d", code)
+	}
+
+	code, view = do(http.MethodPut, "/api/secrets/new.txt", `{"value":"x","annotations":{"ticket":"ABC-123"}}`)
+	if code != http.StatusOK || view.Annotations["ticket"] != "ABC-123" {
+		t.Errorf("unexpected replace with annotations: %d %+v", code, view)
+	}
+	if _, view = do(http.MethodGet, "/api/secrets/new.txt", ""); view.Annotations["ticket"] != "ABC-123" {
+		t.Errorf("annotations not returned by GET: %+v", view.Annotations)
+	}
+
+	for _, bad := range []struct{ method, path, body string }{
+		{http.MethodPost, "/api/secrets", `{"name":"a.txt"}`},
+		{http.MethodPost, "/api/secrets", `{"name":"a.txt","value":"x","mode":"rwz"}`},
+		{http.MethodPost, "/api/secrets", `{"name":"a.txt","value":"x","bogus":1}`},
+		{http.MethodPut, "/api/secrets/a.txt", `{"name":"b.txt","value":"x"}`},
+		{http.MethodPost, "/api/secrets", `{"name":"a.txt","value":"x","annotations":{" ":"y"}}`},
+	} {
+		if code, _ := do(bad.method, bad.path, bad.body); code != http.StatusBadRequest {
+			t.Errorf("%s %s %s: expected 400, got %d", bad.method, bad.path, bad.body, code)
+		}
+	}
+
+	if code, _ := do(http.MethodDelete, "/api/secrets/db.txt", ""); code != http.StatusNoContent {
+		t.Errorf("expected 204 deleting, got %d", code)
+	}
+	if code, _ := do(http.MethodDelete, "/api/secrets/db.txt", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting a missing secret, got %d", code)
+	}
+	if _, ok := store.Get("db.txt"); ok {
+		t.Error("deleted secret still in the store")
+	}
+}
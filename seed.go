@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// What Mount answers while REQUIRE_SEED seeding runs.
+const (
+	SeedingServe       = "serve"
+	SeedingUnavailable = "unavailable"
+	SeedingPlaceholder = "placeholder"
+)
+
+// seedingPlaceholderName is the only file mounted while seeding with MOUNT_WHILE_SEEDING=placeholder.
+const seedingPlaceholderName = "SEEDING_IN_PROGRESS"
+
+func validSeedingMode(m string) bool {
+	return m == SeedingServe || m == SeedingUnavailable || m == SeedingPlaceholder
+}
+
+// seedEntry is a secret of a SEED_FILE.
+type seedEntry struct {
+	Name    string `json:"name" yaml:"name"`
+	Value   string `json:"value" yaml:"value"`
+	Version string `json:"version" yaml:"version"`
+	Mode    int32  `json:"mode" yaml:"mode"`
+}
+
+// readSeedFile parses a SEED_FILE, JSON for a .json file, YAML otherwise. Unknown fields
+// are rejected so a typo doesn't silently seed an empty value.
+func readSeedFile(path string) ([]Secret, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []seedEntry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&entries)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(&entries)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]Secret, 0, len(entries))
+	for i, e := range entries {
+		if e.Name == "" {
+			return nil, fmt.Errorf("entry %d: missing name", i)
+		}
+		sec := Secret{Name: e.Name, Value: e.Value, Version: e.Version, Mode: e.Mode}
+		if sec.Version == "" {
+			sec.Version = "v1"
+		}
+		if sec.Mode == 0 {
+			sec.Mode = 420
+		}
+		secrets = append(secrets, sec)
+	}
+	return secrets, nil
+}
+
+// seedStore loads the initial secrets: the persisted store, or SEED_FILE (the debug
+// secret without one) when there is none, then INIT_DOTENV.
+func seedStore(logger *slog.Logger, cfg Config, store *MemoryStore) error {
+	loaded, err := store.LoadPersisted()
+	if err != nil {
+		return fmt.Errorf("failed to load persisted store %s: %w", cfg.PersistFile, err)
+	}
+	switch {
+	case loaded:
+	case cfg.SeedFile != "":
+		secrets, err := readSeedFile(cfg.SeedFile)
+		if err != nil {
+			return fmt.Errorf("failed to read SEED_FILE %s: %w", cfg.SeedFile, err)
+		}
+		// Import validates every entry before writing any, so a bad file leaves the store empty
+		summary, err := store.Import(secrets, CollisionOverwrite)
+		if err != nil {
+			return fmt.Errorf("failed to load SEED_FILE %s: %w", cfg.SeedFile, err)
+		}
+		logger.Info("seed file loaded", "file", cfg.SeedFile, "count", len(summary.Written()))
+	default:
+		// Pre-populate a dummy secret
+		store.Set("debug-secret.txt", "Initial value loaded at startup", "v1", 420)
+	}
+
+	if cfg.InitDotenv != "" {
+		content, err := os.ReadFile(cfg.InitDotenv)
+		if err != nil {
+			return fmt.Errorf("failed to read INIT_DOTENV: %w", err)
+		}
+		summary, err := store.ImportDotenv(string(content), "v1", CollisionOverwrite)
+		if err != nil {
+			return fmt.Errorf("failed to import INIT_DOTENV %s: %w", cfg.InitDotenv, err)
+		}
+		logger.Info("dotenv imported", "file", cfg.InitDotenv, "count", len(summary.Written()))
+	}
+	return nil
+}
+
+// Seeding reports whether REQUIRE_SEED is on and seeding hasn't completed yet.
+func (s *MemoryStore) Seeding() bool {
+	return s.seeding.Load()
+}
+
+// whileSeeding answers Mount according to MOUNT_WHILE_SEEDING while the store is seeding,
+// so pods don't briefly mount an empty directory. It returns false when Mount should proceed.
+func (s *ProviderServer) whileSeeding(targetPath string) (*v1alpha1.MountResponse, bool, error) {
+	if !s.store.Seeding() {
+		return nil, false, nil
+	}
+	switch s.cfg.MountWhileSeeding {
+	case SeedingUnavailable:
+		s.logger.Info("Mount unavailable while seeding", "target_path", targetPath)
+		return nil, true, status.Errorf(codes.Unavailable, "store seeding in progress")
+	case SeedingPlaceholder:
+		s.logger.Info("Mount placeholder served while seeding", "target_path", targetPath)
+		return &v1alpha1.MountResponse{
+			Files: []*v1alpha1.File{{
+				Path:     seedingPlaceholderName,
+				Mode:     0o444,
+				Contents: []byte("seeding in progress\n"),
+			}},
+			ObjectVersion: []*v1alpha1.ObjectVersion{{Id: seedingPlaceholderName, Version: "seeding"}},
+		}, true, nil
+	}
+	return nil, false, nil
+}
+
+// seedAndSignal runs seedStore with the servers already up, answering not ready until it
+// completes, then writes SEED_READY_FILE for init containers and exec probes to wait on.
+func seedAndSignal(logger *slog.Logger, cfg Config, store *MemoryStore) error {
+	start := time.Now()
+	if err := seedStore(logger, cfg, store); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(cfg.SeedReadyFile, []byte(time.Now().UTC().Format(time.RFC3339)+"\n")); err != nil {
+		return fmt.Errorf("failed to write SEED_READY_FILE: %w", err)
+	}
+	store.seeding.Store(false)
+	logger.Info("store seeded", "ready_file", cfg.SeedReadyFile, "took", time.Since(start))
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestRequireSeed(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		RequireSeed:   true,
+		SeedReadyFile: filepath.Join(dir, "seeded"),
+		InitDotenv:    filepath.Join(dir, "init.env"),
+	}
+	if err := os.WriteFile(cfg.InitDotenv, []byte("DB_PASSWORD=hunter2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.seeding.Store(true)
+	web := newTestWeb(t, cfg, store)
+
+	readyz := func() int {
+		rec := httptest.NewRecorder()
+		web.handleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before seeding, got %d", code)
+	}
+	if _, err := os.Stat(cfg.SeedReadyFile); !os.IsNotExist(err) {
+		t.Fatalf("ready file exists before seeding: %v", err)
+	}
+
+	if err := seedAndSignal(testLogger(), cfg, store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.SeedReadyFile); err != nil {
+		t.Errorf("expected the ready file after seeding: %v", err)
+	}
+	if sec, ok := store.Get("DB_PASSWORD"); !ok || sec.Value != "hunter2" {
+		t.Errorf("INIT_DOTENV not seeded: %+v", sec)
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Errorf("expected 200 after seeding, got %d", code)
+	}
+}
+
+func TestMountWhileSeeding(t *testing.T) {
+	for _, mode := range []string{SeedingServe, SeedingUnavailable, SeedingPlaceholder} {
+		cfg := Config{RequireSeed: true, SeedReadyFile: filepath.Join(t.TempDir(), "seeded"), MountWhileSeeding: mode}
+		store := NewMemoryStore(testLogger(), cfg)
+		store.seeding.Store(true)
+		srv := NewProviderServer(testLogger(), cfg, store, NewMetrics(""))
+
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+		switch mode {
+		case SeedingServe:
+			if err != nil || len(resp.GetFiles()) != 0 {
+				t.Errorf("serve: expected the empty store, got %v %v", resp, err)
+			}
+		case SeedingUnavailable:
+			if status.Code(err) != codes.Unavailable {
+				t.Errorf("unavailable: expected Unavailable, got %v", err)
+			}
+		case SeedingPlaceholder:
+			if err != nil || len(resp.GetFiles()) != 1 || resp.GetFiles()[0].GetPath() != seedingPlaceholderName {
+				t.Errorf("placeholder: expected the placeholder file, got %v %v", resp, err)
+			}
+		}
+
+		if err := seedAndSignal(testLogger(), cfg, store); err != nil {
+			t.Fatal(err)
+		}
+		resp, err = srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+		if err != nil || len(resp.GetFiles()) != 1 || resp.GetFiles()[0].GetPath() != "debug-secret.txt" {
+			t.Errorf("%s: expected the seeded store after seeding, got %v %v", mode, resp, err)
+		}
+	}
+}
+
+func TestSeedFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	files := map[string]string{
+		"json": write("seed.json", `[{"name":"db/password","value":"hunter2","version":"v3","mode":256},{"name":"token"}]`),
+		"yaml": write("seed.yaml", "- name: db/password\n  value: hunter2\n  version: v3\n  mode: 256\n- name: token\n"),
+	}
+	for format, path := range files {
+		store := NewMemoryStore(testLogger(), Config{SeedFile: path})
+		if err := seedStore(testLogger(), Config{SeedFile: path}, store); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if sec, ok := store.Get("db/password"); !ok || sec.Value != "hunter2" || sec.Version != "v3" || sec.Mode != 256 {
+			t.Errorf("%s: unexpected db/password %+v", format, sec)
+		}
+		if sec, ok := store.Get("token"); !ok || sec.Version != "v1" || sec.Mode != 420 {
+			t.Errorf("%s: expected defaults for token, got %+v", format, sec)
+		}
+		if _, ok := store.Get("debug-secret.txt"); ok {
+			t.Errorf("%s: debug secret seeded along the seed file", format)
+		}
+	}
+
+	for name, path := range map[string]string{
+		"missing":       filepath.Join(dir, "missing.yaml"),
+		"malformed":     write("malformed.json", `[{"name":"a"`),
+		"unknown field": write("unknown.yaml", "- name: a\n  valeu: b\n"),
+		"no name":       write("noname.json", `[{"value":"b"}]`),
+		"bad mode":      write("badmode.yaml", "- name: ok\n  value: \"1\"\n- name: other\n  mode: rw\n"),
+	} {
+		store := NewMemoryStore(testLogger(), Config{})
+		if err := seedStore(testLogger(), Config{SeedFile: path}, store); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if n := store.Len(); n != 0 {
+			t.Errorf("%s: expected an empty store, got %d secrets", name, n)
+		}
+	}
+
+	store := NewMemoryStore(testLogger(), Config{})
+	if err := seedStore(testLogger(), Config{}, store); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("debug-secret.txt"); !ok {
+		t.Error("expected the debug secret without a seed file")
+	}
+}
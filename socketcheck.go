@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// socketInDir reports whether socket is directly in dir, where the driver looks for
+// <provider>.sock files.
+func socketInDir(socket, dir string) bool {
+	abs, err := filepath.Abs(socket)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	return filepath.Dir(abs) == absDir
+}
+
+// checkSocketDir warns when SOCKET_PATH isn't in EXPECTED_PROVIDERS_DIR, the most common
+// reason for the driver never calling the provider. It doesn't stop the startup.
+func checkSocketDir(logger *slog.Logger, cfg Config) bool {
+	if cfg.ExpectedProvidersDir == "" {
+		return true
+	}
+	if !socketInDir(cfg.SocketPath, cfg.ExpectedProvidersDir) {
+		logger.Warn("SOCKET_PATH is not in the driver providers directory, the driver won't find the provider",
+			"socket", cfg.SocketPath,
+			"expected_dir", cfg.ExpectedProvidersDir,
+		)
+		return false
+	}
+	if !strings.HasSuffix(cfg.SocketPath, ".sock") {
+		logger.Warn("SOCKET_PATH should be named <provider>.sock, after the provider field of the SecretProviderClass",
+			"socket", cfg.SocketPath,
+		)
+		return false
+	}
+	return true
+}
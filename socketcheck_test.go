@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestCheckSocketDir(t *testing.T) {
+	const dir = "/var/lib/kubelet/plugins/secrets-store.csi.k8s.io/providers"
+	tests := map[string]bool{
+		dir + "/csi-debugger.sock":          true,
+		dir + "/../providers/debugger.sock": true,
+		dir + "/csi-debugger":               false,
+		dir + "/nested/csi-debugger.sock":   false,
+		"/tmp/csi-debugger.sock":            false,
+		"/var/lib/kubelet/plugins/x.sock":   false,
+	}
+	for socket, want := range tests {
+		if got := checkSocketDir(testLogger(), Config{SocketPath: socket, ExpectedProvidersDir: dir}); got != want {
+			t.Errorf("checkSocketDir(%q) = %v, want %v", socket, got, want)
+		}
+	}
+	if !checkSocketDir(testLogger(), Config{SocketPath: "/tmp/csi-debugger.sock"}) {
+		t.Error("expected no check without EXPECTED_PROVIDERS_DIR")
+	}
+}
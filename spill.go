@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Spilled reports whether the secret value lives in a temp file rather than in memory.
+func (sec Secret) Spilled() bool {
+	return sec.spillPath != ""
+}
+
+// Size returns the length of the stored value in bytes.
+func (sec Secret) Size() int {
+	if sec.Spilled() {
+		return sec.spillSize
+	}
+	return len(sec.Value)
+}
+
+// spillLocked moves the value of sec to a temp file when it exceeds SPILL_THRESHOLD_BYTES,
+// falling back to keeping it in memory if the file can't be written. s.mu must be held for writing.
+func (s *MemoryStore) spillLocked(sec *Secret) {
+	if s.cfg.SpillThresholdBytes <= 0 || len(sec.Value) <= s.cfg.SpillThresholdBytes {
+		return
+	}
+	if s.spillDir == "" {
+		dir, err := os.MkdirTemp("", "csi-debugger-spill-")
+		if err != nil {
+			s.logger.Error("failed to create spill directory, keeping value in memory", "name", sec.Name, "error", err)
+			return
+		}
+		s.spillDir = dir
+	}
+	f, err := os.CreateTemp(s.spillDir, "secret-")
+	if err != nil {
+		s.logger.Error("failed to create spill file, keeping value in memory", "name", sec.Name, "error", err)
+		return
+	}
+	_, err = f.WriteString(sec.Value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		s.logger.Error("failed to write spill file, keeping value in memory", "name", sec.Name, "error", err)
+		return
+	}
+	s.logger.Debug("spilled secret value to disk", "name", sec.Name, "size", len(sec.Value), "path", f.Name())
+	sec.spillPath = f.Name()
+	sec.spillSize = len(sec.Value)
+	sec.Value = ""
+}
+
+// loadLocked returns sec with its value read back from disk if it was spilled.
+func (s *MemoryStore) loadLocked(sec Secret) (Secret, error) {
+	if !sec.Spilled() {
+		return sec, nil
+	}
+	b, err := os.ReadFile(sec.spillPath)
+	if err != nil {
+		return sec, fmt.Errorf("reading spilled value of %s: %w", sec.Name, err)
+	}
+	sec.Value = string(b)
+	return sec, nil
+}
+
+// removeSpillLocked deletes the spill file backing sec, if any.
+func (s *MemoryStore) removeSpillLocked(sec Secret) {
+	if !sec.Spilled() {
+		return
+	}
+	if err := os.Remove(sec.spillPath); err != nil && !os.IsNotExist(err) {
+		s.logger.Error("failed to remove spill file", "name", sec.Name, "path", sec.spillPath, "error", err)
+	}
+}
+
+// Close removes every spill file, it is called on shutdown.
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.spillDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(s.spillDir)
+	s.spillDir = ""
+	for name, sec := range s.secrets {
+		if sec.Spilled() {
+			delete(s.secrets, name)
+		}
+	}
+	for name, history := range s.history {
+		s.history[name] = slices.DeleteFunc(history, func(e SecretVersion) bool { return e.spillPath != "" })
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMemoryStoreSpill(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{SpillThresholdBytes: 16})
+	big := strings.Repeat("x", 64)
+	store.Set("big.bin", big, "v1", 420)
+	store.Set("small.txt", "tiny", "v1", 420)
+
+	store.mu.RLock()
+	stored := store.secrets["big.bin"]
+	store.mu.RUnlock()
+	if !stored.Spilled() || stored.Value != "" || stored.Size() != len(big) {
+		t.Fatalf("expected big value to be spilled, got %+v", stored)
+	}
+	if _, err := os.Stat(stored.spillPath); err != nil {
+		t.Fatalf("spill file missing: %v", err)
+	}
+	if sec, _ := store.Get("small.txt"); sec.Spilled() {
+		t.Error("small value should stay in memory")
+	}
+
+	if sec, _ := store.Get("big.bin"); sec.Value != big {
+		t.Error("Get did not read the spilled value back")
+	}
+	files, _, _ := store.GetFiles(nil)
+	for _, f := range files {
+		if f.Path == "big.bin" && string(f.Contents) != big {
+			t.Error("GetFiles did not read the spilled value back")
+		}
+	}
+
+	store.Delete("big.bin")
+	if _, err := os.Stat(stored.spillPath); !os.IsNotExist(err) {
+		t.Errorf("spill file should be removed on delete, stat: %v", err)
+	}
+
+	store.Set("big.bin", big, "v2", 420)
+	dir := store.spillDir
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("spill directory should be removed on close, stat: %v", err)
+	}
+}
+
+// BenchmarkMemoryStoreHeap compares the heap held by 32 secrets of 1 MiB with and without spilling.
+func BenchmarkMemoryStoreHeap(b *testing.B) {
+	value := strings.Repeat("x", 1<<20)
+	for _, threshold := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("threshold=%d", threshold), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				store := NewMemoryStore(testLogger(), Config{SpillThresholdBytes: threshold})
+				for n := 0; n < 32; n++ {
+					// Build a distinct string so the heap actually holds each value
+					store.Set(fmt.Sprintf("s%d", n), value[:len(value)-1]+fmt.Sprint(n%10), "v1", 420)
+				}
+				runtime.GC()
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				b.ReportMetric(float64(ms.HeapInuse)/(1<<20), "heap-MiB")
+				store.Close()
+			}
+		})
+	}
+}
+
+func TestHistoryKeepsSpilledValuesOnDisk(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{SpillThresholdBytes: 16, SecretHistoryDepth: 1})
+	big1, big2 := strings.Repeat("1", 64), strings.Repeat("2", 64)
+	store.Set("big.bin", big1, "v1", 420)
+	store.mu.RLock()
+	first := store.secrets["big.bin"].spillPath
+	store.mu.RUnlock()
+	store.Set("big.bin", big2, "v2", 420)
+
+	store.mu.RLock()
+	entry := store.history["big.bin"][0]
+	store.mu.RUnlock()
+	if entry.Value != "" || entry.spillPath != first {
+		t.Fatalf("expected the history entry to keep the spill file of v1, got %+v", entry)
+	}
+	if history, _ := store.History("big.bin"); len(history) != 1 || history[0].Value != big1 || history[0].Version != "v1" {
+		t.Fatalf("History did not read the spilled value back: %+v", history)
+	}
+
+	// Past SECRET_HISTORY_DEPTH the evicted entry's file is removed
+	store.Set("big.bin", big1, "v3", 420)
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("evicted history spill file should be removed, stat: %v", err)
+	}
+	store.mu.RLock()
+	second := store.history["big.bin"][0].spillPath
+	store.mu.RUnlock()
+	store.Delete("big.bin")
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Errorf("history spill file should be removed on delete, stat: %v", err)
+	}
+	store.Close()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// staticFS holds the favicon and stylesheet of the admin UI.
+//
+//go:embed static
+var staticFS embed.FS
+
+// staticHandler serves the embedded static directory, long cached since assets only
+// change with the binary.
+func staticHandler() http.Handler {
+	sub, err := fs.Sub(staticFS, "static")
+	if err != nil {
+		panic(err)
+	}
+	files := http.FileServerFS(sub)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Cache-Control", "public, max-age=86400")
+		files.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStaticAssets(t *testing.T) {
+	srv := newTestWebServer(t, NewMemoryStore(testLogger(), Config{}))
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/favicon.ico", "image/"},
+		{"/static/admin.css", "text/css"},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || len(body) == 0 {
+			t.Errorf("%s: got %d with %d bytes", tt.path, resp.StatusCode, len(body))
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("%s: unexpected content type %q", tt.path, ct)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/static/missing.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing asset, got %d", resp.StatusCode)
+	}
+}
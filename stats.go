@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// pathStats tracks Mount arrivals for a single target path.
+type pathStats struct {
+	Count               int       `json:"count"`
+	LastMount           time.Time `json:"lastMount"`
+	LastIntervalSeconds float64   `json:"lastIntervalSeconds"`
+	AvgIntervalSeconds  float64   `json:"avgIntervalSeconds"`
+
+	totalInterval time.Duration
+}
+
+// maxTrackedPaths bounds MountStats, pods come and go and each brings a new target path.
+const maxTrackedPaths = 1024
+
+// MountStats measures the time between successive Mount calls per target path,
+// which is how often the driver polls for rotation.
+type MountStats struct {
+	mu    sync.Mutex
+	paths map[string]*pathStats
+}
+
+func NewMountStats() *MountStats {
+	return &MountStats{paths: make(map[string]*pathStats)}
+}
+
+// Observe records a Mount for targetPath at now and returns the interval since the
+// previous one, ok is false on the first Mount of a path.
+func (m *MountStats) Observe(targetPath string, now time.Time) (interval time.Duration, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ps, found := m.paths[targetPath]
+	if !found {
+		if len(m.paths) >= maxTrackedPaths {
+			m.evictOldestLocked()
+		}
+		ps = &pathStats{}
+		m.paths[targetPath] = ps
+	}
+	ps.Count++
+	if found {
+		interval = now.Sub(ps.LastMount)
+		ps.totalInterval += interval
+		ps.LastIntervalSeconds = interval.Seconds()
+		ps.AvgIntervalSeconds = (ps.totalInterval / time.Duration(ps.Count-1)).Seconds()
+	}
+	ps.LastMount = now
+	return interval, found
+}
+
+// evictOldestLocked drops the path mounted least recently, it is most likely gone.
+func (m *MountStats) evictOldestLocked() {
+	var oldest string
+	var oldestMount time.Time
+	for p, ps := range m.paths {
+		if oldest == "" || ps.LastMount.Before(oldestMount) {
+			oldest, oldestMount = p, ps.LastMount
+		}
+	}
+	delete(m.paths, oldest)
+}
+
+// Snapshot returns a copy of the per target path statistics.
+func (m *MountStats) Snapshot() map[string]pathStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]pathStats, len(m.paths))
+	for p, ps := range m.paths {
+		out[p] = *ps
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMountStatsObserve(t *testing.T) {
+	stats := NewMountStats()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, ok := stats.Observe("/pod-a", start); ok {
+		t.Error("first mount of a path should not report an interval")
+	}
+	stats.Observe("/pod-a", start.Add(2*time.Minute))
+	interval, ok := stats.Observe("/pod-a", start.Add(6*time.Minute))
+	if !ok || interval != 4*time.Minute {
+		t.Errorf("expected a 4m interval, got %s (%v)", interval, ok)
+	}
+	stats.Observe("/pod-b", start)
+
+	snap := stats.Snapshot()
+	a := snap["/pod-a"]
+	if a.Count != 3 || a.LastIntervalSeconds != 240 || a.AvgIntervalSeconds != 180 {
+		t.Errorf("unexpected stats for /pod-a: %+v", a)
+	}
+	if b := snap["/pod-b"]; b.Count != 1 || b.AvgIntervalSeconds != 0 {
+		t.Errorf("unexpected stats for /pod-b: %+v", b)
+	}
+}
+
+func TestMountStatsBounded(t *testing.T) {
+	stats := NewMountStats()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	stats.Observe("/pod-kept", start.Add(time.Hour))
+	for i := range maxTrackedPaths {
+		stats.Observe(fmt.Sprintf("/pod-%d", i), start.Add(time.Duration(i)*time.Second))
+	}
+
+	snap := stats.Snapshot()
+	if len(snap) != maxTrackedPaths {
+		t.Fatalf("expected %d tracked paths, got %d", maxTrackedPaths, len(snap))
+	}
+	if _, ok := snap["/pod-0"]; ok {
+		t.Error("the least recently mounted path should have been evicted")
+	}
+	if _, ok := snap["/pod-kept"]; !ok {
+		t.Error("a recently mounted path should be kept")
+	}
+}
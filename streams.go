@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// streamRegistry tracks the open event streams, so shutdown can send them a final close
+// event instead of leaving clients on a dead connection.
+type streamRegistry struct {
+	mu      sync.Mutex
+	closing chan struct{}
+	closed  bool
+	active  int
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{closing: make(chan struct{})}
+}
+
+// join registers a stream, it returns the channel closed on shutdown and false when the
+// server is already closing.
+func (r *streamRegistry) join() (<-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, false
+	}
+	r.active++
+	return r.closing, true
+}
+
+func (r *streamRegistry) leave() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active--
+}
+
+// Active returns the number of open streams.
+func (r *streamRegistry) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// Close tells every stream to send its close event and return, it is registered with
+// http.Server.RegisterOnShutdown so Shutdown waits for the streams to finish.
+func (r *streamRegistry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.closed = true
+		close(r.closing)
+	}
+}
+
+// handleEvents streams the store generation as server-sent events, once on connect then
+// on every change, and a final close event when the server shuts down.
+func (w *WebServer) handleEvents(rw http.ResponseWriter, r *http.Request) {
+	closing, ok := w.streams.join()
+	if !ok {
+		w.writeJSONError(rw, http.StatusServiceUnavailable, "server closing")
+		return
+	}
+	defer w.streams.leave()
+
+	rc := http.NewResponseController(rw)
+	// The stream lives longer than HTTP_WRITE_TIMEOUT
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		w.logger.Debug("failed to clear the event stream write deadline", "error", err)
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	send := func(event, data string) error {
+		if _, err := fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for {
+		// Grab the channel before reading so a change in between isn't missed
+		changed := w.store.Changed()
+		if err := send("generation", strconv.FormatUint(w.store.Generation(), 10)); err != nil {
+			return
+		}
+		select {
+		case <-changed:
+		case <-closing:
+			if err := send("close", "server closing"); err != nil {
+				w.logger.Debug("failed to send the event stream close event", "error", err)
+			}
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
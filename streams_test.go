@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsClosedOnShutdown(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	server := newHTTPServer(Config{}, "", mux)
+	server.RegisterOnShutdown(web.streams.Close)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+
+	resp, err := http.Get("http://" + lis.Addr().String() + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+	events := bufio.NewScanner(resp.Body)
+	next := func() string {
+		t.Helper()
+		for events.Scan() {
+			if e, ok := strings.CutPrefix(events.Text(), "event: "); ok {
+				return e
+			}
+		}
+		t.Fatalf("stream ended: %v", events.Err())
+		return ""
+	}
+
+	if e := next(); e != "generation" {
+		t.Fatalf("expected the generation on connect, got %q", e)
+	}
+	store.Set("a.txt", "1", "v1", 420)
+	if e := next(); e != "generation" {
+		t.Fatalf("expected the generation on change, got %q", e)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown didn't drain the stream: %v", err)
+	}
+	if e := next(); e != "close" {
+		t.Fatalf("expected the close event on shutdown, got %q", e)
+	}
+	if web.streams.Active() != 0 {
+		t.Errorf("expected no open stream after shutdown, got %d", web.streams.Active())
+	}
+}
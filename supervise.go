@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// maxRestartBackoff caps the doubling backoff between gRPC restarts
+	maxRestartBackoff = 30 * time.Second
+	// restartResetAfter is how long a run must last for the restart budget to be refilled
+	restartResetAfter = time.Minute
+)
+
+// superviseGRPC calls serve until ctx is cancelled, restarting it up to attempts times
+// in a row when it fails, so a transient socket error doesn't tear down the admin
+// server and the store along with it.
+func superviseGRPC(ctx context.Context, logger *slog.Logger, attempts int, backoff time.Duration, serve func(ctx context.Context) error) error {
+	failures := 0
+	delay := backoff
+	for {
+		start := time.Now()
+		err := serve(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if time.Since(start) > restartResetAfter {
+			failures, delay = 0, backoff
+		}
+		if failures >= attempts {
+			return err
+		}
+		failures++
+		logger.Warn("gRPC server failed, restarting", "error", err, "attempt", failures, "max_attempts", attempts, "backoff", delay)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		}
+		delay = min(delay*2, maxRestartBackoff)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSuperviseGRPCRecovers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	serving := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- superviseGRPC(ctx, testLogger(), 3, time.Millisecond, func(ctx context.Context) error {
+			calls++
+			if calls <= 2 {
+				return errors.New("accept: transient socket error")
+			}
+			close(serving)
+			<-ctx.Done()
+			return nil
+		})
+	}()
+
+	select {
+	case <-serving:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server wasn't restarted after failing")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean shutdown after recovering, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("serve called %d times, want 3", calls)
+	}
+}
+
+func TestSuperviseGRPCGivesUp(t *testing.T) {
+	serveErr := errors.New("socket gone")
+	calls := 0
+	err := superviseGRPC(context.Background(), testLogger(), 2, time.Millisecond, func(context.Context) error {
+		calls++
+		return serveErr
+	})
+	if !errors.Is(err, serveErr) {
+		t.Errorf("expected the serve error once attempts are exhausted, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("serve called %d times, want 1 plus 2 restarts", calls)
+	}
+}
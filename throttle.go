@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// maxMountDelay bounds the MOUNT_DELAY set through the admin API.
+const maxMountDelay = 10 * time.Minute
+
+// throttleDelay is the time to send size bytes at bytesPerSec, 0 when unthrottled.
+func throttleDelay(size int, bytesPerSec int64) time.Duration {
+	if bytesPerSec <= 0 || size <= 0 {
+		return 0
+	}
+	return time.Duration(float64(size) / float64(bytesPerSec) * float64(time.Second))
+}
+
+// throttle holds the Mount response for MOUNT_DELAY plus as long as
+// MOUNT_THROTTLE_BYTES_PER_SEC takes to send its contents, it returns early with the
+// context error, DeadlineExceeded when the driver timeout fires, when the call is cancelled.
+func (s *ProviderServer) throttle(ctx context.Context, targetPath string, resp *v1alpha1.MountResponse) error {
+	fixed := time.Duration(s.mountDelay.Load())
+	if s.cfg.MountThrottleBytesPerSec <= 0 && fixed <= 0 {
+		return nil
+	}
+	size := 0
+	for _, f := range resp.GetFiles() {
+		size += len(f.GetContents())
+	}
+	delay := fixed + throttleDelay(size, s.cfg.MountThrottleBytesPerSec)
+	if delay <= 0 {
+		return nil
+	}
+	s.logger.Info("Mount throttled", "target_path", targetPath, "bytes", size, "mount_delay", fixed, "delay", delay)
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		s.logger.Warn("Mount cancelled while delayed", "target_path", targetPath, "delay", delay, "error", ctx.Err())
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// mountDelayBody is the JSON of GET and POST /delay.
+type mountDelayBody struct {
+	Delay string `json:"delay"`
+}
+
+func (w *WebServer) handleGetMountDelay(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, mountDelayBody{Delay: time.Duration(w.provider.mountDelay.Load()).String()})
+}
+
+// handleSetMountDelay replaces MOUNT_DELAY, "0s" or an empty delay removes it.
+func (w *WebServer) handleSetMountDelay(rw http.ResponseWriter, r *http.Request) {
+	var body mountDelayBody
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	var d time.Duration
+	if body.Delay != "" {
+		var err error
+		d, err = time.ParseDuration(body.Delay)
+		if err != nil || d < 0 || d > maxMountDelay {
+			w.writeJSONError(rw, http.StatusBadRequest, fmt.Sprintf("delay must be a duration between 0 and %s", maxMountDelay))
+			return
+		}
+	}
+	w.provider.mountDelay.Store(int64(d))
+	w.logger.Info("Mount delay set via API", "delay", d)
+	w.writeJSON(rw, http.StatusOK, mountDelayBody{Delay: d.String()})
+}
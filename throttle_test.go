@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestThrottleDelay(t *testing.T) {
+	if d := throttleDelay(1000, 0); d != 0 {
+		t.Errorf("expected no delay when unthrottled, got %s", d)
+	}
+	if d := throttleDelay(500, 1000); d != 500*time.Millisecond {
+		t.Errorf("expected 500ms, got %s", d)
+	}
+	if small, large := throttleDelay(100, 1000), throttleDelay(400, 1000); large != 4*small {
+		t.Errorf("expected 4 times the delay for 4 times the bytes, got %s and %s", small, large)
+	}
+}
+
+func TestMountThrottle(t *testing.T) {
+	mount := func(size int) time.Duration {
+		t.Helper()
+		store := NewMemoryStore(testLogger(), Config{})
+		store.Set("a.txt", strings.Repeat("x", size), "v1", 420)
+		srv := NewProviderServer(testLogger(), Config{MountThrottleBytesPerSec: 1000}, store, NewMetrics(""))
+		start := time.Now()
+		if _, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{}); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+	if d := mount(50); d < 50*time.Millisecond {
+		t.Errorf("expected at least 50ms for 50 bytes, took %s", d)
+	}
+	if d := mount(200); d < 200*time.Millisecond {
+		t.Errorf("expected at least 200ms for 200 bytes, took %s", d)
+	}
+
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", strings.Repeat("x", 10000), "v1", 420)
+	srv := NewProviderServer(testLogger(), Config{MountThrottleBytesPerSec: 1000}, store, NewMetrics(""))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := srv.Mount(ctx, &v1alpha1.MountRequest{})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded once the caller gives up, got %v", err)
+	}
+}
+
+func TestMountDelay(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	web := newTestWeb(t, Config{MountDelay: 100 * time.Millisecond}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	mount := func(ctx context.Context) (time.Duration, error) {
+		start := time.Now()
+		_, err := web.provider.Mount(ctx, &v1alpha1.MountRequest{Attributes: "{}"})
+		return time.Since(start), err
+	}
+	if d, err := mount(context.Background()); err != nil || d < 100*time.Millisecond {
+		t.Errorf("expected a successful Mount after 100ms, took %s with error %v", d, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if d, err := mount(ctx); status.Code(err) != codes.DeadlineExceeded || d >= 100*time.Millisecond {
+		t.Errorf("expected DeadlineExceeded when the deadline fires mid-delay, took %s with error %v", d, err)
+	}
+
+	post := func(body string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/delay", strings.NewReader(body)))
+		return rec.Code
+	}
+	for _, body := range []string{`{"delay":"soon"}`, `{"delay":"-1s"}`, `{"delay":"1h"}`, `{"latency":"1s"}`} {
+		if code := post(body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, code)
+		}
+	}
+	if code := post(`{"delay":"0s"}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if d, err := mount(context.Background()); err != nil || d >= 100*time.Millisecond {
+		t.Errorf("expected an immediate Mount once the delay is removed, took %s with error %v", d, err)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/delay", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `"delay":"0s"`) {
+		t.Errorf("unexpected GET /delay body %s", body)
+	}
+}
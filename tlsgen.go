@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Key types accepted by generateTLS.
+const (
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+)
+
+const defaultTLSValidity = 365 * 24 * time.Hour
+
+// generateTLS returns a PEM self-signed certificate for cn, also its DNS or IP SAN,
+// valid for validity, and its PKCS #8 PEM private key of keyType.
+func generateTLS(cn string, validity time.Duration, keyType string) (certPEM, keyPEM []byte, err error) {
+	var key crypto.Signer
+	switch keyType {
+	case KeyTypeECDSA, "":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, fmt.Errorf("unknown key type %q", keyType)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{cn}
+	}
+	// RSA keys also encipher the TLS 1.2 key exchange
+	if keyType == KeyTypeRSA {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// handleGenerateTLS stores a new self-signed certificate for the cn query parameter and
+// its key as two secrets, cert (default tls.crt, mode 0644) and key (default tls.key,
+// mode 0600). validity (default 8760h), key_type (ecdsa, rsa or ed25519, default ecdsa)
+// and version (default v1) are optional.
+func (w *WebServer) handleGenerateTLS(rw http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cn := q.Get("cn")
+	if cn == "" {
+		w.rejectJSON(rw, reasonRequired, "cn required")
+		return
+	}
+	validity := defaultTLSValidity
+	if v := q.Get("validity"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			w.rejectJSON(rw, reasonInvalid, "validity must be a positive duration")
+			return
+		}
+		validity = d
+	}
+	certName, keyName := q.Get("cert"), q.Get("key")
+	if certName == "" {
+		certName = "tls.crt"
+	}
+	if keyName == "" {
+		keyName = "tls.key"
+	}
+	version := q.Get("version")
+	if version == "" {
+		version = "v1"
+	}
+	if err := w.checkVersion(version); err != nil {
+		w.rejectJSON(rw, reasonVersion, err.Error())
+		return
+	}
+
+	certPEM, keyPEM, err := generateTLS(cn, validity, q.Get("key_type"))
+	if err != nil {
+		w.rejectJSON(rw, reasonInvalid, err.Error())
+		return
+	}
+	pair := []Secret{
+		{Name: certName, Value: string(certPEM), Version: version, Mode: 0o644},
+		{Name: keyName, Value: string(keyPEM), Version: version, Mode: 0o600},
+	}
+	for _, sec := range pair {
+		if err := validateSecret(sec); err != nil {
+			w.rejectJSON(rw, rejectionReason(err), fmt.Sprintf("secret %q: %s", sec.Name, err))
+			return
+		}
+	}
+	// Both files in a single change, a pod never mounts a cert with the previous key
+	err = w.store.Owned(ownerToken(r), fixedNames(certName, keyName), func() bool {
+		for _, sec := range pair {
+			w.store.putLocked(sec)
+		}
+		return true
+	})
+	if w.ownerRejected(rw, r, err) {
+		return
+	}
+	w.logger.Info("TLS pair generated via API", "cn", cn, "cert", certName, "key", keyName, "validity", validity)
+	w.writeJSON(rw, http.StatusOK, map[string]string{"cert": certName, "key": keyName})
+}
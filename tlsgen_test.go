@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGenerateTLS(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	for _, keyType := range []string{"", KeyTypeRSA, KeyTypeEd25519} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/secrets/generate-tls?cn=example.com&validity=48h&version=v2&key_type="+keyType, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d %s", keyType, rec.Code, rec.Body)
+		}
+		var names map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &names); err != nil {
+			t.Fatal(err)
+		}
+		crt, ok := store.Get(names["cert"])
+		if !ok || crt.Mode != 0o644 || crt.Version != "v2" {
+			t.Fatalf("%q: unexpected certificate secret %+v", keyType, crt)
+		}
+		key, ok := store.Get(names["key"])
+		if !ok || key.Mode != 0o600 {
+			t.Fatalf("%q: unexpected key secret %+v", keyType, key)
+		}
+
+		if _, err := tls.X509KeyPair([]byte(crt.Value), []byte(key.Value)); err != nil {
+			t.Fatalf("%q: generated pair doesn't match: %v", keyType, err)
+		}
+		block, _ := pem.Decode([]byte(crt.Value))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cert.VerifyHostname("example.com"); err != nil {
+			t.Fatalf("%q: %v", keyType, err)
+		}
+		if d := cert.NotAfter.Sub(time.Now()); d > 48*time.Hour || d < 47*time.Hour {
+			t.Fatalf("%q: expected 48h validity, expires in %s", keyType, d)
+		}
+	}
+
+	for _, query := range []string{"", "?cn=x&key_type=dsa", "?cn=x&validity=-1h"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/secrets/generate-tls"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
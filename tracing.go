@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadataCarrier adapts incoming gRPC metadata to the OpenTelemetry propagators.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// tracingInterceptor puts the W3C trace context (traceparent) sent by the caller in the
+// request context, for Mount latency exemplars to point at the caller trace.
+func tracingInterceptor() grpc.UnaryServerInterceptor {
+	prop := propagation.TraceContext{}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ctx = prop.Extract(ctx, metadataCarrier(md))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ObserveMount records the duration of a Mount call started at start, with the trace
+// and span IDs of ctx as exemplar when withExemplar is set and ctx carries a span.
+func (m *Metrics) ObserveMount(ctx context.Context, start time.Time, withExemplar bool) {
+	d := time.Since(start).Seconds()
+	sc := trace.SpanContextFromContext(ctx)
+	if !withExemplar || !sc.IsValid() {
+		m.mountDuration.Observe(d)
+		return
+	}
+	m.mountDuration.(prometheus.ExemplarObserver).ObserveWithExemplar(d, prometheus.Labels{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+// mountExemplarTraceID returns the trace_id of the exemplar recorded on the Mount duration histogram.
+func mountExemplarTraceID(t *testing.T, m *Metrics) string {
+	t.Helper()
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "csi_debugger_mount_duration_seconds" {
+			continue
+		}
+		for _, b := range f.GetMetric()[0].GetHistogram().GetBucket() {
+			for _, l := range b.GetExemplar().GetLabel() {
+				if l.GetName() == "trace_id" {
+					return l.GetValue()
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func TestMountExemplar(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"traceparent", "00-"+traceID+"-00f067aa0ba902b7-01",
+	))
+	mount := func(cfg Config) *Metrics {
+		metrics := NewMetrics("")
+		srv := NewProviderServer(testLogger(), cfg, NewMemoryStore(testLogger(), cfg), metrics)
+		_, err := tracingInterceptor()(ctx, &v1alpha1.MountRequest{}, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+			return srv.Mount(ctx, req.(*v1alpha1.MountRequest))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metrics
+	}
+
+	if got := mountExemplarTraceID(t, mount(Config{Tracing: true})); got != traceID {
+		t.Errorf("expected an exemplar for trace %s, got %q", traceID, got)
+	}
+	if got := mountExemplarTraceID(t, mount(Config{})); got != "" {
+		t.Errorf("expected no exemplar with tracing off, got %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Content transforms applied to a secret value when it is served to the driver.
+const (
+	TransformNone     = "none"
+	TransformBase64   = "base64"
+	TransformJSONWrap = "json-wrap"
+	TransformTemplate = "template"
+)
+
+var transforms = []string{TransformNone, TransformBase64, TransformJSONWrap, TransformTemplate}
+
+func validTransform(t string) bool {
+	if t == "" {
+		return true
+	}
+	for _, v := range transforms {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// transformData is what a "template" transform can reference, e.g. {{.NodeName}}.
+type transformData struct {
+	Name     string
+	Value    string
+	Version  string
+	NodeName string
+}
+
+// maxTemplateOutput bounds the content a template transform may produce.
+const maxTemplateOutput = 1 << 20
+
+var errTemplateTooLarge = fmt.Errorf("template output exceeds %d bytes", maxTemplateOutput)
+
+// templateFuncs are the functions of the template transform, none of them reaches files
+// or the network. counter numbers the renders of the secret, it is called once per
+// render so every {{counter}} of a render gets the same value.
+func templateFuncs(counter func() uint64) template.FuncMap {
+	var n uint64
+	return template.FuncMap{
+		"now":  func() time.Time { return time.Now().UTC() },
+		"uuid": newUUID,
+		"counter": func() uint64 {
+			if n == 0 && counter != nil {
+				n = counter()
+			}
+			return n
+		},
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// checkTemplateBounded rejects the actions that make a template run for longer than its
+// length: range loops, over an integer they can iterate billions of times without output,
+// and template definitions and calls, which can recurse. What is left runs each action
+// once, with maxTemplateOutput bounding the output.
+func checkTemplateBounded(tmpl *template.Template) error {
+	if len(tmpl.Templates()) > 1 {
+		return errors.New("template definitions aren't allowed")
+	}
+	var check func(n parse.Node) error
+	check = func(n parse.Node) error {
+		switch n := n.(type) {
+		case *parse.RangeNode:
+			return errors.New("range actions aren't allowed")
+		case *parse.TemplateNode:
+			return errors.New("template actions aren't allowed")
+		case *parse.ListNode:
+			if n == nil {
+				return nil
+			}
+			for _, c := range n.Nodes {
+				if err := check(c); err != nil {
+					return err
+				}
+			}
+		case *parse.IfNode:
+			if err := check(n.List); err != nil {
+				return err
+			}
+			return check(n.ElseList)
+		case *parse.WithNode:
+			if err := check(n.List); err != nil {
+				return err
+			}
+			return check(n.ElseList)
+		}
+		return nil
+	}
+	if tmpl.Tree == nil {
+		return nil
+	}
+	return check(tmpl.Tree.Root)
+}
+
+// limitedBuffer fails writes past max bytes, which stops the template execution.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		return 0, errTemplateTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// applyTransform returns the content served for sec under transform t, counter feeds
+// the counter function of templates.
+func applyTransform(t string, sec Secret, nodeName string, counter func() uint64) ([]byte, error) {
+	switch t {
+	case "", TransformNone:
+		return []byte(sec.Value), nil
+	case TransformBase64:
+		return []byte(base64.StdEncoding.EncodeToString([]byte(sec.Value))), nil
+	case TransformJSONWrap:
+		return json.Marshal(map[string]string{
+			"name":    sec.Name,
+			"value":   sec.Value,
+			"version": sec.Version,
+		})
+	case TransformTemplate:
+		tmpl, err := template.New(sec.Name).Option("missingkey=error").Funcs(templateFuncs(counter)).Parse(sec.Value)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTemplateBounded(tmpl); err != nil {
+			return nil, err
+		}
+		buf := limitedBuffer{max: maxTemplateOutput}
+		err = tmpl.Execute(&buf, transformData{
+			Name:     sec.Name,
+			Value:    sec.Value,
+			Version:  sec.Version,
+			NodeName: nodeName,
+		})
+		if errors.Is(err, errTemplateTooLarge) {
+			return nil, errTemplateTooLarge
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown transform %q", t)
+	}
+}
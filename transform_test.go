@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyTransform(t *testing.T) {
+	sec := Secret{Name: "db.txt", Value: "secret", Version: "v1"}
+	tests := []struct {
+		transform string
+		value     string
+		want      string
+	}{
+		{"", "secret", "secret"},
+		{TransformNone, "secret", "secret"},
+		{TransformBase64, "secret", "c2VjcmV0"},
+		{TransformJSONWrap, "secret", `{"name":"db.txt","value":"secret","version":"v1"}`},
+		{TransformTemplate, "node={{.NodeName}} version={{.Version}}", "node=node-1 version=v1"},
+	}
+	for _, tt := range tests {
+		sec.Value = tt.value
+		got, err := applyTransform(tt.transform, sec, "node-1", nil)
+		if err != nil {
+			t.Errorf("transform %q: %v", tt.transform, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("transform %q = %q, want %q", tt.transform, got, tt.want)
+		}
+	}
+
+	if _, err := applyTransform("rot13", sec, "", nil); err == nil {
+		t.Error("expected an error for an unknown transform")
+	}
+	sec.Value = "{{.Missing}}"
+	if _, err := applyTransform(TransformTemplate, sec, "", nil); err == nil {
+		t.Error("expected an error for a template referencing an unknown field")
+	}
+}
+
+func TestGetFilesTransform(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{ContentTransform: TransformBase64})
+	store.Put(Secret{Name: "global.txt", Value: "secret"})
+	store.Put(Secret{Name: "raw.txt", Value: "secret", Transform: TransformNone})
+
+	files, _, _ := store.GetFiles(nil)
+	got := map[string]string{}
+	for _, f := range files {
+		got[f.Path] = string(f.Contents)
+	}
+	if got["global.txt"] != "c2VjcmV0" {
+		t.Errorf("global transform not applied: %q", got["global.txt"])
+	}
+	if got["raw.txt"] != "secret" {
+		t.Errorf("per-secret transform should override global: %q", got["raw.txt"])
+	}
+}
+
+func TestTemplateFunctions(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "id.txt", Value: "{{counter}}-{{counter}} {{uuid}} {{now.Year}}", Transform: TransformTemplate})
+
+	render := func() string {
+		t.Helper()
+		files, _, _ := store.GetFiles(nil)
+		if len(files) != 1 {
+			t.Fatalf("got %d files, want 1", len(files))
+		}
+		return string(files[0].Contents)
+	}
+	first, second := strings.Fields(render()), strings.Fields(render())
+	if first[0] != "1-1" || second[0] != "2-2" {
+		t.Errorf("counters = %q, %q, want 1-1 and 2-2", first[0], second[0])
+	}
+	if len(first[1]) != 36 || first[1][14] != '4' || first[1] == second[1] {
+		t.Errorf("uuids = %q, %q, want two distinct v4 UUIDs", first[1], second[1])
+	}
+	if first[2] != strconv.Itoa(time.Now().UTC().Year()) {
+		t.Errorf("now.Year = %q", first[2])
+	}
+	if !store.Dynamic() {
+		t.Error("a template secret should make mounts dynamic")
+	}
+
+	store.Put(Secret{Name: "id.txt", Value: "{{counter}}", Transform: TransformTemplate})
+	if got := render(); got != "1" {
+		t.Errorf("counter after an update = %q, want 1", got)
+	}
+
+	sec := Secret{Name: "big.txt", Value: `{{printf "%2000000s" ""}}`}
+	if _, err := applyTransform(TransformTemplate, sec, "", nil); !errors.Is(err, errTemplateTooLarge) {
+		t.Errorf("oversized template error = %v, want errTemplateTooLarge", err)
+	}
+}
+
+func TestTemplateBounded(t *testing.T) {
+	for _, src := range []string{
+		`{{range 100000000000}}{{end}}`,
+		`{{if true}}{{range 10}}x{{end}}{{end}}`,
+		`{{with .Name}}{{else}}{{range 10}}{{end}}{{end}}`,
+		`{{define "x"}}{{template "x" .}}{{template "x" .}}{{end}}{{template "x" .}}`,
+	} {
+		if _, err := applyTransform(TransformTemplate, Secret{Name: "loop.txt", Value: src}, "", nil); err == nil {
+			t.Errorf("%s: expected the template rejected", src)
+		}
+	}
+	if got, err := applyTransform(TransformTemplate, Secret{Name: "ok.txt", Value: `{{if .Name}}{{.Name}}{{end}}`}, "", nil); err != nil || string(got) != "ok.txt" {
+		t.Errorf("bounded template = %q, %v", got, err)
+	}
+
+	// A failing template leaves the secret out rather than mounting its source
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "ok.txt", Value: "v"})
+	store.Put(Secret{Name: "loop.txt", Value: `{{range 10}}{{end}}`, Transform: TransformTemplate})
+	files, _, _ := store.GetFiles(nil)
+	if len(files) != 1 || files[0].Path != "ok.txt" {
+		t.Errorf("expected only ok.txt mounted, got %v", files)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Expired reports whether the secret has a TTL that elapsed at now.
+func (sec Secret) Expired(now time.Time) bool {
+	return !sec.ExpiresAt.IsZero() && !now.Before(sec.ExpiresAt)
+}
+
+// expired reports whether sec expired at now. TTLs are paused while the store is frozen,
+// expired secrets stay served until it is unfrozen.
+func (s *MemoryStore) expired(sec Secret, now time.Time) bool {
+	return !s.Frozen() && sec.Expired(now)
+}
+
+// parseTTL parses a TTL duration such as "30s" or "5m", empty meaning no expiry.
+func parseTTL(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TTL %q: %w", v, err)
+	}
+	if ttl <= 0 {
+		return 0, errors.New("TTL must be positive")
+	}
+	return ttl, nil
+}
+
+// expiresAt returns when a secret written now with ttl expires, zero for no TTL.
+func (s *MemoryStore) expiresAt(ttl time.Duration) time.Time {
+	if ttl == 0 {
+		return time.Time{}
+	}
+	return s.now().Add(ttl)
+}
+
+// ExpireSecrets deletes the secrets whose TTL elapsed and returns their names, nothing
+// while the store is frozen.
+func (s *MemoryStore) ExpireSecrets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var expired []string
+	for name, sec := range s.secrets {
+		if s.expired(sec, now) {
+			expired = append(expired, name)
+			s.deleteLocked(name)
+			s.logger.Info("secret expired", "name", name, "expires_at", sec.ExpiresAt)
+		}
+	}
+	if len(expired) > 0 {
+		s.notifyLocked()
+	}
+	return expired
+}
+
+// RunExpiry sweeps expired secrets every TTL_SWEEP_INTERVAL, so they go away even
+// when nothing lists or mounts the store.
+func (s *MemoryStore) RunExpiry(ctx context.Context) error {
+	if s.cfg.TTLSweepInterval <= 0 {
+		return nil
+	}
+	t := time.NewTicker(s.cfg.TTLSweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			s.ExpireSecrets()
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretTTL(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Put(Secret{Name: "short.txt", Value: "1", Version: "v1", Mode: 420, ExpiresAt: store.expiresAt(time.Minute)})
+	store.Set("forever.txt", "2", "v1", 420)
+
+	if n := len(store.List()); n != 2 {
+		t.Fatalf("expected 2 secrets before expiry, got %d", n)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := store.Get("short.txt"); ok {
+		t.Error("expected Get to hide the expired secret")
+	}
+	files, _, _ := store.GetFiles(nil)
+	if len(files) != 1 || files[0].Path != "forever.txt" {
+		t.Errorf("expected only forever.txt mounted, got %v", files)
+	}
+	// GetFiles deleted it lazily
+	if n := store.Len(); n != 1 {
+		t.Errorf("expected the expired secret deleted, store holds %d secrets", n)
+	}
+}
+
+func TestTTLPausedWhileFrozen(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.Put(Secret{Name: "short.txt", Value: "1", Version: "v1", Mode: 420, ExpiresAt: store.expiresAt(time.Minute)})
+	store.SetFrozen(true)
+
+	now = now.Add(time.Minute)
+	if expired := store.ExpireSecrets(); len(expired) != 0 {
+		t.Errorf("expected nothing to expire while frozen, got %v", expired)
+	}
+	if files, _, _ := store.GetFiles(nil); len(files) != 1 {
+		t.Errorf("expected the secret still mounted while frozen, got %d files", len(files))
+	}
+
+	store.SetFrozen(false)
+	if expired := store.ExpireSecrets(); len(expired) != 1 {
+		t.Errorf("expected the secret to expire once unfrozen, got %v", expired)
+	}
+}
+
+func TestExpireSecrets(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{TTLSweepInterval: 10 * time.Millisecond})
+	store.Put(Secret{Name: "gone.txt", Value: "1", Version: "v1", Mode: 420, ExpiresAt: time.Now().Add(20 * time.Millisecond)})
+	store.Set("kept.txt", "2", "v1", 420)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go store.RunExpiry(ctx)
+
+	changed := store.Changed()
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the sweep didn't delete the expired secret")
+	}
+	if n := store.Len(); n != 1 {
+		t.Errorf("expected 1 secret left, got %d", n)
+	}
+}
+
+func TestTTLField(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	form := url.Values{"name": {"form.txt"}, "value": {"1"}, "version": {"v1"}, "ttl": {"5m"}}
+	req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d: %s", rec.Code, rec.Body)
+	}
+	if sec, _ := store.Get("form.txt"); time.Until(sec.ExpiresAt).Round(time.Minute) != 5*time.Minute {
+		t.Errorf("expected form.txt to expire in 5m, got %v", sec.ExpiresAt)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(`{"name":"api.txt","value":"1","ttl":"30s"}`))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if sec, _ := store.Get("api.txt"); time.Until(sec.ExpiresAt).Round(time.Second) != 30*time.Second {
+		t.Errorf("expected api.txt to expire in 30s, got %v", sec.ExpiresAt)
+	}
+
+	for _, ttl := range []string{"soon", "-1m", "0s"} {
+		req = httptest.NewRequest(http.MethodPost, "/api/secrets", strings.NewReader(`{"name":"bad.txt","value":"1","ttl":"`+ttl+`"}`))
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("ttl %q: expected 400, got %d", ttl, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tx stages changes to a MemoryStore, see Transaction.
+type Tx struct {
+	ops []txOp
+}
+
+// txOp is a staged Put, or a Delete of name when put is nil.
+type txOp struct {
+	put  *Secret
+	name string
+}
+
+// validateSecret checks the fields a secret can't be mounted without.
+func validateSecret(sec Secret) error {
+	if sec.Name == "" {
+		return invalid(reasonRequired, errors.New("name required"))
+	}
+	if !validTransform(sec.Transform) {
+		return invalid(reasonTransform, fmt.Errorf("unknown transform %q", sec.Transform))
+	}
+	if !validSecretType(sec.Type) {
+		return invalid(reasonType, fmt.Errorf("unknown secret type %q", sec.Type))
+	}
+	if err := validMountAs(sec.MountAs); err != nil {
+		return invalid(reasonPath, err)
+	}
+	if !validEncoding(sec.Encoding) {
+		return invalid(reasonEncoding, fmt.Errorf("unknown encoding %q", sec.Encoding))
+	}
+	if !validLineEnding(sec.LineEnding) {
+		return invalid(reasonEncoding, fmt.Errorf("unknown line ending %q", sec.LineEnding))
+	}
+	if err := checkVariants(sec); err != nil {
+		return err
+	}
+	if sec.Type == SecretTypeHTTPRef {
+		if err := validHTTPRef(sec.Value); err != nil {
+			return invalid(reasonURL, err)
+		}
+	}
+	return nil
+}
+
+// Put stages sec, it fails if sec is invalid.
+func (tx *Tx) Put(sec Secret) error {
+	if err := validateSecret(sec); err != nil {
+		return fmt.Errorf("secret %q: %w", sec.Name, err)
+	}
+	tx.ops = append(tx.ops, txOp{put: &sec, name: sec.Name})
+	return nil
+}
+
+// Delete stages the removal of the named secret.
+func (tx *Tx) Delete(name string) {
+	tx.ops = append(tx.ops, txOp{name: name})
+}
+
+// Transaction calls fn to stage changes, then applies them all under a single write
+// lock with one change notification. Nothing is applied if fn returns an error.
+func (s *MemoryStore) Transaction(fn func(tx *Tx) error) error {
+	var tx Tx
+	if err := fn(&tx); err != nil {
+		s.logger.Info("transaction rolled back", "staged", len(tx.ops), "error", err)
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, op := range tx.ops {
+		if op.put != nil {
+			s.putLocked(*op.put)
+		} else {
+			s.deleteLocked(op.name)
+		}
+	}
+	s.notifyLocked()
+	s.logger.Info("transaction committed", "changes", len(tx.ops))
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("old.txt", "x", "v1", 420)
+	changed := store.Changed()
+
+	err := store.Transaction(func(tx *Tx) error {
+		if err := tx.Put(Secret{Name: "tls.crt", Value: "cert", Version: "v1", Mode: 420}); err != nil {
+			return err
+		}
+		if err := tx.Put(Secret{Name: "tls.key", Value: "key", Version: "v1", Mode: 420}); err != nil {
+			return err
+		}
+		tx.Delete("old.txt")
+
+		// Nothing is visible before the commit
+		if _, ok := store.Get("tls.crt"); ok {
+			t.Error("staged secret visible before commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	default:
+		t.Error("expected a change notification")
+	}
+	list := store.List()
+	if len(list) != 2 || list[0].Name != "tls.crt" || list[1].Name != "tls.key" {
+		t.Errorf("unexpected store content: %+v", list)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("tls.crt", "old", "v1", 420)
+	changed := store.Changed()
+
+	err := store.Transaction(func(tx *Tx) error {
+		if err := tx.Put(Secret{Name: "tls.crt", Value: "new", Version: "v2"}); err != nil {
+			return err
+		}
+		return tx.Put(Secret{Name: "tls.key", Value: "key", Transform: "rot13"})
+	})
+	if err == nil {
+		t.Fatal("expected the invalid transform to fail the transaction")
+	}
+
+	sentinel := errors.New("abort")
+	if err := store.Transaction(func(tx *Tx) error {
+		tx.Delete("tls.crt")
+		return sentinel
+	}); !errors.Is(err, sentinel) {
+		t.Errorf("expected the fn error back, got %v", err)
+	}
+
+	select {
+	case <-changed:
+		t.Error("rolled back transactions must not notify")
+	default:
+	}
+	list := store.List()
+	if len(list) != 1 || list[0].Value != "old" || list[0].Version != "v1" {
+		t.Errorf("store changed by rolled back transactions: %+v", list)
+	}
+}
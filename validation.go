@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Reasons of csi_debugger_validation_rejections_total.
+const (
+	reasonRequired    = "required"
+	reasonMode        = "mode"
+	reasonAnnotations = "annotations"
+	reasonLabels      = "labels"
+	reasonTransform   = "transform"
+	reasonType        = "type"
+	reasonPath        = "path"
+	reasonURL         = "url"
+	reasonJSON        = "json"
+	reasonDotenv      = "dotenv"
+	reasonEncoding    = "encoding"
+	reasonHex         = "hex"
+	reasonCollision   = "collision"
+	reasonVersion     = "version"
+	reasonTTL         = "ttl"
+	reasonSize        = "size"
+	reasonInvalid     = "invalid"
+)
+
+// validationError is an input error along with its rejection reason.
+type validationError struct {
+	reason string
+	err    error
+}
+
+func (e *validationError) Error() string { return e.err.Error() }
+func (e *validationError) Unwrap() error { return e.err }
+
+func invalid(reason string, err error) error {
+	return &validationError{reason: reason, err: err}
+}
+
+// rejectionReason returns the reason of the validationError in err's chain.
+func rejectionReason(err error) string {
+	var v *validationError
+	if errors.As(err, &v) {
+		return v.reason
+	}
+	return reasonInvalid
+}
+
+// rejectionStatus is the HTTP status of a rejection: 413 for an oversized value, 400 otherwise.
+func rejectionStatus(reason string) int {
+	if reason == reasonSize {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// reject answers a form request with 400 and counts the rejection.
+func (w *WebServer) reject(rw http.ResponseWriter, reason, msg string) {
+	w.metrics.validationRejections.WithLabelValues(reason).Inc()
+	http.Error(rw, msg, rejectionStatus(reason))
+}
+
+// rejectJSON answers an API request with a 400 JSON error and counts the rejection.
+func (w *WebServer) rejectJSON(rw http.ResponseWriter, reason, msg string) {
+	w.metrics.validationRejections.WithLabelValues(reason).Inc()
+	w.writeJSONError(rw, rejectionStatus(reason), msg)
+}
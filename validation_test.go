@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/caarlos0/env/v11"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestValidationRejections(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	send := func(method, path, contentType, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	form := "application/x-www-form-urlencoded"
+
+	bad := []struct {
+		method, path, contentType, body, reason string
+	}{
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"x"}, "mode": {"rwz"}}.Encode(), reasonMode},
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"x"}, "mount_as": {"../b"}}.Encode(), reasonPath},
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}}.Encode(), reasonRequired},
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"not a url"}, "type": {SecretTypeHTTPRef}}.Encode(), reasonURL},
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"x"}, "transform": {"rot13"}}.Encode(), reasonTransform},
+		{http.MethodPost, "/bulk", form, url.Values{"json_data": {"[{"}}.Encode(), reasonJSON},
+		{http.MethodPost, "/bulk", form, url.Values{"json_data": {`[{"name":"b.txt","mountAs":"/etc/b"}]`}}.Encode(), reasonPath},
+		{http.MethodPatch, "/api/secrets/a.txt", "application/json", `{"mode":"rwz"}`, reasonMode},
+		{http.MethodPatch, "/api/secrets/a.txt", "application/json", `{"transform":"nope"}`, reasonTransform},
+		{http.MethodPost, "/api/import/dotenv", "text/plain", `A="unterminated`, reasonDotenv},
+	}
+	for _, b := range bad {
+		before := testutil.ToFloat64(web.metrics.validationRejections.WithLabelValues(b.reason))
+		if code := send(b.method, b.path, b.contentType, b.body); code != http.StatusBadRequest {
+			t.Errorf("%s %s %q: expected 400, got %d", b.method, b.path, b.body, code)
+		}
+		if got := testutil.ToFloat64(web.metrics.validationRejections.WithLabelValues(b.reason)); got != before+1 {
+			t.Errorf("%s %s %q: %s rejections = %v, want %v", b.method, b.path, b.body, b.reason, got, before+1)
+		}
+	}
+}
+
+func TestMaxSecretBytesRejections(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	web := newTestWeb(t, Config{MaxSecretBytes: 4}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	send := func(method, path, contentType, body string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	form := "application/x-www-form-urlencoded"
+
+	tooLarge := []struct {
+		method, path, contentType, body string
+	}{
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"12345"}}.Encode()},
+		// base64 of 5 bytes, the limit applies to the decoded content
+		{http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"MTIzNDU="}, "content_encoding": {"base64"}}.Encode()},
+		{http.MethodPost, "/bulk", form, url.Values{"json_data": {`[{"name":"b.txt","value":"12345"}]`}}.Encode()},
+		{http.MethodPost, "/api/secrets", "application/json", `{"name":"b.txt","value":"12345"}`},
+		{http.MethodPut, "/api/secrets/a.txt", "application/json", `{"value":"12345"}`},
+		{http.MethodPatch, "/api/secrets/a.txt", "application/json", `{"value":"12345"}`},
+	}
+	for _, b := range tooLarge {
+		if code := send(b.method, b.path, b.contentType, b.body); code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s %s %q: expected 413, got %d", b.method, b.path, b.body, code)
+		}
+	}
+	if sec, _ := store.Get("a.txt"); sec.Value != "1" || store.Len() != 1 {
+		t.Errorf("expected the store to be untouched, got a.txt=%q and %d secrets", sec.Value, store.Len())
+	}
+
+	// Base64 of 4 bytes is longer than the limit but decodes within it
+	if code := send(http.MethodPost, "/update", form, url.Values{"name": {"b.txt"}, "value": {"MTIzNA=="}, "content_encoding": {"base64"}}.Encode()); code != http.StatusSeeOther {
+		t.Errorf("expected a value at the limit to be stored, got %d", code)
+	}
+	// An oversized item next to an invalid one is reported as a bad request
+	if code := send(http.MethodPost, "/bulk", form, url.Values{"json_data": {`[{"name":"c.txt","value":"12345"},{"name":"d.txt","mode":"rwz"}]`}}.Encode()); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for mixed failures, got %d", code)
+	}
+}
+
+func TestMaxSecretBytesDefault(t *testing.T) {
+	for environ, want := range map[string]int{"": 1 << 20, "0": 0} {
+		vars := map[string]string{}
+		if environ != "" {
+			vars["MAX_SECRET_BYTES"] = environ
+		}
+		var cfg Config
+		if err := env.ParseWithOptions(&cfg, env.Options{Environment: vars}); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.MaxSecretBytes != want {
+			t.Errorf("MAX_SECRET_BYTES=%q: got %d, want %d", environ, cfg.MaxSecretBytes, want)
+		}
+	}
+
+	// 0 opts out of the limit
+	web := newTestWeb(t, Config{}, NewMemoryStore(testLogger(), Config{}))
+	if err := web.checkSize(strings.Repeat("x", 2<<20)); err != nil {
+		t.Errorf("expected no limit with MAX_SECRET_BYTES=0, got %v", err)
+	}
+}
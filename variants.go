@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+)
+
+// Strategies picking the value of a SecretTypeVariants secret on every mount.
+const (
+	VariantRoundRobin = "round-robin"
+	VariantRandom     = "random"
+)
+
+func validVariantStrategy(s string) bool {
+	return s == "" || s == VariantRoundRobin || s == VariantRandom
+}
+
+// parseVariants parses the value of a SecretTypeVariants secret, a JSON array of the
+// candidate values.
+func parseVariants(value string) ([]string, error) {
+	var variants []string
+	if err := json.Unmarshal([]byte(value), &variants); err != nil {
+		return nil, fmt.Errorf("variants must be a JSON array of strings: %w", err)
+	}
+	if len(variants) == 0 {
+		return nil, errors.New("variants must hold at least one value")
+	}
+	return variants, nil
+}
+
+// checkVariants validates the variant settings of sec.
+func checkVariants(sec Secret) error {
+	if !validVariantStrategy(sec.VariantStrategy) {
+		return invalid(reasonType, fmt.Errorf("unknown variant strategy %q", sec.VariantStrategy))
+	}
+	if sec.Type != SecretTypeVariants {
+		return nil
+	}
+	// Both would advance the same per secret counter
+	if sec.RotateOnMount {
+		return invalid(reasonType, errors.New("rotate on mount can't be combined with variants"))
+	}
+	if _, err := parseVariants(sec.Value); err != nil {
+		return invalid(reasonJSON, err)
+	}
+	return nil
+}
+
+// pickVariant returns the index of the variant of sec to mount, out of n, cycling in
+// order with VariantRoundRobin (the default) or drawn with VariantRandom.
+func (s *MemoryStore) pickVariant(sec Secret, n int) int {
+	if sec.VariantStrategy == VariantRandom {
+		return rand.IntN(n)
+	}
+	return int((s.nextRotation(sec.Name) - 1) % uint64(n))
+}
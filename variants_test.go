@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestMountVariantsRoundRobin(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "db.txt", Value: `["primary","replica-a","replica-b"]`, Version: "v1", Mode: 420, Type: SecretTypeVariants})
+	srv := NewProviderServer(testLogger(), Config{MountCache: true}, store, NewMetrics(""))
+
+	want := []string{"primary", "replica-a", "replica-b", "primary", "replica-a"}
+	for n, value := range want {
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, v := resp.GetFiles()[0], resp.GetObjectVersion()[0]
+		if string(f.GetContents()) != value || v.GetVersion() != fmt.Sprintf("v1-%d", n%3) {
+			t.Errorf("mount %d: expected %s at v1-%d, got %s at %s", n, value, n%3, f.GetContents(), v.GetVersion())
+		}
+	}
+}
+
+func TestMountVariantsRandom(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Put(Secret{Name: "db.txt", Value: `["a","b"]`, Version: "v1", Mode: 420, Type: SecretTypeVariants, VariantStrategy: VariantRandom})
+	srv := NewProviderServer(testLogger(), Config{}, store, NewMetrics(""))
+
+	seen := map[string]bool{}
+	for range 100 {
+		resp, err := srv.Mount(context.Background(), &v1alpha1.MountRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[string(resp.GetFiles()[0].GetContents())] = true
+	}
+	if !seen["a"] || !seen["b"] || len(seen) != 2 {
+		t.Errorf("expected both variants to be drawn, got %v", seen)
+	}
+}
+
+func TestCheckVariants(t *testing.T) {
+	bad := []Secret{
+		{Name: "a", Type: SecretTypeVariants, Value: "primary"},
+		{Name: "a", Type: SecretTypeVariants, Value: "[]"},
+		{Name: "a", Type: SecretTypeVariants, Value: `["x"]`, RotateOnMount: true},
+		{Name: "a", Type: SecretTypeVariants, Value: `["x"]`, VariantStrategy: "weighted"},
+	}
+	for _, sec := range bad {
+		if err := validateSecret(sec); err == nil {
+			t.Errorf("expected %+v to be rejected", sec)
+		}
+	}
+	if err := validateSecret(Secret{Name: "a", Type: SecretTypeVariants, Value: `["x","y"]`, VariantStrategy: VariantRandom}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VERSION_VALIDATION modes, the format versions set through the admin server must have.
+const (
+	VersionValidationOff     = "off"
+	VersionValidationSemver  = "semver"
+	VersionValidationInteger = "integer"
+	VersionValidationPattern = "pattern"
+)
+
+var (
+	semverRE  = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+	integerRE = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// versionRegexp returns the regexp versions must match under VERSION_VALIDATION, nil
+// when any version is accepted. A VERSION_PATTERN must match the whole version.
+func versionRegexp(mode, pattern string) (*regexp.Regexp, error) {
+	switch mode {
+	case VersionValidationOff, "":
+		return nil, nil
+	case VersionValidationSemver:
+		return semverRE, nil
+	case VersionValidationInteger:
+		return integerRE, nil
+	case VersionValidationPattern:
+		if pattern == "" {
+			return nil, fmt.Errorf("VERSION_VALIDATION=pattern needs VERSION_PATTERN")
+		}
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VERSION_PATTERN: %w", err)
+		}
+		return re, nil
+	}
+	return nil, fmt.Errorf("unknown VERSION_VALIDATION %q, must be off, semver, integer or pattern", mode)
+}
+
+// checkVersion rejects a version not in the VERSION_VALIDATION format.
+func (w *WebServer) checkVersion(version string) error {
+	if w.versionRE == nil || w.versionRE.MatchString(version) {
+		return nil
+	}
+	return invalid(reasonVersion, fmt.Errorf("version %q doesn't match the %s format %s", version, w.provider.cfg.VersionValidation, w.versionRE))
+}
+
+// compareVersions orders versions, returning -1, 0 or +1. Semantic versions are compared
+// by precedence, a pre-release coming before its release, other versions in natural
+// order, runs of digits compared as numbers so v2 comes before v10.
+func compareVersions(a, b string) int {
+	ma, mb := semverRE.FindStringSubmatch(a), semverRE.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return naturalCompare(a, b)
+	}
+	for i := 1; i <= 3; i++ {
+		if c := naturalCompare(ma[i], mb[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case ma[4] == mb[4]:
+		return 0
+	case ma[4] == "":
+		return 1
+	case mb[4] == "":
+		return -1
+	}
+	return naturalCompare(ma[4], mb[4])
+}
+
+// naturalCompare compares a and b chunk by chunk, runs of digits by numeric value.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ca, ra := versionChunk(a)
+		cb, rb := versionChunk(b)
+		na, errA := strconv.ParseUint(ca, 10, 64)
+		nb, errB := strconv.ParseUint(cb, 10, 64)
+		var c int
+		if errA == nil && errB == nil {
+			c = cmp.Compare(na, nb)
+		} else {
+			c = strings.Compare(ca, cb)
+		}
+		if c != 0 {
+			return c
+		}
+		a, b = ra, rb
+	}
+	return strings.Compare(a, b)
+}
+
+// versionChunk splits the leading run of digits or of non digits off s.
+func versionChunk(s string) (chunk, rest string) {
+	digit := s[0] >= '0' && s[0] <= '9'
+	i := 1
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
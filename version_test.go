@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestVersionRegexp(t *testing.T) {
+	tests := []struct {
+		mode, pattern string
+		good, bad     []string
+	}{
+		{VersionValidationSemver, "", []string{"1.2.3", "v0.1.0", "1.0.0-rc.1", "1.0.0+build.5"}, []string{"v1", "1.2", "01.2.3", "latest"}},
+		{VersionValidationInteger, "", []string{"0", "42"}, []string{"v1", "-1", "1.0"}},
+		{VersionValidationPattern, "v[0-9]+", []string{"v1", "v10"}, []string{"v", "xv1", "v1-beta"}},
+	}
+	for _, tt := range tests {
+		re, err := versionRegexp(tt.mode, tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range tt.good {
+			if !re.MatchString(v) {
+				t.Errorf("%s: expected %q to conform", tt.mode, v)
+			}
+		}
+		for _, v := range tt.bad {
+			if re.MatchString(v) {
+				t.Errorf("%s: expected %q to be rejected", tt.mode, v)
+			}
+		}
+	}
+
+	if re, err := versionRegexp(VersionValidationOff, ""); re != nil || err != nil {
+		t.Errorf("expected no validation when off, got %v %v", re, err)
+	}
+	for _, bad := range [][2]string{{"calver", ""}, {VersionValidationPattern, ""}, {VersionValidationPattern, "v[0-9"}} {
+		if _, err := versionRegexp(bad[0], bad[1]); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	ordered := [][]string{
+		{"v1", "v2", "v10"},
+		{"1", "9", "10", "100"},
+		{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0", "1.0.1", "1.10.0", "v2.0.0"},
+		{"2024.1", "2024.2", "2024.10"},
+	}
+	for _, versions := range ordered {
+		for i := range versions {
+			for j := range versions {
+				want := 0
+				if i < j {
+					want = -1
+				} else if i > j {
+					want = 1
+				}
+				if got := compareVersions(versions[i], versions[j]); got != want {
+					t.Errorf("compareVersions(%q, %q) = %d, want %d", versions[i], versions[j], got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestVersionValidationRejects(t *testing.T) {
+	cfg := Config{VersionValidation: VersionValidationSemver}
+	store := NewMemoryStore(testLogger(), cfg)
+	web := newTestWeb(t, cfg, store)
+
+	post := func(version string) int {
+		form := url.Values{"name": {"a.txt"}, "value": {"x"}, "version": {version}}
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		web.handleUpdate(rec, req)
+		return rec.Code
+	}
+	if code := post("v1"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non semver version, got %d", code)
+	}
+	if _, ok := store.Get("a.txt"); ok {
+		t.Error("non conforming version was stored")
+	}
+	if code := post("1.0.0"); code != http.StatusSeeOther {
+		t.Errorf("expected a conforming version to be stored, got %d", code)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/api/secrets/a.txt", strings.NewReader(`{"version":"two"}`))
+	req.SetPathValue("name", "a.txt")
+	web.handlePatchSecret(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 patching a non semver version, got %d", rec.Code)
+	}
+
+	if _, err := NewWebServer(testLogger(), store, NewMetrics(""), NewProviderServer(testLogger(), Config{VersionValidation: "calver"}, store, NewMetrics(""))); err == nil {
+		t.Error("expected an error for an unknown VERSION_VALIDATION")
+	}
+}
+
+func TestVersionValidationBumpedVersions(t *testing.T) {
+	cfg := Config{VersionValidation: VersionValidationPattern, VersionPattern: `release-[a-z]+`}
+	store := NewMemoryStore(testLogger(), cfg)
+	store.Set("a.txt", "x", "release-a", 420)
+	web := newTestWeb(t, cfg, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+
+	// release-a bumps to release-a-1, outside VERSION_PATTERN
+	before := testutil.ToFloat64(web.metrics.validationRejections.WithLabelValues(reasonVersion))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/secrets/a.txt/chmod?mode=0600", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a chmod bumping to a non conforming version, got %d", rec.Code)
+	}
+	if got := testutil.ToFloat64(web.metrics.validationRejections.WithLabelValues(reasonVersion)); got != before+1 {
+		t.Errorf("version rejections = %v, want %v", got, before+1)
+	}
+
+	form := url.Values{"json_data": {`[{"name":"a.txt","value":"y","version":"release-b"}]`}, "collisionPolicy": {CollisionVersionBump}}
+	req := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an import bumping to a non conforming version, got %d", rec.Code)
+	}
+
+	if sec, _ := store.Get("a.txt"); sec.Version != "release-a" || sec.Mode != 420 {
+		t.Errorf("expected a.txt untouched, got %+v", sec)
+	}
+}
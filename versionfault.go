@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxVersionFaultLatency bounds the injected Version latency.
+const maxVersionFaultLatency = 5 * time.Minute
+
+// VersionFault is injected into Version calls only, to test how the driver health check
+// reacts to a slow or failing provider while mounts keep working.
+type VersionFault struct {
+	// Latency delays every Version answer, as a duration string like "3s"
+	Latency string `json:"latency,omitempty"`
+	// Code, a gRPC code name like "UNAVAILABLE", makes Version fail after Latency
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Details is attached to the error status as an ErrorInfo, for clients reading status details
+	Details *FaultDetails `json:"details,omitempty"`
+
+	latency time.Duration
+	code    codes.Code
+}
+
+// parse validates the fault and fills its parsed fields.
+func (f *VersionFault) parse() error {
+	f.latency, f.code = 0, codes.OK
+	if f.Latency != "" {
+		d, err := time.ParseDuration(f.Latency)
+		if err != nil || d < 0 || d > maxVersionFaultLatency {
+			return fmt.Errorf("latency must be a duration between 0 and %s", maxVersionFaultLatency)
+		}
+		f.latency = d
+	}
+	if f.Code != "" {
+		if err := f.code.UnmarshalJSON([]byte(strconv.Quote(f.Code))); err != nil {
+			return fmt.Errorf("unknown gRPC code %q", f.Code)
+		}
+	}
+	if f.Details != nil && f.code == codes.OK {
+		return fmt.Errorf("details need an error code")
+	}
+	return nil
+}
+
+// FaultDetails is the google.rpc.ErrorInfo attached to an injected error.
+type FaultDetails struct {
+	Reason   string            `json:"reason"`
+	Domain   string            `json:"domain,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// faultError returns the injected error status, with details as an ErrorInfo when set.
+func faultError(code codes.Code, msg string, details *FaultDetails) error {
+	st := status.New(code, msg)
+	if details == nil {
+		return st.Err()
+	}
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   details.Reason,
+		Domain:   details.Domain,
+		Metadata: details.Metadata,
+	})
+	if err != nil {
+		// Only fails for an OK code, which parse rules out
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// inject applies the fault, it returns the error Version must answer with, if any.
+func (f *VersionFault) inject(ctx context.Context) error {
+	if f.latency > 0 {
+		t := time.NewTimer(f.latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if f.code != codes.OK {
+		msg := f.Message
+		if msg == "" {
+			msg = "injected Version fault"
+		}
+		return faultError(f.code, msg, f.Details)
+	}
+	return nil
+}
+
+func (w *WebServer) handleGetVersionFault(rw http.ResponseWriter, r *http.Request) {
+	f := w.provider.versionFault.Load()
+	if f == nil {
+		f = &VersionFault{}
+	}
+	w.writeJSON(rw, http.StatusOK, f)
+}
+
+// handleSetVersionFault replaces the Version fault, an empty object clears it.
+func (w *WebServer) handleSetVersionFault(rw http.ResponseWriter, r *http.Request) {
+	var f VersionFault
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if err := f.parse(); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.provider.versionFault.Store(&f)
+	w.logger.Info("Version fault set via API", "latency", f.latency, "code", f.code, "message", f.Message)
+	w.writeJSON(rw, http.StatusOK, &f)
+}
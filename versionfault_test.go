@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestVersionFault(t *testing.T) {
+	store := NewMemoryStore(testLogger(), Config{})
+	store.Set("a.txt", "1", "v1", 420)
+	web := newTestWeb(t, Config{}, store)
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	put := func(body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, srv.URL+"/api/version-fault", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	version := func() (time.Duration, error) {
+		start := time.Now()
+		_, err := web.provider.Version(context.Background(), &v1alpha1.VersionRequest{Version: "v1alpha1"})
+		return time.Since(start), err
+	}
+
+	for _, body := range []string{`{"latency":"forever"}`, `{"latency":"1h"}`, `{"code":"NOPE"}`, `{"delay":"1s"}`} {
+		if code := put(body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, code)
+		}
+	}
+
+	if code := put(`{"latency":"100ms"}`); code != http.StatusOK {
+		t.Fatalf("set latency: expected 200, got %d", code)
+	}
+	if took, err := version(); err != nil || took < 100*time.Millisecond {
+		t.Errorf("expected a successful Version after 100ms, took %s with error %v", took, err)
+	}
+
+	if code := put(`{"code":"UNAVAILABLE","message":"backend down"}`); code != http.StatusOK {
+		t.Fatalf("set error: expected 200, got %d", code)
+	}
+	_, err := version()
+	if status.Code(err) != codes.Unavailable || status.Convert(err).Message() != "backend down" {
+		t.Errorf("expected the injected Unavailable error, got %v", err)
+	}
+
+	// Mount is left alone
+	if _, err := web.provider.Mount(context.Background(), &v1alpha1.MountRequest{}); err != nil {
+		t.Errorf("Mount failed while Version is faulted: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/version-fault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got VersionFault
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != "UNAVAILABLE" || got.Message != "backend down" {
+		t.Errorf("unexpected fault returned by GET: %+v", got)
+	}
+
+	if code := put(`{"code":"UNAVAILABLE","details":{"reason":"BACKEND_DOWN","domain":"debugger.test","metadata":{"region":"eu"}}}`); code != http.StatusOK {
+		t.Fatalf("set error with details: expected 200, got %d", code)
+	}
+	_, err = version()
+	details := status.Convert(err).Details()
+	if len(details) != 1 {
+		t.Fatalf("expected 1 status detail, got %v", details)
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok || info.Reason != "BACKEND_DOWN" || info.Domain != "debugger.test" || info.Metadata["region"] != "eu" {
+		t.Errorf("unexpected status detail: %v", details[0])
+	}
+	if code := put(`{"details":{"reason":"X"}}`); code != http.StatusBadRequest {
+		t.Errorf("details without a code: expected 400, got %d", code)
+	}
+
+	if code := put(`{}`); code != http.StatusOK {
+		t.Fatalf("clear: expected 200, got %d", code)
+	}
+	if _, err := version(); err != nil {
+		t.Errorf("expected Version to succeed once cleared, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+)
+
+// What Version reports unless configured otherwise.
+const (
+	defaultProviderAPIVersion = "v1alpha1"
+	defaultRuntimeName        = "csi-debugger-provider"
+	defaultRuntimeVersion     = "0.0.1"
+)
+
+// VersionInfo is what Version answers, from PROVIDER_API_VERSION, RUNTIME_NAME and
+// RUNTIME_VERSION or the admin API.
+type VersionInfo struct {
+	Version        string `json:"version"`
+	RuntimeName    string `json:"runtimeName"`
+	RuntimeVersion string `json:"runtimeVersion"`
+}
+
+// versionInfo returns the identity Version reports, each field coming from the admin
+// API, else the config, else its default.
+func (s *ProviderServer) versionInfo() VersionInfo {
+	var o VersionInfo
+	if p := s.versionOverride.Load(); p != nil {
+		o = *p
+	}
+	return VersionInfo{
+		Version:        cmp.Or(o.Version, s.cfg.ProviderAPIVersion, defaultProviderAPIVersion),
+		RuntimeName:    cmp.Or(o.RuntimeName, s.cfg.RuntimeName, defaultRuntimeName),
+		RuntimeVersion: cmp.Or(o.RuntimeVersion, s.cfg.RuntimeVersion, defaultRuntimeVersion),
+	}
+}
+
+func (w *WebServer) handleGetVersionInfo(rw http.ResponseWriter, r *http.Request) {
+	w.writeJSON(rw, http.StatusOK, w.provider.versionInfo())
+}
+
+// handleSetVersionInfo overrides the fields Version reports, an empty field reverts to
+// its config value.
+func (w *WebServer) handleSetVersionInfo(rw http.ResponseWriter, r *http.Request) {
+	var o VersionInfo
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		w.writeJSONError(rw, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	w.provider.versionOverride.Store(&o)
+	info := w.provider.versionInfo()
+	w.logger.Info("Version identity set via API", "version", info.Version, "runtime_name", info.RuntimeName, "runtime_version", info.RuntimeVersion)
+	w.writeJSON(rw, http.StatusOK, info)
+}
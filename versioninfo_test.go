@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/secrets-store-csi-driver/provider/v1alpha1"
+)
+
+func TestVersionInfo(t *testing.T) {
+	web := newTestWeb(t, Config{RuntimeName: "vault", RuntimeVersion: "1.2.3"}, NewMemoryStore(testLogger(), Config{}))
+	mux := http.NewServeMux()
+	web.RegisterHandlers(mux)
+	version := func() *v1alpha1.VersionResponse {
+		t.Helper()
+		resp, err := web.provider.Version(context.Background(), &v1alpha1.VersionRequest{Version: "v1alpha1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := version(); resp.Version != "v1alpha1" || resp.RuntimeName != "vault" || resp.RuntimeVersion != "1.2.3" {
+		t.Errorf("configured Version response = %v", resp)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/version-info", strings.NewReader(`{"version":"v2","runtimeVersion":"9.9.9"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d", rec.Code)
+	}
+	var info VersionInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	want := VersionInfo{Version: "v2", RuntimeName: "vault", RuntimeVersion: "9.9.9"}
+	if info != want {
+		t.Errorf("PUT answered %+v, want %+v", info, want)
+	}
+	if resp := version(); resp.Version != "v2" || resp.RuntimeName != "vault" || resp.RuntimeVersion != "9.9.9" {
+		t.Errorf("overridden Version response = %v", resp)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/version-info", strings.NewReader(`{}`)))
+	if resp := version(); resp.Version != "v1alpha1" || resp.RuntimeVersion != "1.2.3" {
+		t.Errorf("expected an empty override to restore the config, got %v", resp)
+	}
+}